@@ -19,18 +19,79 @@ type Clerk struct {
 	SecretKey     string `yaml:"secret_key"`      // Clerk secret key (required when auth_provider is "clerk")
 }
 
+// NotFoundFallback decides what visitors see on a short domain when a slug doesn't exist
+type NotFoundFallback struct {
+	Action       string `yaml:"action"`        // "landing", "redirect", "html" or "json"
+	URL          string `yaml:"url"`           // URL to redirect to (required for "redirect")
+	TemplatePath string `yaml:"template_path"` // Path to an html/template file rendered as the 404 page (required for "html")
+}
+
+// URLPolicy controls which destination URLs short links may point to
+type URLPolicy struct {
+	AllowedSchemes        []string `yaml:"allowed_schemes"`         // Destination schemes accepted (default: http, https)
+	AllowPrivateAddresses bool     `yaml:"allow_private_addresses"` // Accept destinations on private, loopback and reserved addresses (default: false)
+	BlocklistPath         string   `yaml:"blocklist_path"`          // File with one blocked domain per line (optional, reloaded on SIGHUP)
+}
+
+// LinkHealth configures the background checker that finds short URLs with broken destinations
+type LinkHealth struct {
+	Enabled          bool `yaml:"enabled"`           // Run the checker (default: false)
+	Interval         int  `yaml:"interval"`          // Seconds between checks of the same link (default: 86400)
+	Concurrency      int  `yaml:"concurrency"`       // Destinations checked at the same time (default: 4)
+	HostDelayMS      int  `yaml:"host_delay_ms"`     // Minimum milliseconds between requests to the same host (default: 1000)
+	Timeout          int  `yaml:"timeout"`           // Seconds to wait for a destination to respond (default: 10)
+	FailureThreshold int  `yaml:"failure_threshold"` // Checks in a row that must fail before a link is marked broken (default: 3)
+}
+
+// SlugGeneration chooses how slugs are generated for links created without one
+// Namespaces can override it with their own strategy
+type SlugGeneration struct {
+	Strategy string `yaml:"strategy"` // "random" (default), "sequential" or "pronounceable"
+	Length   int    `yaml:"length"`   // Slug length; minimum length for sequential slugs (default: 5 random, 8 pronounceable)
+	Alphabet string `yaml:"alphabet"` // Characters used by random slugs (default: a-z, A-Z and 0-9)
+}
+
 type Config struct {
-	Port                  int      `yaml:"port"`
-	PostgresURI           string   `yaml:"postgres_uri"`
-	SQLitePath            string   `yaml:"sqlite_path"`
-	AvailableShortDomains []string `yaml:"available_short_domains"`
-	AuthProvider          string   `yaml:"auth_provider"` // "external_jwt", "local", or "clerk"
-	EnableSignup          bool     `yaml:"enable_signup"` // Enable user signup (only used when auth_provider is "local")
-	JWT                   *JWT     `yaml:"jwt,omitempty"`
-	Clerk                 *Clerk   `yaml:"clerk,omitempty"`
-	AdminPassword         string   `yaml:"admin_password"`           // Super long password for administrative purposes
-	DashboardDevServerURL string   `yaml:"dashboard_dev_server_url"` // URL for dashboard dev server (optional, for development)
-	LandingDevServerURL   string   `yaml:"landing_dev_server_url"`  // URL for landing page dev server (optional, for development)
+	Port                  int                         `yaml:"port"`
+	PostgresURI           string                      `yaml:"postgres_uri"`
+	SQLitePath            string                      `yaml:"sqlite_path"`
+	AvailableShortDomains []string                    `yaml:"available_short_domains"`
+	AuthProvider          string                      `yaml:"auth_provider"` // "external_jwt", "local", or "clerk"
+	EnableSignup          bool                        `yaml:"enable_signup"` // Enable user signup (only used when auth_provider is "local")
+	JWT                   *JWT                        `yaml:"jwt,omitempty"`
+	Clerk                 *Clerk                      `yaml:"clerk,omitempty"`
+	AdminPassword         string                      `yaml:"admin_password"`           // Super long password for administrative purposes
+	DashboardDevServerURL string                      `yaml:"dashboard_dev_server_url"` // URL for dashboard dev server (optional, for development)
+	LandingDevServerURL   string                      `yaml:"landing_dev_server_url"`   // URL for landing page dev server (optional, for development)
+	DefaultRedirectType   int                         `yaml:"default_redirect_type"`    // HTTP status used for links without their own redirect type: 301, 302, 307 or 308 (default: 301)
+	ExpiredLinkURL        string                      `yaml:"expired_link_url"`         // URL to redirect expired links to (optional, 410 Gone is returned if unset)
+	GeoIPDatabasePath     string                      `yaml:"geoip_database_path"`      // Path to a MaxMind-format .mmdb country database (optional, enables geo rules)
+	RedirectCacheSize     int                         `yaml:"redirect_cache_size"`      // Max cached redirect lookups (default: 10000, negative disables the cache)
+	RedirectCacheTTL      int                         `yaml:"redirect_cache_ttl"`       // Seconds a cached redirect lookup stays valid (default: 60)
+	LandingReservedPaths  []string                    `yaml:"landing_reserved_paths"`   // Path prefixes always served by the landing page, never resolved as short links
+	NotFoundFallbacks     map[string]NotFoundFallback `yaml:"not_found_fallbacks"`      // Per-domain behavior for unknown slugs, keyed by short domain (default: landing page)
+	URLPolicy             URLPolicy                   `yaml:"url_policy"`               // Destination URL validation
+	LinkHealth            LinkHealth                  `yaml:"link_health"`              // Background checks of link destinations
+	SlugGeneration        SlugGeneration              `yaml:"slug_generation"`          // Default slug generation strategy
+	IdempotencyKeyTTL     int                         `yaml:"idempotency_key_ttl"`      // Seconds a repeated Idempotency-Key returns the original response (default: 86400)
+}
+
+// DefaultLandingReservedPaths are the landing page's own routes and static assets
+var DefaultLandingReservedPaths = []string{
+	"/_next",       // Next.js static assets and internal routes
+	"/docs",        // Landing page docs routes
+	"/favicon.ico", // Favicon
+	"/robots.txt",  // Robots.txt
+	"/sitemap.xml", // Sitemap
+}
+
+// IsValidRedirectType checks if the status code is a redirect type supported for short URLs
+func IsValidRedirectType(code int) bool {
+	switch code {
+	case 301, 302, 307, 308:
+		return true
+	}
+	return false
 }
 
 func LoadConfig(configPath string) (*Config, error) {
@@ -39,6 +100,8 @@ func LoadConfig(configPath string) (*Config, error) {
 		SQLitePath:            "db.sqlite",                // default SQLite path
 		AvailableShortDomains: []string{"localhost:3000"}, // default short domains
 		EnableSignup:          false,                      // default signup disabled
+		DefaultRedirectType:   301,                        // default permanent redirect
+		LandingReservedPaths:  DefaultLandingReservedPaths,
 	}
 
 	if configPath == "" {
@@ -64,6 +127,12 @@ func LoadConfig(configPath string) (*Config, error) {
 	if len(config.AvailableShortDomains) == 0 {
 		config.AvailableShortDomains = []string{"localhost:3000"}
 	}
+	if config.DefaultRedirectType == 0 {
+		config.DefaultRedirectType = 301
+	}
+	if config.LandingReservedPaths == nil {
+		config.LandingReservedPaths = DefaultLandingReservedPaths
+	}
 
 	// Validate configuration
 	if err := config.Validate(); err != nil {
@@ -142,5 +211,32 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	// If a default redirect type is provided, it must be a supported redirect status
+	if c.DefaultRedirectType != 0 && !IsValidRedirectType(c.DefaultRedirectType) {
+		return fmt.Errorf("invalid default_redirect_type: %d (must be 301, 302, 307 or 308)", c.DefaultRedirectType)
+	}
+
+	// Link health settings can't be negative; zero uses the default
+	if c.LinkHealth.Interval < 0 || c.LinkHealth.Concurrency < 0 || c.LinkHealth.HostDelayMS < 0 || c.LinkHealth.Timeout < 0 || c.LinkHealth.FailureThreshold < 0 {
+		return fmt.Errorf("link_health.interval, concurrency, host_delay_ms, timeout and failure_threshold must not be negative")
+	}
+
+	// Each not-found fallback must have what its action needs
+	for domain, fallback := range c.NotFoundFallbacks {
+		switch fallback.Action {
+		case "landing", "json":
+		case "redirect":
+			if fallback.URL == "" {
+				return fmt.Errorf("not_found_fallbacks.%s.url is required when action is 'redirect'", domain)
+			}
+		case "html":
+			if fallback.TemplatePath == "" {
+				return fmt.Errorf("not_found_fallbacks.%s.template_path is required when action is 'html'", domain)
+			}
+		default:
+			return fmt.Errorf("invalid not_found_fallbacks.%s.action: %s (must be 'landing', 'redirect', 'html' or 'json')", domain, fallback.Action)
+		}
+	}
+
 	return nil
 }
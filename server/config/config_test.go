@@ -285,3 +285,70 @@ func TestConfig_Validate_ClerkAuth_MissingSecretKey(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "clerk.secret_key is required")
 }
+
+func TestConfig_DefaultRedirectType_Default(t *testing.T) {
+	cfg, err := LoadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, 301, cfg.DefaultRedirectType)
+}
+
+func TestConfig_Validate_DefaultRedirectType(t *testing.T) {
+	for _, code := range []int{0, 301, 302, 307, 308} {
+		cfg := &Config{
+			AuthProvider:        "external_jwt",
+			DefaultRedirectType: code,
+		}
+		assert.NoError(t, cfg.Validate(), "code %d should be valid", code)
+	}
+
+	cfg := &Config{
+		AuthProvider:        "external_jwt",
+		DefaultRedirectType: 200,
+	}
+	err := cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid default_redirect_type")
+}
+
+func TestConfig_LandingReservedPaths_Default(t *testing.T) {
+	cfg, err := LoadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultLandingReservedPaths, cfg.LandingReservedPaths)
+}
+
+func TestConfig_Validate_NotFoundFallbacks(t *testing.T) {
+	cfg := &Config{
+		AuthProvider: "external_jwt",
+		NotFoundFallbacks: map[string]NotFoundFallback{
+			"a.example.com": {Action: "landing"},
+			"b.example.com": {Action: "json"},
+			"c.example.com": {Action: "redirect", URL: "https://example.com/missing"},
+			"d.example.com": {Action: "html", TemplatePath: "/etc/openshortpath/404.html"},
+		},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	invalid := []NotFoundFallback{
+		{Action: "redirect"},
+		{Action: "html"},
+		{Action: "teapot"},
+	}
+	for _, fallback := range invalid {
+		cfg := &Config{
+			AuthProvider:      "external_jwt",
+			NotFoundFallbacks: map[string]NotFoundFallback{"example.com": fallback},
+		}
+		assert.Error(t, cfg.Validate(), "action %q should be invalid", fallback.Action)
+	}
+}
+
+func TestConfig_Validate_LinkHealth(t *testing.T) {
+	cfg := &Config{
+		AuthProvider: "external_jwt",
+		LinkHealth:   LinkHealth{Enabled: true, Interval: 3600, Concurrency: 8, HostDelayMS: 500, Timeout: 5},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.LinkHealth.Concurrency = -1
+	assert.Error(t, cfg.Validate())
+}
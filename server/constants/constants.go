@@ -25,3 +25,6 @@ const PlanPro = "pro"
 const RateLimitTypeIP = "ip"
 const RateLimitTypeUser = "user"
 
+// RateLimitTypeLinkPassword tracks failed unlock attempts on password-protected links, per IP
+const RateLimitTypeLinkPassword = "link_password"
+
@@ -52,6 +52,98 @@ if [ -n "$AVAILABLE_SHORT_DOMAINS" ]; then
     write_yaml_list "available_short_domains" "$AVAILABLE_SHORT_DOMAINS"
 fi
 
+if [ -n "$DEFAULT_REDIRECT_TYPE" ]; then
+    write_yaml_key "default_redirect_type" "$DEFAULT_REDIRECT_TYPE"
+fi
+
+if [ -n "$EXPIRED_LINK_URL" ]; then
+    write_yaml_key "expired_link_url" "$EXPIRED_LINK_URL"
+fi
+
+if [ -n "$GEOIP_DATABASE_PATH" ]; then
+    write_yaml_key "geoip_database_path" "$GEOIP_DATABASE_PATH"
+fi
+
+if [ -n "$REDIRECT_CACHE_SIZE" ]; then
+    write_yaml_key "redirect_cache_size" "$REDIRECT_CACHE_SIZE"
+fi
+
+if [ -n "$REDIRECT_CACHE_TTL" ]; then
+    write_yaml_key "redirect_cache_ttl" "$REDIRECT_CACHE_TTL"
+fi
+
+if [ -n "$LANDING_RESERVED_PATHS" ]; then
+    write_yaml_list "landing_reserved_paths" "$LANDING_RESERVED_PATHS"
+fi
+
+# Write URL policy configuration if any URL policy env var is set
+if [ -n "$URL_ALLOWED_SCHEMES" ] || [ -n "$URL_ALLOW_PRIVATE_ADDRESSES" ] || [ -n "$URL_BLOCKLIST_PATH" ]; then
+    echo "url_policy:" >> "$CONFIG_FILE"
+
+    if [ -n "$URL_ALLOWED_SCHEMES" ]; then
+        echo "  allowed_schemes:" >> "$CONFIG_FILE"
+        echo "$URL_ALLOWED_SCHEMES" | tr ',' '\n' | sed 's/^/    - /' >> "$CONFIG_FILE"
+    fi
+
+    if [ -n "$URL_ALLOW_PRIVATE_ADDRESSES" ]; then
+        write_yaml_key "  allow_private_addresses" "$URL_ALLOW_PRIVATE_ADDRESSES"
+    fi
+
+    if [ -n "$URL_BLOCKLIST_PATH" ]; then
+        write_yaml_key "  blocklist_path" "$URL_BLOCKLIST_PATH"
+    fi
+fi
+
+# Write link health configuration if any link health env var is set
+if [ -n "$LINK_HEALTH_ENABLED" ] || [ -n "$LINK_HEALTH_INTERVAL" ] || [ -n "$LINK_HEALTH_CONCURRENCY" ] || [ -n "$LINK_HEALTH_HOST_DELAY_MS" ] || [ -n "$LINK_HEALTH_TIMEOUT" ] || [ -n "$LINK_HEALTH_FAILURE_THRESHOLD" ]; then
+    echo "link_health:" >> "$CONFIG_FILE"
+
+    if [ -n "$LINK_HEALTH_ENABLED" ]; then
+        write_yaml_key "  enabled" "$LINK_HEALTH_ENABLED"
+    fi
+
+    if [ -n "$LINK_HEALTH_INTERVAL" ]; then
+        write_yaml_key "  interval" "$LINK_HEALTH_INTERVAL"
+    fi
+
+    if [ -n "$LINK_HEALTH_CONCURRENCY" ]; then
+        write_yaml_key "  concurrency" "$LINK_HEALTH_CONCURRENCY"
+    fi
+
+    if [ -n "$LINK_HEALTH_HOST_DELAY_MS" ]; then
+        write_yaml_key "  host_delay_ms" "$LINK_HEALTH_HOST_DELAY_MS"
+    fi
+
+    if [ -n "$LINK_HEALTH_TIMEOUT" ]; then
+        write_yaml_key "  timeout" "$LINK_HEALTH_TIMEOUT"
+    fi
+
+    if [ -n "$LINK_HEALTH_FAILURE_THRESHOLD" ]; then
+        write_yaml_key "  failure_threshold" "$LINK_HEALTH_FAILURE_THRESHOLD"
+    fi
+fi
+
+# Write slug generation configuration if any slug env var is set
+if [ -n "$SLUG_STRATEGY" ] || [ -n "$SLUG_LENGTH" ] || [ -n "$SLUG_ALPHABET" ]; then
+    echo "slug_generation:" >> "$CONFIG_FILE"
+
+    if [ -n "$SLUG_STRATEGY" ]; then
+        write_yaml_key "  strategy" "$SLUG_STRATEGY"
+    fi
+
+    if [ -n "$SLUG_LENGTH" ]; then
+        write_yaml_key "  length" "$SLUG_LENGTH"
+    fi
+
+    if [ -n "$SLUG_ALPHABET" ]; then
+        write_yaml_key "  alphabet" "$SLUG_ALPHABET"
+    fi
+fi
+
+if [ -n "$IDEMPOTENCY_KEY_TTL" ]; then
+    write_yaml_key "idempotency_key_ttl" "$IDEMPOTENCY_KEY_TTL"
+fi
+
 if [ -n "$AUTH_PROVIDER" ]; then
     write_yaml_key "auth_provider" "$AUTH_PROVIDER"
 fi
@@ -48,6 +48,7 @@ type UserResponse struct {
 	RateLimitPerHour  int    `json:"rate_limit_per_hour,omitempty"`
 	RateLimitRemaining int   `json:"rate_limit_remaining,omitempty"`
 	RateLimitReset     string `json:"rate_limit_reset,omitempty"`
+	DedupLinks         *bool  `json:"dedup_links,omitempty"` // Only included in the user's own details
 	CreatedAt         string `json:"created_at"`
 	UpdatedAt         string `json:"updated_at"`
 }
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"openshortpath/server/models"
+	"openshortpath/server/utils"
+)
+
+// blockedAppURLSchemes contains schemes that are never accepted as app URLs because
+// browsers execute or render them in place instead of handing them to an app
+var blockedAppURLSchemes = map[string]bool{
+	"javascript": true,
+	"vbscript":   true,
+	"data":       true,
+	"file":       true,
+	"blob":       true,
+	"about":      true,
+}
+
+// deepLinkAppOpenTimeoutMs is how long the bridge page waits for the app to open before falling back
+const deepLinkAppOpenTimeoutMs = 1500
+
+// deepLinkBridgeTemplate is the page that tries to open the app and falls back to the web URL
+var deepLinkBridgeTemplate = template.Must(template.New("deep-link").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Opening app…</title>
+<style>
+body{font-family:system-ui,-apple-system,sans-serif;background:#f5f5f5;display:flex;align-items:center;justify-content:center;min-height:100vh;margin:0;text-align:center}
+a{color:#1a56db}
+</style>
+</head>
+<body>
+<div>
+<p>Opening the app…</p>
+<p><a href="{{.AppURL}}">Open in app</a> · <a href="{{.FallbackURL}}">Continue in browser</a></p>
+</div>
+<script>
+(function(){
+var appURL = {{.AppURL}};
+var fallbackURL = {{.FallbackURL}};
+var timer = setTimeout(function(){ window.location.replace(fallbackURL); }, {{.Timeout}});
+document.addEventListener("visibilitychange", function(){ if (document.hidden) { clearTimeout(timer); } });
+window.location.href = appURL;
+})();
+</script>
+</body>
+</html>
+`))
+
+// validateAppURL checks a deep-link app URL
+// Custom app schemes (myapp://path) are allowed, but schemes browsers execute in place are not
+func validateAppURL(field, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Scheme == "" || (parsed.Host == "" && parsed.Opaque == "" && parsed.Path == "") {
+		return fmt.Errorf("%s must be an absolute URL with a scheme", field)
+	}
+	if blockedAppURLSchemes[strings.ToLower(parsed.Scheme)] {
+		return fmt.Errorf("%s must not use the %s scheme", field, parsed.Scheme)
+	}
+	return nil
+}
+
+// validateDeepLink checks the app URLs and fallback URL of a deep link
+// Only the app URLs may use non-HTTP schemes; the fallback must be a web URL
+func validateDeepLink(deepLink models.DeepLink) error {
+	if deepLink.IOSURL != "" {
+		if err := validateAppURL("deep_link.ios_url", deepLink.IOSURL); err != nil {
+			return err
+		}
+	}
+	if deepLink.AndroidURL != "" {
+		if err := validateAppURL("deep_link.android_url", deepLink.AndroidURL); err != nil {
+			return err
+		}
+	}
+	if deepLink.FallbackURL != "" {
+		parsed, err := url.Parse(deepLink.FallbackURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("deep_link.fallback_url must be an http or https URL")
+		}
+		if deepLink.IsEmpty() {
+			return fmt.Errorf("deep_link.fallback_url requires deep_link.ios_url or deep_link.android_url")
+		}
+	}
+	return nil
+}
+
+// deepLinkAppURL returns the app URL to try for the visitor's platform, or "" if there is none
+func deepLinkAppURL(deepLink models.DeepLink, userAgent utils.UserAgentInfo) string {
+	switch userAgent.Platform {
+	case utils.PlatformIOS:
+		return deepLink.IOSURL
+	case utils.PlatformAndroid:
+		return deepLink.AndroidURL
+	}
+	return ""
+}
+
+// renderDeepLinkBridge writes the bridge page that opens appURL and falls back to fallbackURL
+func renderDeepLinkBridge(c *gin.Context, appURL, fallbackURL string) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+	deepLinkBridgeTemplate.Execute(c.Writer, gin.H{
+		// App URLs were validated against blockedAppURLSchemes when they were saved
+		"AppURL":      template.URL(appURL),
+		"FallbackURL": fallbackURL,
+		"Timeout":     deepLinkAppOpenTimeoutMs,
+	})
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"openshortpath/server/config"
+	"openshortpath/server/models"
+)
+
+func TestValidateDeepLink(t *testing.T) {
+	tests := []struct {
+		name     string
+		deepLink models.DeepLink
+		valid    bool
+	}{
+		{"empty", models.DeepLink{}, true},
+		{"custom scheme", models.DeepLink{IOSURL: "myapp://product/42"}, true},
+		{"universal link", models.DeepLink{AndroidURL: "https://app.example.com/product/42"}, true},
+		{"android intent", models.DeepLink{AndroidURL: "intent://product/42#Intent;scheme=myapp;package=com.example.app;end"}, true},
+		{"with fallback", models.DeepLink{IOSURL: "myapp://product/42", FallbackURL: "https://example.com/product/42"}, true},
+		{"javascript scheme", models.DeepLink{IOSURL: "javascript:alert(1)"}, false},
+		{"data scheme", models.DeepLink{AndroidURL: "data:text/html,hi"}, false},
+		{"relative url", models.DeepLink{IOSURL: "/product/42"}, false},
+		{"non-http fallback", models.DeepLink{IOSURL: "myapp://product/42", FallbackURL: "myapp://home"}, false},
+		{"fallback without app url", models.DeepLink{FallbackURL: "https://example.com"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDeepLink(tt.deepLink)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestShortLinkRouter_Handle_DeepLinkBridge(t *testing.T) {
+	// Setup
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	cfg := &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	}
+
+	handler := NewRedirectHandler(db, cfg)
+
+	id := uuid.New().String()
+	now := time.Now()
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "abc123").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "namespace_id", "deep_link_ios_url", "deep_link_fallback_url", "created_at", "updated_at"}).
+			AddRow(id, "example.com", "abc123", "https://example.com/target", "", nil, "myapp://product/42", "https://example.com/product/42", now, now))
+	expectNoDeviceRules(mock)
+
+	// Setup Gin context
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/abc123", nil)
+	c.Request.Host = "example.com"
+	c.Request.URL.Path = "/abc123"
+	c.Request.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1")
+
+	// Execute
+	newTestRouterFor(handler).Handle(c)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `href="myapp://product/42"`)
+	assert.Contains(t, w.Body.String(), `href="https://example.com/product/42"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortLinkRouter_Handle_DeepLinkDesktop(t *testing.T) {
+	// Setup
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	cfg := &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	}
+
+	handler := NewRedirectHandler(db, cfg)
+
+	id := uuid.New().String()
+	now := time.Now()
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "abc123").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "namespace_id", "deep_link_ios_url", "created_at", "updated_at"}).
+			AddRow(id, "example.com", "abc123", "https://example.com/target", "", nil, "myapp://product/42", now, now))
+	expectNoDeviceRules(mock)
+
+	// Setup Gin context
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/abc123", nil)
+	c.Request.Host = "example.com"
+	c.Request.URL.Path = "/abc123"
+
+	// Execute
+	newTestRouterFor(handler).Handle(c)
+
+	// Assert
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "https://example.com/target", w.Header().Get("Location"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"openshortpath/server/models"
+	"openshortpath/server/services"
+	"openshortpath/server/utils"
+)
+
+type DeviceRulesHandler struct {
+	db            *gorm.DB
+	urlPolicy     *services.URLPolicy
+	redirectCache *services.RedirectCache
+}
+
+type CreateDeviceRuleRequest struct {
+	Platform string `json:"platform" binding:"required"`
+	URL      string `json:"url" binding:"required"`
+}
+
+type UpdateDeviceRuleRequest struct {
+	Platform string `json:"platform,omitempty"`
+	URL      string `json:"url,omitempty"`
+}
+
+func NewDeviceRulesHandler(db *gorm.DB) *DeviceRulesHandler {
+	return &DeviceRulesHandler{
+		db: db,
+	}
+}
+
+// SetURLPolicy sets the policy rule URLs must pass
+// Without a policy rule URLs are stored unchecked
+func (h *DeviceRulesHandler) SetURLPolicy(policy *services.URLPolicy) {
+	h.urlPolicy = policy
+}
+
+// SetRedirectCache sets the redirect cache to invalidate when rules change
+// Redirects keep a link's rules with its cached lookup
+func (h *DeviceRulesHandler) SetRedirectCache(cache *services.RedirectCache) {
+	h.redirectCache = cache
+}
+
+// normalizePlatform lowercases a platform name
+// Returns false if device rules cannot target the platform
+func normalizePlatform(platform string) (string, bool) {
+	platform = strings.ToLower(strings.TrimSpace(platform))
+	return platform, utils.TargetablePlatforms[platform]
+}
+
+// ListDeviceRules handles GET /api/v1/short-urls/:id/device-rules
+func (h *DeviceRulesHandler) ListDeviceRules(c *gin.Context) {
+	shortURL, ok := loadOwnedShortURL(c, h.db)
+	if !ok {
+		return
+	}
+
+	rules := []models.DeviceRule{}
+	if err := h.db.Where("short_url_id = ?", shortURL.ID).Order("platform ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"device_rules": rules,
+	})
+}
+
+// CreateDeviceRule handles POST /api/v1/short-urls/:id/device-rules
+func (h *DeviceRulesHandler) CreateDeviceRule(c *gin.Context) {
+	shortURL, ok := loadOwnedShortURL(c, h.db)
+	if !ok {
+		return
+	}
+
+	// Parse request body
+	var req CreateDeviceRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	platform, valid := normalizePlatform(req.Platform)
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "platform must be one of: ios, android, windows, macos, linux",
+		})
+		return
+	}
+
+	if !applyURLPolicy(c, h.urlPolicy, nil, policyURL{"url", &req.URL}) {
+		return
+	}
+
+	// Check for an existing rule for the same platform
+	var existing models.DeviceRule
+	result := h.db.Where("short_url_id = ? AND platform = ?", shortURL.ID, platform).First(&existing)
+	if result.Error == nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": fmt.Sprintf("A device rule for platform '%s' already exists", platform),
+		})
+		return
+	}
+	if result.Error != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"details": result.Error.Error(),
+		})
+		return
+	}
+
+	rule := models.DeviceRule{
+		ShortURLID: shortURL.ID,
+		Platform:   platform,
+		URL:        req.URL,
+	}
+
+	if err := h.db.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to create device rule",
+			"details": err.Error(),
+		})
+		return
+	}
+	h.redirectCache.InvalidateShortURL(shortURL)
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateDeviceRule handles PUT /api/v1/short-urls/:id/device-rules/:rule_id
+func (h *DeviceRulesHandler) UpdateDeviceRule(c *gin.Context) {
+	shortURL, ok := loadOwnedShortURL(c, h.db)
+	if !ok {
+		return
+	}
+
+	ruleID := c.Param("rule_id")
+	var rule models.DeviceRule
+	result := h.db.Where("id = ? AND short_url_id = ?", ruleID, shortURL.ID).First(&rule)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Device rule not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"details": result.Error.Error(),
+		})
+		return
+	}
+
+	// Parse request body
+	var req UpdateDeviceRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if !applyURLPolicy(c, h.urlPolicy, nil, policyURL{"url", &req.URL}) {
+		return
+	}
+
+	// Update fields if provided
+	updateFields := make(map[string]interface{})
+
+	if req.URL != "" {
+		updateFields["url"] = req.URL
+	}
+
+	if req.Platform != "" {
+		platform, valid := normalizePlatform(req.Platform)
+		if !valid {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "platform must be one of: ios, android, windows, macos, linux",
+			})
+			return
+		}
+
+		// Check for conflict with another rule for the same platform
+		var existing models.DeviceRule
+		conflictResult := h.db.Where("short_url_id = ? AND platform = ? AND id != ?", shortURL.ID, platform, rule.ID).First(&existing)
+		if conflictResult.Error == nil {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": fmt.Sprintf("A device rule for platform '%s' already exists", platform),
+			})
+			return
+		}
+		if conflictResult.Error != gorm.ErrRecordNotFound {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"details": conflictResult.Error.Error(),
+			})
+			return
+		}
+		updateFields["platform"] = platform
+	}
+
+	// If no fields to update, return the existing record
+	if len(updateFields) == 0 {
+		c.JSON(http.StatusOK, rule)
+		return
+	}
+
+	if err := h.db.Model(&rule).Updates(updateFields).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to update device rule",
+			"details": err.Error(),
+		})
+		return
+	}
+	h.redirectCache.InvalidateShortURL(shortURL)
+
+	// Reload the record to get updated values
+	if err := h.db.Where("id = ?", rule.ID).First(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to reload updated device rule",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteDeviceRule handles DELETE /api/v1/short-urls/:id/device-rules/:rule_id
+func (h *DeviceRulesHandler) DeleteDeviceRule(c *gin.Context) {
+	shortURL, ok := loadOwnedShortURL(c, h.db)
+	if !ok {
+		return
+	}
+
+	ruleID := c.Param("rule_id")
+	result := h.db.Where("id = ? AND short_url_id = ?", ruleID, shortURL.ID).Delete(&models.DeviceRule{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to delete device rule",
+			"details": result.Error.Error(),
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Device rule not found",
+		})
+		return
+	}
+	h.redirectCache.InvalidateShortURL(shortURL)
+
+	c.AbortWithStatus(http.StatusNoContent)
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"openshortpath/server/constants"
+	"openshortpath/server/models"
+	"openshortpath/server/services"
+)
+
+func TestDeviceRulesHandler_CreateDeviceRule_Success(t *testing.T) {
+	// Setup
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewDeviceRulesHandler(db)
+
+	userID := "user123"
+	id := uuid.New().String()
+
+	expectOwnedShortURL(mock, id, userID)
+	mock.ExpectQuery(`SELECT (.+) FROM "device_rules"`).
+		WithArgs(id, "ios").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "device_rules"`).
+		WithArgs(sqlmock.AnyArg(), id, "ios", "https://apps.apple.com/app/id123", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// Setup Gin context with user_id
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, userID)
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	body, _ := json.Marshal(CreateDeviceRuleRequest{Platform: "iOS", URL: "https://apps.apple.com/app/id123"})
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/short-urls/"+id+"/device-rules", bytes.NewBuffer(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	// Execute
+	handler.CreateDeviceRule(c)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response models.DeviceRule
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "ios", response.Platform)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeviceRulesHandler_CreateDeviceRule_InvalidPlatform(t *testing.T) {
+	// Setup
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewDeviceRulesHandler(db)
+
+	userID := "user123"
+	id := uuid.New().String()
+
+	expectOwnedShortURL(mock, id, userID)
+
+	// Setup Gin context with user_id
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, userID)
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	body, _ := json.Marshal(CreateDeviceRuleRequest{Platform: "blackberry", URL: "https://example.com"})
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/short-urls/"+id+"/device-rules", bytes.NewBuffer(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	// Execute
+	handler.CreateDeviceRule(c)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeviceRulesHandler_DeleteDeviceRule_Success(t *testing.T) {
+	// Setup
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewDeviceRulesHandler(db)
+	cache := services.NewRedirectCache(10, time.Minute)
+	handler.SetRedirectCache(cache)
+
+	userID := "user123"
+	id := uuid.New().String()
+	ruleID := uuid.New().String()
+	cacheKey := services.RedirectCacheKey{Host: "example.com", Slug: "abc123"}
+	cache.Set(cacheKey, services.RedirectCacheEntry{ShortURL: &models.ShortURL{ID: id}})
+
+	expectOwnedShortURL(mock, id, userID)
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "device_rules"`).
+		WithArgs(ruleID, id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Setup Gin context with user_id
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, userID)
+	c.Params = gin.Params{{Key: "id", Value: id}, {Key: "rule_id", Value: ruleID}}
+	c.Request = httptest.NewRequest(http.MethodDelete, "/api/v1/short-urls/"+id+"/device-rules/"+ruleID, nil)
+
+	// Execute
+	handler.DeleteDeviceRule(c)
+
+	// Assert
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// The link's cached lookup is dropped so redirects stop using the rule
+	_, cached := cache.Get(cacheKey)
+	assert.False(t, cached)
+}
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"openshortpath/server/constants"
+	"openshortpath/server/models"
+	"openshortpath/server/services"
+)
+
+type GeoRulesHandler struct {
+	db            *gorm.DB
+	urlPolicy     *services.URLPolicy
+	redirectCache *services.RedirectCache
+}
+
+type CreateGeoRuleRequest struct {
+	CountryCode string `json:"country_code" binding:"required"`
+	URL         string `json:"url" binding:"required"`
+}
+
+type UpdateGeoRuleRequest struct {
+	CountryCode string `json:"country_code,omitempty"`
+	URL         string `json:"url,omitempty"`
+}
+
+func NewGeoRulesHandler(db *gorm.DB) *GeoRulesHandler {
+	return &GeoRulesHandler{
+		db: db,
+	}
+}
+
+// SetURLPolicy sets the policy rule URLs must pass
+// Without a policy rule URLs are stored unchecked
+func (h *GeoRulesHandler) SetURLPolicy(policy *services.URLPolicy) {
+	h.urlPolicy = policy
+}
+
+// SetRedirectCache sets the redirect cache to invalidate when rules change
+// Redirects keep a link's rules with its cached lookup
+func (h *GeoRulesHandler) SetRedirectCache(cache *services.RedirectCache) {
+	h.redirectCache = cache
+}
+
+var countryCodePattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// normalizeCountryCode uppercases an ISO 3166-1 alpha-2 country code
+// Returns false if the code is not two letters
+func normalizeCountryCode(code string) (string, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	return code, countryCodePattern.MatchString(code)
+}
+
+// loadOwnedShortURL finds the short URL from the :id parameter that belongs to the authenticated user
+// Returns false if the response has already been written (unauthorized, not found or database error)
+func loadOwnedShortURL(c *gin.Context, db *gorm.DB) (*models.ShortURL, bool) {
+	// Get user ID from context
+	userIDValue, exists := c.Get(constants.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User ID not found in context",
+		})
+		return nil, false
+	}
+
+	userID, ok := userIDValue.(string)
+	if !ok || userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid user ID in context",
+		})
+		return nil, false
+	}
+
+	// Get ID from URL parameter
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "ID parameter is required",
+		})
+		return nil, false
+	}
+
+	// Find the ShortURL by ID and user_id
+	var shortURL models.ShortURL
+	result := db.Where("id = ? AND user_id = ?", id, userID).First(&shortURL)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Short URL not found",
+			})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"details": result.Error.Error(),
+		})
+		return nil, false
+	}
+
+	return &shortURL, true
+}
+
+// ListGeoRules handles GET /api/v1/short-urls/:id/geo-rules
+func (h *GeoRulesHandler) ListGeoRules(c *gin.Context) {
+	shortURL, ok := loadOwnedShortURL(c, h.db)
+	if !ok {
+		return
+	}
+
+	rules := []models.GeoRule{}
+	if err := h.db.Where("short_url_id = ?", shortURL.ID).Order("country_code ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"geo_rules": rules,
+	})
+}
+
+// CreateGeoRule handles POST /api/v1/short-urls/:id/geo-rules
+func (h *GeoRulesHandler) CreateGeoRule(c *gin.Context) {
+	shortURL, ok := loadOwnedShortURL(c, h.db)
+	if !ok {
+		return
+	}
+
+	// Parse request body
+	var req CreateGeoRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	countryCode, valid := normalizeCountryCode(req.CountryCode)
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "country_code must be a two-letter ISO 3166-1 alpha-2 code",
+		})
+		return
+	}
+
+	if !applyURLPolicy(c, h.urlPolicy, nil, policyURL{"url", &req.URL}) {
+		return
+	}
+
+	// Check for an existing rule for the same country
+	var existing models.GeoRule
+	result := h.db.Where("short_url_id = ? AND country_code = ?", shortURL.ID, countryCode).First(&existing)
+	if result.Error == nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": fmt.Sprintf("A geo rule for country '%s' already exists", countryCode),
+		})
+		return
+	}
+	if result.Error != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"details": result.Error.Error(),
+		})
+		return
+	}
+
+	rule := models.GeoRule{
+		ShortURLID:  shortURL.ID,
+		CountryCode: countryCode,
+		URL:         req.URL,
+	}
+
+	if err := h.db.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to create geo rule",
+			"details": err.Error(),
+		})
+		return
+	}
+	h.redirectCache.InvalidateShortURL(shortURL)
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateGeoRule handles PUT /api/v1/short-urls/:id/geo-rules/:rule_id
+func (h *GeoRulesHandler) UpdateGeoRule(c *gin.Context) {
+	shortURL, ok := loadOwnedShortURL(c, h.db)
+	if !ok {
+		return
+	}
+
+	ruleID := c.Param("rule_id")
+	var rule models.GeoRule
+	result := h.db.Where("id = ? AND short_url_id = ?", ruleID, shortURL.ID).First(&rule)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Geo rule not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"details": result.Error.Error(),
+		})
+		return
+	}
+
+	// Parse request body
+	var req UpdateGeoRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if !applyURLPolicy(c, h.urlPolicy, nil, policyURL{"url", &req.URL}) {
+		return
+	}
+
+	// Update fields if provided
+	updateFields := make(map[string]interface{})
+
+	if req.URL != "" {
+		updateFields["url"] = req.URL
+	}
+
+	if req.CountryCode != "" {
+		countryCode, valid := normalizeCountryCode(req.CountryCode)
+		if !valid {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "country_code must be a two-letter ISO 3166-1 alpha-2 code",
+			})
+			return
+		}
+
+		// Check for conflict with another rule for the same country
+		var existing models.GeoRule
+		conflictResult := h.db.Where("short_url_id = ? AND country_code = ? AND id != ?", shortURL.ID, countryCode, rule.ID).First(&existing)
+		if conflictResult.Error == nil {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": fmt.Sprintf("A geo rule for country '%s' already exists", countryCode),
+			})
+			return
+		}
+		if conflictResult.Error != gorm.ErrRecordNotFound {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"details": conflictResult.Error.Error(),
+			})
+			return
+		}
+		updateFields["country_code"] = countryCode
+	}
+
+	// If no fields to update, return the existing record
+	if len(updateFields) == 0 {
+		c.JSON(http.StatusOK, rule)
+		return
+	}
+
+	if err := h.db.Model(&rule).Updates(updateFields).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to update geo rule",
+			"details": err.Error(),
+		})
+		return
+	}
+	h.redirectCache.InvalidateShortURL(shortURL)
+
+	// Reload the record to get updated values
+	if err := h.db.Where("id = ?", rule.ID).First(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to reload updated geo rule",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteGeoRule handles DELETE /api/v1/short-urls/:id/geo-rules/:rule_id
+func (h *GeoRulesHandler) DeleteGeoRule(c *gin.Context) {
+	shortURL, ok := loadOwnedShortURL(c, h.db)
+	if !ok {
+		return
+	}
+
+	ruleID := c.Param("rule_id")
+	result := h.db.Where("id = ? AND short_url_id = ?", ruleID, shortURL.ID).Delete(&models.GeoRule{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to delete geo rule",
+			"details": result.Error.Error(),
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Geo rule not found",
+		})
+		return
+	}
+	h.redirectCache.InvalidateShortURL(shortURL)
+
+	c.AbortWithStatus(http.StatusNoContent)
+}
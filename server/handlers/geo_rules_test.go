@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"openshortpath/server/constants"
+	"openshortpath/server/models"
+	"openshortpath/server/services"
+)
+
+func expectOwnedShortURL(mock sqlmock.Sqlmock, id, userID string) {
+	now := time.Now()
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs(id, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "created_at", "updated_at"}).
+			AddRow(id, "example.com", "abc123", "https://example.com/target", userID, now, now))
+}
+
+func TestGeoRulesHandler_ListGeoRules_Success(t *testing.T) {
+	// Setup
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewGeoRulesHandler(db)
+
+	userID := "user123"
+	id := uuid.New().String()
+	now := time.Now()
+
+	expectOwnedShortURL(mock, id, userID)
+	mock.ExpectQuery(`SELECT (.+) FROM "geo_rules" WHERE short_url_id = \$1 ORDER BY country_code ASC`).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "short_url_id", "country_code", "url", "created_at", "updated_at"}).
+			AddRow(uuid.New().String(), id, "DE", "https://example.de", now, now).
+			AddRow(uuid.New().String(), id, "FR", "https://example.fr", now, now))
+
+	// Setup Gin context with user_id
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, userID)
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/short-urls/"+id+"/geo-rules", nil)
+
+	// Execute
+	handler.ListGeoRules(c)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		GeoRules []models.GeoRule `json:"geo_rules"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Len(t, response.GeoRules, 2)
+	assert.Equal(t, "DE", response.GeoRules[0].CountryCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGeoRulesHandler_CreateGeoRule_Success(t *testing.T) {
+	// Setup
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewGeoRulesHandler(db)
+	cache := services.NewRedirectCache(10, time.Minute)
+	handler.SetRedirectCache(cache)
+
+	userID := "user123"
+	id := uuid.New().String()
+	cacheKey := services.RedirectCacheKey{Host: "example.com", Slug: "abc123"}
+	cache.Set(cacheKey, services.RedirectCacheEntry{ShortURL: &models.ShortURL{ID: id}})
+
+	expectOwnedShortURL(mock, id, userID)
+	mock.ExpectQuery(`SELECT (.+) FROM "geo_rules"`).
+		WithArgs(id, "DE").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "geo_rules"`).
+		WithArgs(sqlmock.AnyArg(), id, "DE", "https://example.de", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// Setup Gin context with user_id
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, userID)
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	body, _ := json.Marshal(CreateGeoRuleRequest{CountryCode: "de", URL: "https://example.de"})
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/short-urls/"+id+"/geo-rules", bytes.NewBuffer(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	// Execute
+	handler.CreateGeoRule(c)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response models.GeoRule
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "DE", response.CountryCode)
+	assert.Equal(t, id, response.ShortURLID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// The link's cached lookup is dropped so redirects see the new rule
+	_, cached := cache.Get(cacheKey)
+	assert.False(t, cached)
+}
+
+func TestGeoRulesHandler_CreateGeoRule_InvalidCountry(t *testing.T) {
+	// Setup
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewGeoRulesHandler(db)
+
+	userID := "user123"
+	id := uuid.New().String()
+
+	expectOwnedShortURL(mock, id, userID)
+
+	// Setup Gin context with user_id
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, userID)
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	body, _ := json.Marshal(CreateGeoRuleRequest{CountryCode: "Germany", URL: "https://example.de"})
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/short-urls/"+id+"/geo-rules", bytes.NewBuffer(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	// Execute
+	handler.CreateGeoRule(c)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGeoRulesHandler_CreateGeoRule_URLPolicyRejected(t *testing.T) {
+	// Setup
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewGeoRulesHandler(db)
+	handler.SetURLPolicy(newTestURLPolicy(t))
+
+	userID := "user123"
+	id := uuid.New().String()
+
+	expectOwnedShortURL(mock, id, userID)
+
+	// Setup Gin context with user_id
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, userID)
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	body, _ := json.Marshal(CreateGeoRuleRequest{CountryCode: "DE", URL: "http://192.168.0.1/admin"})
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/short-urls/"+id+"/geo-rules", bytes.NewBuffer(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	// Execute
+	handler.CreateGeoRule(c)
+
+	// Assert the rule is rejected before it is stored
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, services.URLPolicyPrivateAddress, response["code"])
+	assert.Equal(t, "url", response["field"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGeoRulesHandler_CreateGeoRule_Conflict(t *testing.T) {
+	// Setup
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewGeoRulesHandler(db)
+
+	userID := "user123"
+	id := uuid.New().String()
+	now := time.Now()
+
+	expectOwnedShortURL(mock, id, userID)
+	mock.ExpectQuery(`SELECT (.+) FROM "geo_rules"`).
+		WithArgs(id, "DE").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "short_url_id", "country_code", "url", "created_at", "updated_at"}).
+			AddRow(uuid.New().String(), id, "DE", "https://example.de", now, now))
+
+	// Setup Gin context with user_id
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, userID)
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	body, _ := json.Marshal(CreateGeoRuleRequest{CountryCode: "DE", URL: "https://example.de/other"})
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/short-urls/"+id+"/geo-rules", bytes.NewBuffer(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	// Execute
+	handler.CreateGeoRule(c)
+
+	// Assert
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGeoRulesHandler_DeleteGeoRule_NotFound(t *testing.T) {
+	// Setup
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewGeoRulesHandler(db)
+
+	userID := "user123"
+	id := uuid.New().String()
+	ruleID := uuid.New().String()
+
+	expectOwnedShortURL(mock, id, userID)
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "geo_rules"`).
+		WithArgs(ruleID, id).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	// Setup Gin context with user_id
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, userID)
+	c.Params = gin.Params{{Key: "id", Value: id}, {Key: "rule_id", Value: ruleID}}
+	c.Request = httptest.NewRequest(http.MethodDelete, "/api/v1/short-urls/"+id+"/geo-rules/"+ruleID, nil)
+
+	// Execute
+	handler.DeleteGeoRule(c)
+
+	// Assert
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
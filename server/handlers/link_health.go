@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+
+	"gorm.io/gorm"
+
+	"openshortpath/server/models"
+)
+
+// validateHealthFallbackURL checks that a health fallback URL, if set, is an absolute web URL
+func validateHealthFallbackURL(fallbackURL string) error {
+	if fallbackURL == "" {
+		return nil
+	}
+	parsed, err := url.Parse(fallbackURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("health_fallback_url must be an http or https URL")
+	}
+	return nil
+}
+
+// resetLinkHealth adds the column updates that mark a link's destination as not yet checked
+func resetLinkHealth(updateFields map[string]interface{}) {
+	updateFields["health_status"] = models.LinkHealthUnchecked
+	updateFields["health_status_code"] = 0
+	updateFields["health_latency_ms"] = 0
+	updateFields["health_checked_at"] = nil
+	updateFields["health_error"] = ""
+	updateFields["health_failures"] = 0
+}
+
+// filterByHealth narrows a short URL query to links with the given health status
+// health is "broken", "healthy" or "unchecked"; false is returned for anything else
+func filterByHealth(query *gorm.DB, health string) (*gorm.DB, bool) {
+	switch health {
+	case models.LinkHealthBroken, models.LinkHealthHealthy:
+		return query.Where("health_status = ?", health), true
+	case "unchecked":
+		return query.Where("health_status = ? OR health_status IS NULL", models.LinkHealthUnchecked), true
+	default:
+		return query, false
+	}
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"openshortpath/server/config"
+	"openshortpath/server/constants"
+	"openshortpath/server/models"
+)
+
+func TestValidateHealthFallbackURL(t *testing.T) {
+	assert.NoError(t, validateHealthFallbackURL(""))
+	assert.NoError(t, validateHealthFallbackURL("https://example.com/archived"))
+	assert.Error(t, validateHealthFallbackURL("/archived"))
+	assert.Error(t, validateHealthFallbackURL("javascript:alert(1)"))
+}
+
+func TestShortLinkRouter_Handle_HealthFallback(t *testing.T) {
+	tests := []struct {
+		name             string
+		healthStatus     string
+		fallbackURL      string
+		expectedLocation string
+	}{
+		{"broken with fallback", models.LinkHealthBroken, "https://example.com/archived", "https://example.com/archived"},
+		{"broken without fallback", models.LinkHealthBroken, "", "https://example.com/target"},
+		{"healthy with fallback", models.LinkHealthHealthy, "https://example.com/archived", "https://example.com/target"},
+		{"unchecked with fallback", models.LinkHealthUnchecked, "https://example.com/archived", "https://example.com/target"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, sqlDB := setupTestDB(t)
+			defer sqlDB.Close()
+
+			cfg := &config.Config{
+				AvailableShortDomains: []string{"example.com"},
+			}
+			handler := NewRedirectHandler(db, cfg)
+
+			now := time.Now()
+			mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+				WithArgs("example.com", "abc123").
+				WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "namespace_id", "redirect_type", "health_status", "health_fallback_url", "created_at", "updated_at"}).
+					AddRow(uuid.New().String(), "example.com", "abc123", "https://example.com/target", "", nil, 302, tt.healthStatus, tt.fallbackURL, now, now))
+			expectNoDeviceRules(mock)
+
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/abc123", nil)
+			c.Request.Host = "example.com"
+
+			newTestRouterFor(handler).Handle(c)
+
+			assert.Equal(t, http.StatusFound, w.Code)
+			assert.Equal(t, tt.expectedLocation, w.Header().Get("Location"))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestShortURLsHandler_List_HealthFilter(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewShortURLsHandler(db, &config.Config{})
+
+	userID := "user123"
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "short_urls" WHERE user_id = \$1 AND health_status = \$2`).
+		WithArgs(userID, models.LinkHealthBroken).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls" WHERE user_id = \$1 AND health_status = \$2`).
+		WithArgs(userID, models.LinkHealthBroken).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "health_status", "health_status_code", "health_checked_at", "created_at", "updated_at"}).
+			AddRow(uuid.New().String(), "example.com", "old", "https://example.com/gone", userID, models.LinkHealthBroken, 404, now, now, now))
+
+	expectLoadShortURLTags(mock)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, userID)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/short-urls?health=broken", nil)
+
+	handler.List(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response ListResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 1, len(response.URLs))
+	assert.Equal(t, 404, response.URLs[0].Health.StatusCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortURLsHandler_List_InvalidHealthFilter(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewShortURLsHandler(db, &config.Config{})
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, "user123")
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/short-urls?health=sick", nil)
+
+	handler.List(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortURLsHandler_Update_ResetsHealthFailures(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewShortURLsHandler(db, &config.Config{AvailableShortDomains: []string{"example.com"}})
+
+	userID := "user123"
+	id := uuid.New().String()
+	now := time.Now()
+	columns := []string{"id", "domain", "slug", "url", "user_id", "health_status", "health_failures", "created_at", "updated_at"}
+
+	// The old destination is part way through a failure streak
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs(id, userID).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(id, "example.com", "abc123", "https://example.com/old", userID, models.LinkHealthHealthy, 2, now, now))
+
+	// The new destination starts with no failures
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "short_urls" SET "health_checked_at"=\$1,"health_error"=\$2,"health_failures"=\$3,"health_latency_ms"=\$4,"health_status"=\$5,"health_status_code"=\$6,"url"=\$7`).
+		WithArgs(nil, "", 0, 0, models.LinkHealthUnchecked, 0, "https://example.com/new", sqlmock.AnyArg(), sqlmock.AnyArg(), id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs(id, id).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(id, "example.com", "abc123", "https://example.com/new", userID, models.LinkHealthUnchecked, 0, now, now))
+
+	expectLoadShortURLTags(mock)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, userID)
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	c.Request = httptest.NewRequest(http.MethodPut, "/api/v1/short-urls/"+id, strings.NewReader(`{"url": "https://example.com/new"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	handler.Update(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response models.ShortURL
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 0, response.Health.Failures)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
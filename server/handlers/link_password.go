@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"openshortpath/server/constants"
+	"openshortpath/server/models"
+	"openshortpath/server/services"
+	"openshortpath/server/utils"
+)
+
+// maxFailedUnlockAttemptsPerHour is the number of wrong passwords an IP may submit per hour
+const maxFailedUnlockAttemptsPerHour = 10
+
+// unlockPageTemplate is the interstitial page shown for password-protected links
+var unlockPageTemplate = template.Must(template.New("unlock").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Password required</title>
+<style>
+body{font-family:system-ui,-apple-system,sans-serif;background:#f5f5f5;display:flex;align-items:center;justify-content:center;min-height:100vh;margin:0}
+form{background:#fff;padding:2rem;border-radius:8px;box-shadow:0 1px 4px rgba(0,0,0,.1);width:100%;max-width:320px}
+h1{font-size:1.25rem;margin:0 0 1rem}
+input{width:100%;box-sizing:border-box;padding:.5rem;margin-bottom:1rem;font-size:1rem}
+button{width:100%;padding:.5rem;font-size:1rem;cursor:pointer}
+.error{color:#b00020;margin:0 0 1rem}
+</style>
+</head>
+<body>
+<form method="POST">
+<h1>This link is password protected</h1>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<input type="password" name="password" placeholder="Password" autofocus required>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// renderUnlockPage writes the unlock form with the given status and optional error message
+func renderUnlockPage(c *gin.Context, status int, errorMessage string) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Cache-Control", "no-store")
+	c.Status(status)
+	unlockPageTemplate.Execute(c.Writer, gin.H{
+		"Error": errorMessage,
+	})
+}
+
+// unlockLink gates a password-protected short URL behind the unlock form
+// Returns true if the request carried the correct password and the redirect may proceed
+// Otherwise the form (or an error) has already been written to the response
+func (h *RedirectHandler) unlockLink(c *gin.Context, shortURL *models.ShortURL) bool {
+	if c.Request.Method != http.MethodPost {
+		renderUnlockPage(c, http.StatusOK, "")
+		return false
+	}
+
+	// Refuse to check passwords for IPs that have failed too often
+	clientIP := services.GetClientIP(c)
+	rateLimitInfo, err := services.GetRateLimitInfo(h.db, clientIP, constants.RateLimitTypeLinkPassword, maxFailedUnlockAttemptsPerHour)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Rate limit check failed",
+		})
+		return false
+	}
+	if rateLimitInfo.Remaining <= 0 {
+		renderUnlockPage(c, http.StatusTooManyRequests, "Too many failed attempts. Please try again later.")
+		return false
+	}
+
+	valid, err := utils.VerifyPassword(c.PostForm("password"), *shortURL.PasswordHash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to verify password",
+		})
+		return false
+	}
+	if !valid {
+		// Only failed attempts count towards the limit
+		if _, err := services.CheckRateLimit(h.db, clientIP, constants.RateLimitTypeLinkPassword, maxFailedUnlockAttemptsPerHour); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Rate limit check failed",
+			})
+			return false
+		}
+		renderUnlockPage(c, http.StatusUnauthorized, "Incorrect password.")
+		return false
+	}
+
+	return true
+}
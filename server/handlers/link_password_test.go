@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"openshortpath/server/config"
+	"openshortpath/server/utils"
+)
+
+// expectProtectedShortURL mocks the lookup of a password-protected short URL
+func expectProtectedShortURL(t *testing.T, mock sqlmock.Sqlmock, password string) {
+	hashed, err := utils.HashPassword(password)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "password_hash", "created_at", "updated_at"}).
+		AddRow(uuid.New().String(), "example.com", "secret", "https://example.com/private", "", hashed, now, now)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "secret").
+		WillReturnRows(rows)
+	expectNoDeviceRules(mock)
+}
+
+// newUnlockContext creates a request context for the protected link
+func newUnlockContext(method string, password string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	if method == http.MethodPost {
+		form := url.Values{"password": {password}}
+		c.Request = httptest.NewRequest(http.MethodPost, "/secret", strings.NewReader(form.Encode()))
+		c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		c.Request = httptest.NewRequest(method, "/secret", nil)
+	}
+	c.Request.Host = "example.com"
+	c.Request.Header.Set("X-Forwarded-For", "198.51.100.4")
+	return c, w
+}
+
+func TestShortLinkRouter_Handle_PasswordProtected_ShowsUnlockForm(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewRedirectHandler(db, &config.Config{AvailableShortDomains: []string{"example.com"}})
+	expectProtectedShortURL(t, mock, "hunter2")
+
+	c, w := newUnlockContext(http.MethodGet, "")
+	newTestRouterFor(handler).Handle(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), `name="password"`)
+	assert.Empty(t, w.Header().Get("Location"))
+	assert.NotContains(t, w.Body.String(), "https://example.com/private")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortLinkRouter_Handle_PasswordProtected_CorrectPassword(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewRedirectHandler(db, &config.Config{AvailableShortDomains: []string{"example.com"}})
+	expectProtectedShortURL(t, mock, "hunter2")
+
+	// Rate limit lookup finds no failed attempts
+	mock.ExpectQuery(`SELECT (.+) FROM "rate_limits"`).
+		WithArgs("198.51.100.4", "link_password", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	c, w := newUnlockContext(http.MethodPost, "hunter2")
+	newTestRouterFor(handler).Handle(c)
+	// http.Redirect writes no body for POST, so flush the status as the engine would
+	c.Writer.WriteHeaderNow()
+
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "https://example.com/private", w.Header().Get("Location"))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortLinkRouter_Handle_PasswordProtected_WrongPassword(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewRedirectHandler(db, &config.Config{AvailableShortDomains: []string{"example.com"}})
+	expectProtectedShortURL(t, mock, "hunter2")
+
+	mock.ExpectQuery(`SELECT (.+) FROM "rate_limits"`).
+		WithArgs("198.51.100.4", "link_password", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	// The failed attempt is counted
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM "rate_limits"`).
+		WithArgs("198.51.100.4", "link_password", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectExec(`INSERT INTO "rate_limits"`).
+		WithArgs(sqlmock.AnyArg(), "198.51.100.4", "link_password", 1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	c, w := newUnlockContext(http.MethodPost, "wrong")
+	newTestRouterFor(handler).Handle(c)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Incorrect password")
+	assert.Empty(t, w.Header().Get("Location"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortLinkRouter_Handle_PasswordProtected_RateLimited(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewRedirectHandler(db, &config.Config{AvailableShortDomains: []string{"example.com"}})
+	expectProtectedShortURL(t, mock, "hunter2")
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT (.+) FROM "rate_limits"`).
+		WithArgs("198.51.100.4", "link_password", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "identifier", "type", "request_count", "window_start", "created_at", "updated_at"}).
+			AddRow(uuid.New().String(), "198.51.100.4", "link_password", maxFailedUnlockAttemptsPerHour, now, now, now))
+
+	// Even the correct password is refused while limited
+	c, w := newUnlockContext(http.MethodPost, "hunter2")
+	newTestRouterFor(handler).Handle(c)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
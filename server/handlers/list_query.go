@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// likeEscaper escapes the LIKE wildcards of a search term, with backslash as the escape character
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// searchColumns narrows a query to rows where any of the columns contains term, ignoring case
+// LOWER and LIKE with an explicit ESCAPE behave the same on SQLite and Postgres, unlike ILIKE
+func searchColumns(query *gorm.DB, term string, columns ...string) *gorm.DB {
+	pattern := "%" + likeEscaper.Replace(strings.ToLower(term)) + "%"
+	conditions := make([]string, len(columns))
+	args := make([]interface{}, len(columns))
+	for i, column := range columns {
+		conditions[i] = fmt.Sprintf(`LOWER(%s) LIKE ? ESCAPE '\'`, column)
+		args[i] = pattern
+	}
+	return query.Where(strings.Join(conditions, " OR "), args...)
+}
+
+// filterByCreatedAt narrows a list query to rows created from ?created_after= (inclusive)
+// until ?created_before= (exclusive), both RFC3339 times
+func filterByCreatedAt(c *gin.Context, query *gorm.DB) (*gorm.DB, error) {
+	bounds := []struct {
+		param     string
+		condition string
+	}{
+		{"created_after", "created_at >= ?"},
+		{"created_before", "created_at < ?"},
+	}
+	for _, bound := range bounds {
+		value := c.Query(bound.param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return query, fmt.Errorf("Invalid %s parameter (must be RFC3339)", bound.param)
+		}
+		query = query.Where(bound.condition, t.UTC())
+	}
+	return query, nil
+}
+
+// listOrder builds the ORDER BY clause of a list from ?sort= (one of the sortable columns,
+// created_at by default) and ?order= (asc or desc, desc by default)
+// Ties are broken by ID, so rows don't move between pages
+func listOrder(c *gin.Context, sortable ...string) (string, error) {
+	column := c.DefaultQuery("sort", "created_at")
+	if !slices.Contains(sortable, column) {
+		return "", fmt.Errorf("Invalid sort parameter (must be one of %s)", strings.Join(sortable, ", "))
+	}
+
+	direction := strings.ToUpper(c.DefaultQuery("order", "desc"))
+	if direction != "ASC" && direction != "DESC" {
+		return "", fmt.Errorf("Invalid order parameter (must be asc or desc)")
+	}
+
+	return fmt.Sprintf("%s %s, id %s", column, direction, direction), nil
+}
@@ -16,6 +16,11 @@ type MeHandler struct {
 	db *gorm.DB
 }
 
+// UpdateMeRequest represents the settings a user can change on their own account
+type UpdateMeRequest struct {
+	DedupLinks *bool `json:"dedup_links,omitempty"` // Reuse an existing link for the same destination by default when shortening
+}
+
 func NewMeHandler(db *gorm.DB) *MeHandler {
 	return &MeHandler{
 		db: db,
@@ -111,6 +116,7 @@ func (h *MeHandler) GetMe(c *gin.Context) {
 		MonthlyLinksUsed:   monthlyLinksUsed,
 		RateLimitPerHour:   rateLimitInfo.Limit,
 		RateLimitRemaining: rateLimitInfo.Remaining,
+		DedupLinks:         &user.DedupLinks,
 		CreatedAt:          user.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 		UpdatedAt:          user.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
 	}
@@ -126,3 +132,61 @@ func (h *MeHandler) GetMe(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response)
 }
+
+// UpdateMe handles PATCH /api/v1/me
+// Updates the current user's settings and returns their details
+func (h *MeHandler) UpdateMe(c *gin.Context) {
+	// Get user ID from context (set by RequireAuth middleware)
+	userIDValue, exists := c.Get(constants.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User ID not found in context",
+		})
+		return
+	}
+
+	userID, ok := userIDValue.(string)
+	if !ok || userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid user ID in context",
+		})
+		return
+	}
+
+	var req UpdateMeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	updateFields := make(map[string]interface{})
+	if req.DedupLinks != nil {
+		updateFields["dedup_links"] = *req.DedupLinks
+	}
+	if len(updateFields) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "No fields to update",
+		})
+		return
+	}
+
+	result := h.db.Model(&models.User{}).Where("user_id = ?", userID).Updates(updateFields)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to update user",
+			"details": result.Error.Error(),
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "User not found",
+		})
+		return
+	}
+
+	h.GetMe(c)
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -13,21 +14,30 @@ import (
 	"openshortpath/server/config"
 	"openshortpath/server/constants"
 	"openshortpath/server/models"
+	"openshortpath/server/services"
 )
 
 type NamespacesHandler struct {
-	db  *gorm.DB
-	cfg *config.Config
+	db            *gorm.DB
+	cfg           *config.Config
+	redirectCache *services.RedirectCache
+	urlPolicy     *services.URLPolicy
 }
 
 type CreateNamespaceRequest struct {
 	Name   string `json:"name" binding:"required"`
 	Domain string `json:"domain" binding:"required"`
+	models.UTMDefaults
+	NotFound       models.NotFoundFallback `json:"not_found"`       // What visitors see for unknown slugs; an empty action inherits the domain's fallback
+	SlugGeneration models.SlugGeneration   `json:"slug_generation"` // How slugs are generated for links without one; an empty strategy inherits the deployment's setting
 }
 
 type UpdateNamespaceRequest struct {
 	Name   string `json:"name,omitempty"`
 	Domain string `json:"domain,omitempty"`
+	UTMDefaultsUpdate
+	NotFound       *models.NotFoundFallback `json:"not_found,omitempty"`       // Replaces the not-found fallback; an empty action inherits the domain's fallback
+	SlugGeneration *models.SlugGeneration   `json:"slug_generation,omitempty"` // Replaces the slug generation settings; an empty strategy inherits the deployment's setting
 }
 
 type ListNamespacesResponse struct {
@@ -45,6 +55,17 @@ func NewNamespacesHandler(db *gorm.DB, cfg *config.Config) *NamespacesHandler {
 	}
 }
 
+// SetRedirectCache sets the redirect cache to invalidate when namespaces are created, renamed or deleted
+func (h *NamespacesHandler) SetRedirectCache(cache *services.RedirectCache) {
+	h.redirectCache = cache
+}
+
+// SetURLPolicy sets the policy not-found redirect URLs must pass
+// Without a policy not-found redirect URLs are stored unchecked
+func (h *NamespacesHandler) SetURLPolicy(policy *services.URLPolicy) {
+	h.urlPolicy = policy
+}
+
 // isValidDomain checks if the domain exists in the available short domains list
 func isValidDomainForNamespace(domain string, availableDomains []string) bool {
 	for _, availableDomain := range availableDomains {
@@ -122,6 +143,26 @@ func (h *NamespacesHandler) CreateNamespace(c *gin.Context) {
 		return
 	}
 
+	// Validate not-found fallback
+	notFound, err := validateNotFoundFallback(req.NotFound)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if !applyURLPolicy(c, h.urlPolicy, nil, policyURL{"not_found.url", &notFound.URL}) {
+		return
+	}
+
+	// Validate slug generation settings
+	if err := services.ValidateSlugGeneration(req.SlugGeneration); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid slug_generation: " + err.Error(),
+		})
+		return
+	}
+
 	// Check for existing namespace with same (domain, name) combination
 	var existing models.Namespace
 	result := h.db.Where("domain = ? AND name = ?", req.Domain, req.Name).First(&existing)
@@ -146,10 +187,13 @@ func (h *NamespacesHandler) CreateNamespace(c *gin.Context) {
 
 	// Create new Namespace record
 	namespace := models.Namespace{
-		ID:     id,
-		Name:   req.Name,
-		Domain: req.Domain,
-		UserID: userID,
+		ID:             id,
+		Name:           req.Name,
+		Domain:         req.Domain,
+		UserID:         userID,
+		UTMDefaults:    req.UTMDefaults,
+		NotFound:       notFound,
+		SlugGeneration: req.SlugGeneration,
 	}
 
 	if err := h.db.Create(&namespace).Error; err != nil {
@@ -160,11 +204,16 @@ func (h *NamespacesHandler) CreateNamespace(c *gin.Context) {
 		return
 	}
 
+	// Drop any cached "namespace not found" for the new name
+	h.redirectCache.InvalidateNamespace(&namespace)
+
 	// Return the created namespace
 	c.JSON(http.StatusCreated, namespace)
 }
 
 // ListNamespaces handles GET /api/v1/namespaces
+// ?q= searches names; ?domain=, ?created_after= and ?created_before= filter them
+// ?sort= orders by created_at (default), updated_at or name, and ?order= is asc or desc (default)
 func (h *NamespacesHandler) ListNamespaces(c *gin.Context) {
 	// Get user ID from context (set by RequireAuth middleware)
 	userIDValue, exists := c.Get(constants.ContextKeyUserID)
@@ -206,9 +255,36 @@ func (h *NamespacesHandler) ListNamespaces(c *gin.Context) {
 	// Calculate offset
 	offset := (page - 1) * limit
 
+	// Build base query with optional filters
+	query := h.db.Model(&models.Namespace{}).Where("user_id = ?", userID)
+
+	if search := strings.TrimSpace(c.Query("q")); search != "" {
+		query = searchColumns(query, search, "name")
+	}
+
+	if domain := c.Query("domain"); domain != "" {
+		query = query.Where("domain = ?", domain)
+	}
+
+	query, err := filterByCreatedAt(c, query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	order, err := listOrder(c, "created_at", "updated_at", "name")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
 	// Query total count
 	var total int64
-	if err := h.db.Model(&models.Namespace{}).Where("user_id = ?", userID).Count(&total).Error; err != nil {
+	if err := query.Count(&total).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Database error",
 			"details": err.Error(),
@@ -218,8 +294,8 @@ func (h *NamespacesHandler) ListNamespaces(c *gin.Context) {
 
 	// Query paginated results
 	var namespaces []models.Namespace
-	if err := h.db.Where("user_id = ?", userID).
-		Order("created_at DESC").
+	if err := query.
+		Order(order).
 		Offset(offset).
 		Limit(limit).
 		Find(&namespaces).Error; err != nil {
@@ -405,6 +481,36 @@ func (h *NamespacesHandler) UpdateNamespace(c *gin.Context) {
 		updateFields["name"] = req.Name
 	}
 
+	req.UTMDefaultsUpdate.addUpdateFields(updateFields)
+
+	if req.NotFound != nil {
+		notFound, err := validateNotFoundFallback(*req.NotFound)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		if !applyURLPolicy(c, h.urlPolicy, nil, policyURL{"not_found.url", &notFound.URL}) {
+			return
+		}
+		updateFields["not_found_action"] = notFound.Action
+		updateFields["not_found_url"] = notFound.URL
+		updateFields["not_found_html"] = notFound.HTML
+	}
+
+	if req.SlugGeneration != nil {
+		if err := services.ValidateSlugGeneration(*req.SlugGeneration); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid slug_generation: " + err.Error(),
+			})
+			return
+		}
+		updateFields["slug_strategy"] = req.SlugGeneration.Strategy
+		updateFields["slug_length"] = req.SlugGeneration.Length
+		updateFields["slug_alphabet"] = req.SlugGeneration.Alphabet
+	}
+
 	// If no fields to update, return the existing record
 	if len(updateFields) == 0 {
 		c.JSON(http.StatusOK, namespace)
@@ -429,6 +535,9 @@ func (h *NamespacesHandler) UpdateNamespace(c *gin.Context) {
 		return
 	}
 
+	// Drop cached lookups through the namespace and any cached 404 for its new name
+	h.redirectCache.InvalidateNamespace(&namespace)
+
 	c.JSON(http.StatusOK, namespace)
 }
 
@@ -477,6 +586,16 @@ func (h *NamespacesHandler) DeleteNamespace(c *gin.Context) {
 		return
 	}
 
+	// Delete records that belong to the namespace's short URLs
+	namespaceShortURLIDs := h.db.Model(&models.ShortURL{}).Select("id").Where("namespace_id = ?", id)
+	if err := deleteShortURLDependents(h.db, namespaceShortURLIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to delete associated short URLs",
+			"details": err.Error(),
+		})
+		return
+	}
+
 	// Delete all short URLs that reference this namespace
 	if err := h.db.Where("namespace_id = ?", id).Delete(&models.ShortURL{}).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -495,5 +614,8 @@ func (h *NamespacesHandler) DeleteNamespace(c *gin.Context) {
 		return
 	}
 
+	// Drop cached lookups of the deleted records
+	h.redirectCache.InvalidateNamespace(&namespace)
+
 	c.AbortWithStatus(http.StatusNoContent)
 }
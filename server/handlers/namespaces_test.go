@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -17,6 +18,7 @@ import (
 	"openshortpath/server/config"
 	"openshortpath/server/constants"
 	"openshortpath/server/models"
+	"openshortpath/server/services"
 )
 
 func TestNamespacesHandler_CreateNamespace_Success(t *testing.T) {
@@ -39,7 +41,7 @@ func TestNamespacesHandler_CreateNamespace_Success(t *testing.T) {
 	// Second: insert new namespace
 	mock.ExpectBegin()
 	mock.ExpectExec(`INSERT INTO "namespaces"`).
-		WithArgs(sqlmock.AnyArg(), "my-namespace", "example.com", userID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), "my-namespace", "example.com", userID, "", "", "", "", "", "", "", 0, "", sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
@@ -143,7 +145,7 @@ func TestNamespacesHandler_CreateNamespace_ValidNameFormats(t *testing.T) {
 
 			mock.ExpectBegin()
 			mock.ExpectExec(`INSERT INTO "namespaces"`).
-				WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "example.com", userID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "example.com", userID, "", "", "", "", "", "", "", 0, "", sqlmock.AnyArg(), sqlmock.AnyArg()).
 				WillReturnResult(sqlmock.NewResult(1, 1))
 			mock.ExpectCommit()
 
@@ -467,6 +469,184 @@ func TestNamespacesHandler_UpdateNamespace_InvalidName(t *testing.T) {
 	assert.Contains(t, response["error"], "lowercase alphanumerical")
 }
 
+func TestNamespacesHandler_UpdateNamespace_NotFoundFallback(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	cfg := &config.Config{
+		AvailableShortDomains: []string{"example.com", "localhost:3000"},
+	}
+
+	handler := NewNamespacesHandler(db, cfg)
+	userID := uuid.New().String()
+	namespaceID := uuid.New().String()
+	now := time.Now()
+
+	// Mock find query
+	rows := sqlmock.NewRows([]string{"id", "name", "domain", "user_id", "created_at", "updated_at"}).
+		AddRow(namespaceID, "marketing", "example.com", userID, now, now)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "namespaces"`).
+		WithArgs(namespaceID, userID).
+		WillReturnRows(rows)
+
+	// Mock update - fields the action doesn't use are cleared
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "namespaces"`).
+		WithArgs("redirect", "", "https://example.org/missing", sqlmock.AnyArg(), namespaceID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// Mock reload
+	updatedRows := sqlmock.NewRows([]string{"id", "name", "domain", "user_id", "not_found_action", "not_found_url", "created_at", "updated_at"}).
+		AddRow(namespaceID, "marketing", "example.com", userID, "redirect", "https://example.org/missing", now, now)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "namespaces"`).
+		WithArgs(namespaceID, namespaceID).
+		WillReturnRows(updatedRows)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, userID)
+	c.Params = gin.Params{gin.Param{Key: "id", Value: namespaceID}}
+	c.Request = httptest.NewRequest(http.MethodPut, "/api/v1/namespaces/"+namespaceID, strings.NewReader(`{"not_found": {"action": "redirect", "url": "https://example.org/missing", "html": "<p>unused</p>"}}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	handler.UpdateNamespace(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response models.Namespace
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, models.NotFoundFallback{Action: "redirect", URL: "https://example.org/missing"}, response.NotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNamespacesHandler_UpdateNamespace_InvalidNotFoundFallback(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	cfg := &config.Config{
+		AvailableShortDomains: []string{"example.com", "localhost:3000"},
+	}
+
+	handler := NewNamespacesHandler(db, cfg)
+	userID := uuid.New().String()
+	namespaceID := uuid.New().String()
+	now := time.Now()
+
+	// Mock find query
+	rows := sqlmock.NewRows([]string{"id", "name", "domain", "user_id", "created_at", "updated_at"}).
+		AddRow(namespaceID, "marketing", "example.com", userID, now, now)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "namespaces"`).
+		WithArgs(namespaceID, userID).
+		WillReturnRows(rows)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, userID)
+	c.Params = gin.Params{gin.Param{Key: "id", Value: namespaceID}}
+	c.Request = httptest.NewRequest(http.MethodPut, "/api/v1/namespaces/"+namespaceID, strings.NewReader(`{"not_found": {"action": "html"}}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	handler.UpdateNamespace(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Contains(t, response["error"], "not_found.html is required")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNamespacesHandler_UpdateNamespace_NotFoundRedirectURLPolicyRejected(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	cfg := &config.Config{
+		AvailableShortDomains: []string{"example.com", "localhost:3000"},
+	}
+
+	handler := NewNamespacesHandler(db, cfg)
+	handler.SetURLPolicy(newTestURLPolicy(t))
+	userID := uuid.New().String()
+	namespaceID := uuid.New().String()
+	now := time.Now()
+
+	// Mock find query
+	rows := sqlmock.NewRows([]string{"id", "name", "domain", "user_id", "created_at", "updated_at"}).
+		AddRow(namespaceID, "marketing", "example.com", userID, now, now)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "namespaces"`).
+		WithArgs(namespaceID, userID).
+		WillReturnRows(rows)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, userID)
+	c.Params = gin.Params{gin.Param{Key: "id", Value: namespaceID}}
+	c.Request = httptest.NewRequest(http.MethodPut, "/api/v1/namespaces/"+namespaceID, strings.NewReader(`{"not_found": {"action": "redirect", "url": "http://127.0.0.1/admin"}}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	handler.UpdateNamespace(c)
+
+	// The fallback is rejected before it is stored
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, services.URLPolicyPrivateAddress, response["code"])
+	assert.Equal(t, "not_found.url", response["field"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNamespacesHandler_UpdateNamespace_InvalidSlugGeneration(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	cfg := &config.Config{
+		AvailableShortDomains: []string{"example.com", "localhost:3000"},
+	}
+
+	handler := NewNamespacesHandler(db, cfg)
+	userID := uuid.New().String()
+	namespaceID := uuid.New().String()
+	now := time.Now()
+
+	// Mock find query
+	rows := sqlmock.NewRows([]string{"id", "name", "domain", "user_id", "created_at", "updated_at"}).
+		AddRow(namespaceID, "marketing", "example.com", userID, now, now)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "namespaces"`).
+		WithArgs(namespaceID, userID).
+		WillReturnRows(rows)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, userID)
+	c.Params = gin.Params{gin.Param{Key: "id", Value: namespaceID}}
+	c.Request = httptest.NewRequest(http.MethodPut, "/api/v1/namespaces/"+namespaceID, strings.NewReader(`{"slug_generation": {"strategy": "random", "alphabet": "a/b"}}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	handler.UpdateNamespace(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Contains(t, response["error"], "Invalid slug_generation")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestNamespacesHandler_DeleteNamespace_Success(t *testing.T) {
 	db, mock, sqlDB := setupTestDB(t)
 	defer sqlDB.Close()
@@ -488,6 +668,9 @@ func TestNamespacesHandler_DeleteNamespace_Success(t *testing.T) {
 		WithArgs(namespaceID, userID).
 		WillReturnRows(rows)
 
+	// Mock delete of records belonging to the namespace's short URLs
+	expectDeleteShortURLDependents(mock, `short_url_id IN \(SELECT "id" FROM "short_urls" WHERE namespace_id = \$1\)`, namespaceID)
+
 	// Mock delete short URLs
 	mock.ExpectBegin()
 	mock.ExpectExec(`DELETE FROM "short_urls"`).
@@ -535,6 +718,9 @@ func TestNamespacesHandler_DeleteNamespace_WithShortURLs(t *testing.T) {
 		WithArgs(namespaceID, userID).
 		WillReturnRows(rows)
 
+	// Mock delete of records belonging to the namespace's short URLs
+	expectDeleteShortURLDependents(mock, `short_url_id IN \(SELECT "id" FROM "short_urls" WHERE namespace_id = \$1\)`, namespaceID)
+
 	// Mock delete short URLs (2 URLs deleted)
 	mock.ExpectBegin()
 	mock.ExpectExec(`DELETE FROM "short_urls"`).
@@ -603,10 +789,10 @@ func TestShortenHandler_WithNamespace_Success(t *testing.T) {
 	namespaceID := uuid.New().String()
 	now := time.Now()
 
-	// Mock check for existing short URL (should return no rows) - this happens first
-	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
-		WithArgs("example.com", sqlmock.AnyArg()).
-		WillReturnError(gorm.ErrRecordNotFound)
+	// Mock user query for the deduplication default
+	mock.ExpectQuery(`SELECT "dedup_links" FROM "users"`).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"dedup_links"}).AddRow(false))
 
 	// Mock user query to get plan (for monthly limit check)
 	userRows := sqlmock.NewRows([]string{"user_id", "username", "hashed_password", "active", "plan", "created_at", "updated_at"}).
@@ -637,11 +823,16 @@ func TestShortenHandler_WithNamespace_Success(t *testing.T) {
 		WithArgs(namespaceID, userID).
 		WillReturnRows(namespaceRows)
 
+	// Generated slugs are checked for collisions after the quota and namespace checks
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+
 	// Mock insert short URL
 	// GORM order: id, domain, slug, url, user_id, namespace_id, created_at, updated_at
 	mock.ExpectBegin()
 	mock.ExpectExec(`INSERT INTO "short_urls"`).
-		WithArgs(sqlmock.AnyArg(), "example.com", sqlmock.AnyArg(), "https://example.com/target", userID, namespaceID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), "example.com", sqlmock.AnyArg(), "https://example.com/target", sqlmock.AnyArg(), userID, namespaceID, 0, nil, nil, 0, nil, nil, false, false, false, "", "", "", "", "", "", "", "", "", "", 0, 0, nil, "", 0, "", sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
@@ -676,11 +867,6 @@ func TestShortenHandler_WithNamespace_Unauthorized(t *testing.T) {
 	handler := NewShortenHandler(db, cfg)
 	namespaceID := uuid.New().String()
 
-	// Mock check for existing short URL (should return no rows) - this happens first
-	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
-		WithArgs("example.com", sqlmock.AnyArg()).
-		WillReturnError(gorm.ErrRecordNotFound)
-
 	// Mock monthly link limit check for anonymous user (IP-based)
 	// This happens before namespace check, but since user is anonymous, it will check IP limit
 	mock.ExpectBegin()
@@ -724,10 +910,10 @@ func TestShortenHandler_WithNamespace_NotFound(t *testing.T) {
 	namespaceID := uuid.New().String()
 	now := time.Now()
 
-	// Mock check for existing short URL (should return no rows) - this happens first
-	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
-		WithArgs("example.com", sqlmock.AnyArg()).
-		WillReturnError(gorm.ErrRecordNotFound)
+	// Mock user query for the deduplication default
+	mock.ExpectQuery(`SELECT "dedup_links" FROM "users"`).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"dedup_links"}).AddRow(false))
 
 	// Mock user query to get plan (for monthly limit check)
 	userRows := sqlmock.NewRows([]string{"user_id", "username", "hashed_password", "active", "plan", "created_at", "updated_at"}).
@@ -815,6 +1001,8 @@ func TestShortURLsHandler_Update_WithNamespace_Success(t *testing.T) {
 		WithArgs(shortURLID, shortURLID).
 		WillReturnRows(updatedRows)
 
+	expectLoadShortURLTags(mock)
+
 	gin.SetMode(gin.TestMode)
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
@@ -871,6 +1059,8 @@ func TestShortURLsHandler_Update_RemoveNamespace(t *testing.T) {
 		WithArgs(shortURLID, shortURLID).
 		WillReturnRows(updatedRows)
 
+	expectLoadShortURLTags(mock)
+
 	gin.SetMode(gin.TestMode)
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
@@ -933,3 +1123,137 @@ func TestShortURLsHandler_Update_WithNamespace_NotFound(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Contains(t, response["error"], "not found or you do not have permission")
 }
+
+func TestShortenHandler_WithNamespace_SlugGeneration(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	cfg := &config.Config{
+		AvailableShortDomains: []string{"example.com", "localhost:3000"},
+	}
+
+	handler := NewShortenHandler(db, cfg)
+	userID := uuid.New().String()
+	namespaceID := uuid.New().String()
+	now := time.Now()
+
+	// Mock user query for the deduplication default
+	mock.ExpectQuery(`SELECT "dedup_links" FROM "users"`).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"dedup_links"}).AddRow(false))
+
+	// Mock user query to get plan (for monthly limit check)
+	userRows := sqlmock.NewRows([]string{"user_id", "username", "hashed_password", "active", "plan", "created_at", "updated_at"}).
+		AddRow(userID, "testuser", nil, true, "hobbyist", now, now)
+	mock.ExpectQuery(`SELECT (.+) FROM "users"`).
+		WithArgs(userID).
+		WillReturnRows(userRows)
+
+	// Mock monthly link limit check transaction
+	// First, the transaction begins
+	mock.ExpectBegin()
+	// Then query for existing monthly limit record (should return no rows)
+	mock.ExpectQuery(`SELECT (.+) FROM "monthly_link_limits"`).
+		WithArgs(userID, "user", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+	// Then insert new monthly link limit record
+	mock.ExpectExec(`INSERT INTO "monthly_link_limits"`).
+		WithArgs(sqlmock.AnyArg(), userID, "user", 1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	// Transaction commits
+	mock.ExpectCommit()
+
+	// Mock namespace ownership check
+	namespaceRows := sqlmock.NewRows([]string{"id", "name", "domain", "user_id", "slug_strategy", "slug_length", "created_at", "updated_at"}).
+		AddRow(namespaceID, "my-namespace", "example.com", userID, models.SlugStrategyPronounceable, 6, now, now)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "namespaces"`).
+		WithArgs(namespaceID, userID).
+		WillReturnRows(namespaceRows)
+
+	// Generated slugs are checked for collisions after the quota and namespace checks
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	// Mock insert short URL
+	// GORM order: id, domain, slug, url, user_id, namespace_id, created_at, updated_at
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "short_urls"`).
+		WithArgs(sqlmock.AnyArg(), "example.com", sqlmock.AnyArg(), "https://example.com/target", sqlmock.AnyArg(), userID, namespaceID, 0, nil, nil, 0, nil, nil, false, false, false, "", "", "", "", "", "", "", "", "", "", 0, 0, nil, "", 0, "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, userID)
+
+	reqBody := `{"domain": "example.com", "url": "https://example.com/target", "namespace_id": "` + namespaceID + `"}`
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/shorten", strings.NewReader(reqBody))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	handler.Shorten(c)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response models.ShortURL
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	// The namespace's own strategy overrides the deployment default
+	assert.Regexp(t, `^([bdfghjklmnprstvz][aeiou]){3}$`, response.Slug)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNamespacesHandler_ListNamespaces_SearchFilterAndSort(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewNamespacesHandler(db, &config.Config{})
+	userID := uuid.New().String()
+	now := time.Now()
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	where := regexp.QuoteMeta(`WHERE user_id = $1 AND LOWER(name) LIKE $2 ESCAPE '\' AND domain = $3 AND created_at >= $4`)
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "namespaces" ` + where).
+		WithArgs(userID, "%team%", "example.com", after).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT \* FROM "namespaces" ` + where + ` ORDER BY name DESC, id DESC`).
+		WithArgs(userID, "%team%", "example.com", after).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "domain", "user_id", "created_at", "updated_at"}).
+			AddRow(uuid.New().String(), "team-a", "example.com", userID, now, now))
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, userID)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/namespaces?q=TEAM&domain=example.com&created_after=2024-01-01T00:00:00Z&sort=name", nil)
+
+	handler.ListNamespaces(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response ListNamespacesResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Namespaces, 1)
+	assert.Equal(t, int64(1), response.Total)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNamespacesHandler_ListNamespaces_InvalidSort(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewNamespacesHandler(db, &config.Config{})
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, "user123")
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/namespaces?sort=click_count", nil)
+
+	handler.ListNamespaces(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
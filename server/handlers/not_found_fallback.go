@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"openshortpath/server/models"
+)
+
+// maxNotFoundHTMLSize is the largest not-found page a namespace owner can save
+const maxNotFoundHTMLSize = 64 * 1024
+
+// maxNotFoundPageSize caps the rendered page of an operator's not-found template, since nested templates
+// can multiply their output
+const maxNotFoundPageSize = 1024 * 1024
+
+// errNotFoundPageTooLarge is returned when a rendered not-found page exceeds maxNotFoundPageSize
+var errNotFoundPageTooLarge = errors.New("rendered not-found page is too large")
+
+// notFoundPageData describes the missing link a not-found page is served for
+// It is the data of the operator's templates; namespace pages get it through their placeholders
+type notFoundPageData struct {
+	Domain    string
+	Namespace string
+	Slug      string
+}
+
+// limitedBuffer is a bytes.Buffer that refuses writes past max bytes
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.max {
+		return 0, errNotFoundPageTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+// parseNotFoundTemplate parses the source of an operator's not-found template
+// Only templates from the operator's template_path are parsed; namespace owners' pages are static HTML
+func parseNotFoundTemplate(source string) (*template.Template, error) {
+	return template.New("not-found").Parse(source)
+}
+
+// namespaceNotFoundPage fills in the placeholders of a namespace's not-found page
+// Owner-supplied HTML is never executed as a template, so it can't cost more than one pass over the page.
+// {{domain}}, {{namespace}} and {{slug}} are replaced with HTML-escaped values
+func namespaceNotFoundPage(source string, data notFoundPageData) []byte {
+	replacer := strings.NewReplacer(
+		"{{domain}}", html.EscapeString(data.Domain),
+		"{{namespace}}", html.EscapeString(data.Namespace),
+		"{{slug}}", html.EscapeString(data.Slug),
+	)
+	return []byte(replacer.Replace(source))
+}
+
+// validateNotFoundFallback checks a namespace's not-found fallback and drops the fields its action doesn't use
+func validateNotFoundFallback(fallback models.NotFoundFallback) (models.NotFoundFallback, error) {
+	switch fallback.Action {
+	case "":
+		return models.NotFoundFallback{}, nil
+	case models.NotFoundActionLanding, models.NotFoundActionJSON:
+		return models.NotFoundFallback{Action: fallback.Action}, nil
+	case models.NotFoundActionRedirect:
+		parsed, err := url.Parse(fallback.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fallback, fmt.Errorf("not_found.url must be an http or https URL")
+		}
+		return models.NotFoundFallback{Action: fallback.Action, URL: fallback.URL}, nil
+	case models.NotFoundActionHTML:
+		if fallback.HTML == "" {
+			return fallback, fmt.Errorf("not_found.html is required when not_found.action is 'html'")
+		}
+		if len(fallback.HTML) > maxNotFoundHTMLSize {
+			return fallback, fmt.Errorf("not_found.html must be %d bytes or less", maxNotFoundHTMLSize)
+		}
+		return models.NotFoundFallback{Action: fallback.Action, HTML: fallback.HTML}, nil
+	}
+	return fallback, fmt.Errorf("not_found.action must be 'landing', 'redirect', 'html' or 'json'")
+}
+
+// LoadNotFoundTemplates reads and parses the templates of the per-domain "html" not-found fallbacks
+func (h *RedirectHandler) LoadNotFoundTemplates() error {
+	templates := make(map[string]*template.Template)
+	for domain, fallback := range h.cfg.NotFoundFallbacks {
+		if fallback.Action != models.NotFoundActionHTML {
+			continue
+		}
+		source, err := os.ReadFile(fallback.TemplatePath)
+		if err != nil {
+			return fmt.Errorf("failed to read not-found template for %s: %w", domain, err)
+		}
+		page, err := parseNotFoundTemplate(string(source))
+		if err != nil {
+			return fmt.Errorf("failed to parse not-found template for %s: %w", domain, err)
+		}
+		templates[domain] = page
+	}
+	h.notFoundTemplates = templates
+	return nil
+}
+
+// respondNotFoundJSON writes the JSON 404 for a route whose namespace or slug doesn't exist
+func respondNotFoundJSON(c *gin.Context, route Route) {
+	message := "Short URL not found"
+	if route.Namespace != "" && route.Entry.Namespace == nil {
+		message = "Namespace not found"
+	}
+	c.JSON(http.StatusNotFound, gin.H{
+		"error": message,
+	})
+}
+
+// renderNotFoundPage renders an operator's not-found template and reports whether it succeeded
+func renderNotFoundPage(page *template.Template, data notFoundPageData) ([]byte, bool) {
+	body := &limitedBuffer{max: maxNotFoundPageSize}
+	if err := page.Execute(body, data); err != nil {
+		return nil, false
+	}
+	return body.Bytes(), true
+}
+
+// writeNotFoundPage serves body as an HTML 404
+// Sandboxed pages are served with a CSP sandbox so owner-supplied HTML can't run scripts on the short domain
+func writeNotFoundPage(c *gin.Context, body []byte, sandboxed bool) {
+	c.Header("Cache-Control", "no-store")
+	if sandboxed {
+		c.Header("Content-Security-Policy", "sandbox")
+	}
+	c.Data(http.StatusNotFound, "text/html; charset=utf-8", body)
+}
+
+// respondNotFound serves a route whose namespace or slug doesn't exist
+// The namespace's fallback wins over the domain's; without either, or for the "landing" action,
+// defaultResponse is used. A template that fails to render falls back to the JSON error
+func (h *RedirectHandler) respondNotFound(c *gin.Context, host string, route Route, defaultResponse gin.HandlerFunc) {
+	data := notFoundPageData{Domain: host, Namespace: route.Namespace, Slug: route.Slug}
+	if namespace := route.Entry.Namespace; namespace != nil && namespace.NotFound.Action != "" {
+		switch namespace.NotFound.Action {
+		case models.NotFoundActionRedirect:
+			c.Header("Cache-Control", "no-store")
+			c.Redirect(http.StatusFound, namespace.NotFound.URL)
+		case models.NotFoundActionHTML:
+			writeNotFoundPage(c, namespaceNotFoundPage(namespace.NotFound.HTML, data), true)
+		case models.NotFoundActionJSON:
+			respondNotFoundJSON(c, route)
+		default:
+			defaultResponse(c)
+		}
+		return
+	}
+
+	var (
+		action      string
+		redirectURL string
+		page        *template.Template
+	)
+	if fallback, ok := h.cfg.NotFoundFallbacks[host]; ok {
+		action = fallback.Action
+		redirectURL = fallback.URL
+		page = h.notFoundTemplates[host]
+	}
+
+	switch action {
+	case models.NotFoundActionRedirect:
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(http.StatusFound, redirectURL)
+	case models.NotFoundActionHTML:
+		if page != nil {
+			if body, ok := renderNotFoundPage(page, data); ok {
+				writeNotFoundPage(c, body, false)
+				return
+			}
+		}
+		respondNotFoundJSON(c, route)
+	case models.NotFoundActionJSON:
+		respondNotFoundJSON(c, route)
+	default:
+		defaultResponse(c)
+	}
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"openshortpath/server/config"
+	"openshortpath/server/models"
+)
+
+func TestValidateNotFoundFallback(t *testing.T) {
+	tests := []struct {
+		name        string
+		fallback    models.NotFoundFallback
+		expected    models.NotFoundFallback
+		expectError bool
+	}{
+		{"inherit clears fields", models.NotFoundFallback{URL: "https://example.org"}, models.NotFoundFallback{}, false},
+		{"json", models.NotFoundFallback{Action: "json", HTML: "<p>x</p>"}, models.NotFoundFallback{Action: "json"}, false},
+		{"redirect", models.NotFoundFallback{Action: "redirect", URL: "https://example.org/404"}, models.NotFoundFallback{Action: "redirect", URL: "https://example.org/404"}, false},
+		{"redirect without url", models.NotFoundFallback{Action: "redirect"}, models.NotFoundFallback{}, true},
+		{"redirect to javascript url", models.NotFoundFallback{Action: "redirect", URL: "javascript:alert(1)"}, models.NotFoundFallback{}, true},
+		{"html", models.NotFoundFallback{Action: "html", HTML: "<p>{{slug}} not found</p>"}, models.NotFoundFallback{Action: "html", HTML: "<p>{{slug}} not found</p>"}, false},
+		{"html isn't parsed as a template", models.NotFoundFallback{Action: "html", HTML: "<p>{{range 300000000}}{{end}}</p>"}, models.NotFoundFallback{Action: "html", HTML: "<p>{{range 300000000}}{{end}}</p>"}, false},
+		{"html without page", models.NotFoundFallback{Action: "html"}, models.NotFoundFallback{}, true},
+		{"html too large", models.NotFoundFallback{Action: "html", HTML: strings.Repeat("x", maxNotFoundHTMLSize+1)}, models.NotFoundFallback{}, true},
+		{"unknown action", models.NotFoundFallback{Action: "teapot"}, models.NotFoundFallback{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fallback, err := validateNotFoundFallback(tt.fallback)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, fallback)
+		})
+	}
+}
+
+func TestShortLinkRouter_Handle_DomainNotFoundFallback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name             string
+		fallback         config.NotFoundFallback
+		expectedCode     int
+		expectedLocation string
+		expectedBody     string
+	}{
+		{"redirect", config.NotFoundFallback{Action: "redirect", URL: "https://example.org/missing"}, http.StatusFound, "https://example.org/missing", ""},
+		{"json", config.NotFoundFallback{Action: "json"}, http.StatusNotFound, "", `{"error":"Short URL not found"}`},
+		{"landing", config.NotFoundFallback{Action: "landing"}, http.StatusOK, "", "landing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, sqlDB := setupTestDB(t)
+			defer sqlDB.Close()
+
+			router := newTestShortLinkRouter(db)
+			router.redirectHandler.cfg.NotFoundFallbacks = map[string]config.NotFoundFallback{"example.com": tt.fallback}
+
+			mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+				WithArgs("example.com", "missing").
+				WillReturnError(gorm.ErrRecordNotFound)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/missing", nil)
+			c.Request.Host = "example.com"
+
+			router.Handle(c)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Equal(t, tt.expectedLocation, w.Header().Get("Location"))
+			if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, w.Body.String())
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestShortLinkRouter_Handle_DomainNotFoundTemplate(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	templatePath := filepath.Join(t.TempDir(), "404.html")
+	assert.NoError(t, os.WriteFile(templatePath, []byte("<h1>{{.Domain}}/{{.Slug}} is gone</h1>"), 0o600))
+
+	router := newTestShortLinkRouter(db)
+	router.redirectHandler.cfg.NotFoundFallbacks = map[string]config.NotFoundFallback{
+		"example.com": {Action: "html", TemplatePath: templatePath},
+	}
+	assert.NoError(t, router.redirectHandler.LoadNotFoundTemplates())
+
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "<b>").
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/%3Cb%3E", nil)
+	c.Request.Host = "example.com"
+
+	router.Handle(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "<h1>example.com/&lt;b&gt; is gone</h1>", w.Body.String())
+	assert.Empty(t, w.Header().Get("Content-Security-Policy"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRedirectHandler_LoadNotFoundTemplates_MissingFile(t *testing.T) {
+	cfg := &config.Config{
+		NotFoundFallbacks: map[string]config.NotFoundFallback{
+			"example.com": {Action: "html", TemplatePath: filepath.Join(t.TempDir(), "missing.html")},
+		},
+	}
+	assert.Error(t, NewRedirectHandler(nil, cfg).LoadNotFoundTemplates())
+}
+
+func TestShortLinkRouter_Handle_NamespaceNotFoundFallback(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	router := newTestShortLinkRouter(db)
+	// The namespace's fallback wins over the domain's
+	router.redirectHandler.cfg.NotFoundFallbacks = map[string]config.NotFoundFallback{
+		"example.com": {Action: "redirect", URL: "https://example.org/missing"},
+	}
+
+	namespaceID := uuid.New().String()
+	now := time.Now()
+	mock.ExpectQuery(`SELECT (.+) FROM "namespaces"`).
+		WithArgs("example.com", "marketing").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "domain", "user_id", "not_found_action", "not_found_html", "created_at", "updated_at"}).
+			AddRow(namespaceID, "marketing", "example.com", "user123", "html", "<p>No {{slug}} in {{namespace}} {{range 300000000}}{{end}}</p>", now, now))
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", namespaceID, "missing").
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "marketing").
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/marketing/missing", nil)
+	c.Request.Host = "example.com"
+
+	router.Handle(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "<p>No missing in marketing {{range 300000000}}{{end}}</p>", w.Body.String())
+	assert.Equal(t, "sandbox", w.Header().Get("Content-Security-Policy"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortLinkRouter_Handle_UnknownNamespaceUsesDomainFallback(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	cfg := &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+		NotFoundFallbacks: map[string]config.NotFoundFallback{
+			"example.com": {Action: "json"},
+		},
+	}
+	handler := NewRedirectHandler(db, cfg)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "namespaces"`).
+		WithArgs("example.com", "missing").
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "missing").
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/missing/abc123", nil)
+	c.Request.Host = "example.com"
+
+	newTestRouterFor(handler).Handle(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, `{"error":"Namespace not found"}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNamespaceNotFoundPage_EscapesValues(t *testing.T) {
+	data := notFoundPageData{Domain: "example.com", Namespace: "team", Slug: `<script>"x"</script>`}
+
+	page := namespaceNotFoundPage(`<a title="{{slug}}">{{domain}}/{{namespace}}/{{slug}}</a>`, data)
+
+	assert.Equal(t, `<a title="&lt;script&gt;&#34;x&#34;&lt;/script&gt;">example.com/team/&lt;script&gt;&#34;x&#34;&lt;/script&gt;</a>`, string(page))
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"openshortpath/server/models"
+)
+
+// Open Graph field limits
+const (
+	maxOpenGraphTitleLength       = 300
+	maxOpenGraphDescriptionLength = 1000
+)
+
+// openGraphTemplate is the preview page served to link preview crawlers
+var openGraphTemplate = template.Must(template.New("open-graph").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+{{if .Destination}}<meta http-equiv="refresh" content="0;url={{.Destination}}">
+{{end}}{{if .Title}}<title>{{.Title}}</title>
+<meta property="og:title" content="{{.Title}}">
+<meta name="twitter:title" content="{{.Title}}">
+{{end}}{{if .Description}}<meta name="description" content="{{.Description}}">
+<meta property="og:description" content="{{.Description}}">
+<meta name="twitter:description" content="{{.Description}}">
+{{end}}{{if .ImageURL}}<meta property="og:image" content="{{.ImageURL}}">
+<meta name="twitter:image" content="{{.ImageURL}}">
+<meta name="twitter:card" content="summary_large_image">
+{{else}}<meta name="twitter:card" content="summary">
+{{end}}<meta property="og:type" content="website">
+<meta property="og:url" content="{{.URL}}">
+</head>
+<body>
+{{if .Destination}}<p><a href="{{.Destination}}">Continue</a></p>
+{{end}}</body>
+</html>
+`))
+
+// validateOpenGraph checks the lengths of the preview texts and that the image is a web URL
+func validateOpenGraph(openGraph models.OpenGraph) error {
+	if utf8.RuneCountInString(openGraph.Title) > maxOpenGraphTitleLength {
+		return fmt.Errorf("open_graph.title must be %d characters or less", maxOpenGraphTitleLength)
+	}
+	if utf8.RuneCountInString(openGraph.Description) > maxOpenGraphDescriptionLength {
+		return fmt.Errorf("open_graph.description must be %d characters or less", maxOpenGraphDescriptionLength)
+	}
+	if openGraph.ImageURL != "" {
+		parsed, err := url.Parse(openGraph.ImageURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("open_graph.image_url must be an http or https URL")
+		}
+	}
+	return nil
+}
+
+// renderOpenGraphPage writes the preview page for a short URL that refreshes to destination
+// shortLink is the public URL of the short link, used as og:url
+// An empty destination leaves out the refresh and the continue link
+func renderOpenGraphPage(c *gin.Context, openGraph models.OpenGraph, shortLink, destination string) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+	openGraphTemplate.Execute(c.Writer, gin.H{
+		"Title":       openGraph.Title,
+		"Description": openGraph.Description,
+		"ImageURL":    openGraph.ImageURL,
+		"URL":         shortLink,
+		"Destination": destination,
+	})
+}
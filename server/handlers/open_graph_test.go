@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"openshortpath/server/config"
+	"openshortpath/server/models"
+)
+
+func TestValidateOpenGraph(t *testing.T) {
+	tests := []struct {
+		name        string
+		openGraph   models.OpenGraph
+		expectError bool
+	}{
+		{"empty", models.OpenGraph{}, false},
+		{"all fields", models.OpenGraph{Title: "Launch", Description: "Our new product", ImageURL: "https://cdn.example.com/launch.png"}, false},
+		{"title too long", models.OpenGraph{Title: strings.Repeat("a", maxOpenGraphTitleLength+1)}, true},
+		{"description too long", models.OpenGraph{Description: strings.Repeat("a", maxOpenGraphDescriptionLength+1)}, true},
+		{"relative image", models.OpenGraph{ImageURL: "/launch.png"}, true},
+		{"javascript image", models.OpenGraph{ImageURL: "javascript:alert(1)"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateOpenGraph(tt.openGraph)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestShortLinkRouter_Handle_OpenGraph(t *testing.T) {
+	tests := []struct {
+		name         string
+		userAgent    string
+		maxClicks    interface{}
+		expectedCode int
+	}{
+		{"crawler gets preview", "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", nil, http.StatusOK},
+		{"crawler of capped link gets preview without destination", "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", 10, http.StatusOK},
+		{"visitor gets redirect", "", 10, http.StatusFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, sqlDB := setupTestDB(t)
+			defer sqlDB.Close()
+
+			cfg := &config.Config{
+				AvailableShortDomains: []string{"example.com"},
+			}
+			handler := NewRedirectHandler(db, cfg)
+
+			id := uuid.New().String()
+			now := time.Now()
+			mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+				WithArgs("example.com", "abc123").
+				WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "namespace_id", "redirect_type", "max_clicks", "click_count", "og_title", "og_description", "og_image_url", "created_at", "updated_at"}).
+					AddRow(id, "example.com", "abc123", "https://example.com/target", "", nil, 302, tt.maxClicks, 0, `Launch "day"`, "Our new product", "https://cdn.example.com/launch.png", now, now))
+			expectNoDeviceRules(mock)
+			if tt.expectedCode == http.StatusFound {
+				// Only real visits use up the click allowance
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "short_urls"`).
+					WithArgs(1, id, 10).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/abc123", nil)
+			c.Request.Host = "example.com"
+			c.Request.Header.Set("User-Agent", tt.userAgent)
+
+			newTestRouterFor(handler).Handle(c)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedCode == http.StatusOK {
+				body := w.Body.String()
+				assert.Contains(t, body, `<meta property="og:title" content="Launch &#34;day&#34;">`)
+				assert.Contains(t, body, `<meta property="og:description" content="Our new product">`)
+				assert.Contains(t, body, `<meta property="og:image" content="https://cdn.example.com/launch.png">`)
+				assert.Contains(t, body, `<meta property="og:url" content="https://example.com/abc123">`)
+				if tt.maxClicks == nil {
+					assert.Contains(t, body, `<meta http-equiv="refresh" content="0;url=https://example.com/target">`)
+				} else {
+					assert.NotContains(t, body, "https://example.com/target")
+				}
+			} else {
+				assert.Equal(t, "https://example.com/target", w.Header().Get("Location"))
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestShortLinkRouter_Handle_CrawlerWithoutOpenGraph(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	cfg := &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	}
+	handler := NewRedirectHandler(db, cfg)
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "abc123").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "namespace_id", "redirect_type", "created_at", "updated_at"}).
+			AddRow(uuid.New().String(), "example.com", "abc123", "https://example.com/target", "", nil, 302, now, now))
+	expectNoDeviceRules(mock)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/abc123", nil)
+	c.Request.Host = "example.com"
+	c.Request.Header.Set("User-Agent", "Twitterbot/1.0")
+
+	newTestRouterFor(handler).Handle(c)
+
+	// Without a preview configured, crawlers follow the redirect and see the destination's own tags
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.com/target", w.Header().Get("Location"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"net/url"
+	"strings"
+)
+
+// splitShortLinkPath splits a request path into its segments
+// Returns false for the root path and for paths with empty segments (e.g. /a//b)
+func splitShortLinkPath(path string) ([]string, bool) {
+	trimmed := strings.Trim(path, "/")
+	if trimmed == "" {
+		return nil, false
+	}
+	segments := strings.Split(trimmed, "/")
+	for _, segment := range segments {
+		if segment == "" {
+			return nil, false
+		}
+	}
+	return segments, true
+}
+
+// isSafeForwardedSegment checks a path segment that is about to be appended to a destination
+// Dot segments could climb out of the destination's path, and backslashes and control
+// characters are treated as separators or stripped by some servers
+func isSafeForwardedSegment(segment string) bool {
+	if segment == "." || segment == ".." {
+		return false
+	}
+	for _, r := range segment {
+		if r == '\\' || r < 0x20 || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// escapeForwardedPath validates and escapes the segments left after a short link
+// Returns false if any segment is unsafe to forward
+func escapeForwardedPath(segments []string, trailingSlash bool) (string, bool) {
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		if !isSafeForwardedSegment(segment) {
+			return "", false
+		}
+		escaped[i] = url.PathEscape(segment)
+	}
+	forwardedPath := strings.Join(escaped, "/")
+	if trailingSlash {
+		forwardedPath += "/"
+	}
+	return forwardedPath, true
+}
+
+// appendForwardedPath appends an escaped path suffix to the destination's path,
+// keeping the destination's query and fragment
+func appendForwardedPath(destination, forwardedPath string) string {
+	if forwardedPath == "" {
+		return destination
+	}
+
+	parsed, err := url.Parse(destination)
+	if err != nil || parsed.Opaque != "" {
+		return destination
+	}
+
+	escapedPath := strings.TrimSuffix(parsed.EscapedPath(), "/") + "/" + forwardedPath
+	path, err := url.PathUnescape(escapedPath)
+	if err != nil {
+		return destination
+	}
+	parsed.Path = path
+	parsed.RawPath = escapedPath
+	return parsed.String()
+}
+
+// resolveShortLinkPath finds the short link for a path's segments
+// /namespace/slug is tried before /slug. A link matches exactly, or as a prefix if it forwards
+// paths, in which case the rest of the path is returned in Route.ForwardedPath
+// If nothing matches, the returned RouteLinkNotFound describes the /namespace/slug (or /slug) reading
+func (h *RedirectHandler) resolveShortLinkPath(host string, segments []string, trailingSlash bool) (Route, error) {
+	type candidate struct {
+		namespace string
+		slug      string
+		rest      []string
+	}
+	var candidates []candidate
+	if len(segments) >= 2 {
+		candidates = append(candidates, candidate{segments[0], segments[1], segments[2:]})
+	}
+	candidates = append(candidates, candidate{"", segments[0], segments[1:]})
+
+	notFound := Route{
+		Kind:      RouteLinkNotFound,
+		Namespace: candidates[0].namespace,
+		Slug:      candidates[0].slug,
+	}
+	for i, cand := range candidates {
+		forwardedPath, safe := "", true
+		if len(cand.rest) > 0 {
+			forwardedPath, safe = escapeForwardedPath(cand.rest, trailingSlash)
+		}
+		// The first reading is looked up even if its rest is unsafe, so the 404 still knows its namespace
+		if !safe && i > 0 {
+			continue
+		}
+
+		entry, err := h.lookupShortURL(host, cand.namespace, cand.slug)
+		if err != nil {
+			return Route{}, err
+		}
+		if i == 0 {
+			notFound.Entry = entry
+		}
+		if !safe || entry.ShortURL == nil || (forwardedPath != "" && !entry.ShortURL.ForwardPath) {
+			continue
+		}
+
+		return Route{
+			Kind:          RouteShortLink,
+			Namespace:     cand.namespace,
+			Slug:          cand.slug,
+			Entry:         entry,
+			ForwardedPath: forwardedPath,
+		}, nil
+	}
+
+	// The first reading may have found a link that doesn't forward paths, which is still a 404
+	notFound.Entry.ShortURL = nil
+	return notFound, nil
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestEscapeForwardedPath(t *testing.T) {
+	tests := []struct {
+		name          string
+		segments      []string
+		trailingSlash bool
+		expected      string
+		expectOK      bool
+	}{
+		{"plain", []string{"getting-started", "install"}, false, "getting-started/install", true},
+		{"trailing slash", []string{"guides"}, true, "guides/", true},
+		{"escaped characters", []string{"a b", "c?d#e"}, false, "a%20b/c%3Fd%23e", true},
+		{"dot dot", []string{"..", "etc"}, false, "", false},
+		{"dot", []string{"."}, false, "", false},
+		{"backslash", []string{`..\etc`}, false, "", false},
+		{"control character", []string{"a\nb"}, false, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forwardedPath, ok := escapeForwardedPath(tt.segments, tt.trailingSlash)
+			assert.Equal(t, tt.expectOK, ok)
+			assert.Equal(t, tt.expected, forwardedPath)
+		})
+	}
+}
+
+func TestAppendForwardedPath(t *testing.T) {
+	tests := []struct {
+		name          string
+		destination   string
+		forwardedPath string
+		expected      string
+	}{
+		{"nothing to forward", "https://docs.example.com/v2?ref=short", "", "https://docs.example.com/v2?ref=short"},
+		{"host only", "https://docs.example.com", "getting-started/install", "https://docs.example.com/getting-started/install"},
+		{"destination path with trailing slash", "https://docs.example.com/v2/", "install", "https://docs.example.com/v2/install"},
+		{"keeps query and fragment", "https://docs.example.com/v2?ref=short#top", "install", "https://docs.example.com/v2/install?ref=short#top"},
+		{"keeps escaping", "https://example.com/a%2Fb", "c%20d", "https://example.com/a%2Fb/c%20d"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, appendForwardedPath(tt.destination, tt.forwardedPath))
+		})
+	}
+}
+
+func TestShortLinkRouter_Handle_ForwardPath(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	router := newTestShortLinkRouter(db)
+
+	now := time.Now()
+	// /gh/openshortpath/server is first read as namespace "gh" and slug "openshortpath"
+	mock.ExpectQuery(`SELECT (.+) FROM "namespaces"`).
+		WithArgs("example.com", "gh").
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "gh").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "namespace_id", "redirect_type", "forward_path", "created_at", "updated_at"}).
+			AddRow(uuid.New().String(), "example.com", "gh", "https://github.com/", "", nil, 302, true, now, now))
+	expectNoDeviceRules(mock)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/gh/openshortpath/server?tab=readme", nil)
+	c.Request.Host = "example.com"
+
+	router.Handle(c)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://github.com/openshortpath/server", w.Header().Get("Location"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortLinkRouter_Resolve_ForwardPathDisabled(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	router := newTestShortLinkRouter(db)
+
+	id := uuid.New().String()
+	now := time.Now()
+	columns := []string{"id", "domain", "slug", "url", "user_id", "namespace_id", "forward_path", "created_at", "updated_at"}
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "abc123").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(id, "example.com", "abc123", "https://example.com/target", "", nil, false, now, now))
+	expectNoDeviceRules(mock)
+	mock.ExpectQuery(`SELECT (.+) FROM "namespaces"`).
+		WithArgs("example.com", "abc123").
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "abc123").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(id, "example.com", "abc123", "https://example.com/target", "", nil, false, now, now))
+	expectNoDeviceRules(mock)
+
+	// The exact match is found, then a longer path through the same link is a 404
+	route, err := router.Resolve("example.com", "/abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, RouteShortLink, route.Kind)
+
+	route, err = router.Resolve("example.com", "/abc123/extra")
+	assert.NoError(t, err)
+	assert.Equal(t, RouteLinkNotFound, route.Kind)
+	assert.Nil(t, route.Entry.ShortURL)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortLinkRouter_Resolve_ForwardPathTraversal(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	router := newTestShortLinkRouter(db)
+
+	// Only the namespace reading is looked up; the slug reading would forward "../secret"
+	mock.ExpectQuery(`SELECT (.+) FROM "namespaces"`).
+		WithArgs("example.com", "gh").
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	route, err := router.Resolve("example.com", "/gh/../secret")
+	assert.NoError(t, err)
+	assert.Equal(t, RouteLinkNotFound, route.Kind)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortLinkRouter_Resolve_ForwardPathTraversalInNamespace(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	router := newTestShortLinkRouter(db)
+
+	// The namespace reading would forward "../secret" but is still looked up; the slug reading is skipped
+	namespaceID := uuid.New().String()
+	now := time.Now()
+	mock.ExpectQuery(`SELECT (.+) FROM "namespaces"`).
+		WithArgs("example.com", "marketing").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "domain", "user_id", "created_at", "updated_at"}).
+			AddRow(namespaceID, "marketing", "example.com", "user123", now, now))
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", namespaceID, "promo").
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	route, err := router.Resolve("example.com", "/marketing/promo/../secret")
+	assert.NoError(t, err)
+	assert.Equal(t, RouteLinkNotFound, route.Kind)
+	assert.Equal(t, "marketing", route.Namespace)
+	assert.Equal(t, "promo", route.Slug)
+	if assert.NotNil(t, route.Entry.Namespace) {
+		assert.Equal(t, namespaceID, route.Entry.Namespace.ID)
+	}
+	assert.Nil(t, route.Entry.ShortURL)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"openshortpath/server/models"
+	"openshortpath/server/utils"
+)
+
+// qrCodeSuffix marks a public request for a short link's QR code, e.g. /abc123.qr
+const qrCodeSuffix = ".qr"
+
+// shortLinkURL builds the public URL of a short link
+// Local development domains use http, everything else https
+func shortLinkURL(domain, namespaceName, slug string) string {
+	host := domain
+	if h, _, err := net.SplitHostPort(domain); err == nil {
+		host = h
+	}
+	scheme := "https"
+	if host == "localhost" || host == "127.0.0.1" || strings.HasSuffix(host, ".localhost") {
+		scheme = "http"
+	}
+
+	path := "/" + url.PathEscape(slug)
+	if namespaceName != "" {
+		path = "/" + url.PathEscape(namespaceName) + path
+	}
+	return scheme + "://" + domain + path
+}
+
+// parseQRCodeOptions reads the format and rendering options from the query string
+// Supported parameters: format (png or svg), size, level (L, M, Q or H), margin, fg and bg (#rrggbb)
+func parseQRCodeOptions(c *gin.Context) (string, utils.QRCodeOptions, error) {
+	opts := utils.DefaultQRCodeOptions()
+
+	format := strings.ToLower(c.DefaultQuery("format", "png"))
+	if format != "png" && format != "svg" {
+		return "", opts, fmt.Errorf("format must be 'png' or 'svg'")
+	}
+
+	if sizeStr := c.Query("size"); sizeStr != "" {
+		size, err := strconv.Atoi(sizeStr)
+		if err != nil || size < utils.QRCodeMinSize || size > utils.QRCodeMaxSize {
+			return "", opts, fmt.Errorf("size must be between %d and %d", utils.QRCodeMinSize, utils.QRCodeMaxSize)
+		}
+		opts.Size = size
+	}
+
+	if level := c.Query("level"); level != "" {
+		level = strings.ToUpper(level)
+		if !utils.IsValidQRCodeLevel(level) {
+			return "", opts, fmt.Errorf("level must be 'L', 'M', 'Q' or 'H'")
+		}
+		opts.Level = level
+	}
+
+	if marginStr := c.Query("margin"); marginStr != "" {
+		margin, err := strconv.Atoi(marginStr)
+		if err != nil || margin < 0 || margin > utils.QRCodeMaxMargin {
+			return "", opts, fmt.Errorf("margin must be between 0 and %d", utils.QRCodeMaxMargin)
+		}
+		opts.Margin = margin
+	}
+
+	if fg := c.Query("fg"); fg != "" {
+		foreground, err := utils.ParseHexColor(fg)
+		if err != nil {
+			return "", opts, fmt.Errorf("fg: %v", err)
+		}
+		opts.Foreground = foreground
+	}
+
+	if bg := c.Query("bg"); bg != "" {
+		background, err := utils.ParseHexColor(bg)
+		if err != nil {
+			return "", opts, fmt.Errorf("bg: %v", err)
+		}
+		opts.Background = background
+	}
+
+	return format, opts, nil
+}
+
+// renderQRCode writes content as a QR code using the options in the query string
+func renderQRCode(c *gin.Context, content string) {
+	format, opts, err := parseQRCodeOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var (
+		body        []byte
+		contentType string
+	)
+	if format == "svg" {
+		body, err = utils.RenderQRCodeSVG(content, opts)
+		contentType = "image/svg+xml"
+	} else {
+		body, err = utils.RenderQRCodePNG(content, opts)
+		contentType = "image/png"
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to render QR code",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.Data(http.StatusOK, contentType, body)
+}
+
+// GetQRCode handles GET /api/v1/short-urls/:id/qr
+// Renders the short URL (domain, namespace and slug) as a PNG or SVG QR code
+func (h *ShortURLsHandler) GetQRCode(c *gin.Context) {
+	shortURL, ok := loadOwnedShortURL(c, h.db)
+	if !ok {
+		return
+	}
+
+	namespaceName := ""
+	if shortURL.NamespaceID != nil {
+		var namespace models.Namespace
+		result := h.db.Where("id = ?", *shortURL.NamespaceID).First(&namespace)
+		if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"details": result.Error.Error(),
+			})
+			return
+		}
+		namespaceName = namespace.Name
+	}
+
+	c.Header("Cache-Control", "private, no-cache")
+	renderQRCode(c, shortLinkURL(shortURL.Domain, namespaceName, shortURL.Slug))
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"openshortpath/server/config"
+	"openshortpath/server/constants"
+)
+
+func TestShortLinkURL(t *testing.T) {
+	assert.Equal(t, "https://example.com/abc123", shortLinkURL("example.com", "", "abc123"))
+	assert.Equal(t, "https://example.com/marketing/abc123", shortLinkURL("example.com", "marketing", "abc123"))
+	assert.Equal(t, "http://localhost:3000/abc123", shortLinkURL("localhost:3000", "", "abc123"))
+	assert.Equal(t, "https://example.com/a%20b", shortLinkURL("example.com", "", "a b"))
+}
+
+func TestShortURLsHandler_GetQRCode(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewShortURLsHandler(db, &config.Config{AvailableShortDomains: []string{"example.com"}})
+	userID := uuid.New().String()
+	id := uuid.New().String()
+	namespaceID := uuid.New().String()
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs(id, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "namespace_id", "created_at", "updated_at"}).
+			AddRow(id, "example.com", "abc123", "https://example.com/target", userID, namespaceID, now, now))
+	mock.ExpectQuery(`SELECT (.+) FROM "namespaces"`).
+		WithArgs(namespaceID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "domain", "user_id", "created_at", "updated_at"}).
+			AddRow(namespaceID, "marketing", "example.com", userID, now, now))
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, userID)
+	c.Params = gin.Params{gin.Param{Key: "id", Value: id}}
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/short-urls/"+id+"/qr?format=svg&size=512&level=h&margin=2&fg=%231a56db", nil)
+
+	handler.GetQRCode(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(w.Body.String(), `<svg xmlns="http://www.w3.org/2000/svg" width="512" height="512"`))
+	assert.Contains(t, w.Body.String(), `fill="#1a56db"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortURLsHandler_GetQRCode_InvalidOptions(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"format", "format=gif"},
+		{"size", "size=10000"},
+		{"level", "level=X"},
+		{"margin", "margin=-1"},
+		{"color", "bg=blue"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, sqlDB := setupTestDB(t)
+			defer sqlDB.Close()
+
+			handler := NewShortURLsHandler(db, &config.Config{AvailableShortDomains: []string{"example.com"}})
+			userID := uuid.New().String()
+			id := uuid.New().String()
+			now := time.Now()
+
+			mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+				WithArgs(id, userID).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "namespace_id", "created_at", "updated_at"}).
+					AddRow(id, "example.com", "abc123", "https://example.com/target", userID, nil, now, now))
+
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Set(constants.ContextKeyUserID, userID)
+			c.Params = gin.Params{gin.Param{Key: "id", Value: id}}
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/short-urls/"+id+"/qr?"+tt.query, nil)
+
+			handler.GetQRCode(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestShortLinkRouter_Handle_QRCode(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	router := newTestShortLinkRouter(db)
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "abc123").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "namespace_id", "created_at", "updated_at"}).
+			AddRow(uuid.New().String(), "example.com", "abc123", "https://example.com/target", "", nil, now, now))
+	expectNoDeviceRules(mock)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/abc123.qr", nil)
+	c.Request.Host = "example.com"
+
+	router.Handle(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(w.Body.String(), "\x89PNG"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
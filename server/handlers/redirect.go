@@ -1,14 +1,21 @@
 package handlers
 
 import (
+	"html/template"
+	"math/rand/v2"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
 	"openshortpath/server/config"
 	"openshortpath/server/models"
+	"openshortpath/server/services"
+	"openshortpath/server/utils"
 )
 
 // ReservedNamespaceNames contains namespace names that cannot be used
@@ -33,8 +40,13 @@ func IsReservedNamespaceName(name string) bool {
 }
 
 type RedirectHandler struct {
-	db  *gorm.DB
-	cfg *config.Config
+	db            *gorm.DB
+	cfg           *config.Config
+	clickRecorder *services.ClickRecorder
+	countryLookup services.CountryLookup
+	redirectCache *services.RedirectCache
+	// notFoundTemplates holds the parsed templates of per-domain "html" not-found fallbacks
+	notFoundTemplates map[string]*template.Template
 }
 
 func NewRedirectHandler(db *gorm.DB, cfg *config.Config) *RedirectHandler {
@@ -44,122 +56,356 @@ func NewRedirectHandler(db *gorm.DB, cfg *config.Config) *RedirectHandler {
 	}
 }
 
-// Redirect handles redirects for both namespace and non-namespace URLs
-// It checks the path to determine if it's /:slug or /:namespace/:slug
-func (h *RedirectHandler) Redirect(c *gin.Context) {
-	// Extract hostname from request
-	hostname := c.Request.Host
+// SetClickRecorder sets the click recorder used to record successful redirects
+// If no recorder is set, redirects are not recorded
+func (h *RedirectHandler) SetClickRecorder(recorder *services.ClickRecorder) {
+	h.clickRecorder = recorder
+}
 
-	// Validate domain
-	if !isValidDomain(hostname, h.cfg.AvailableShortDomains) {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Domain not found",
-		})
-		return
+// SetRedirectCache sets the cache used for short URL lookups
+// If no cache is set, every redirect queries the database
+func (h *RedirectHandler) SetRedirectCache(cache *services.RedirectCache) {
+	h.redirectCache = cache
+}
+
+// SetCountryLookup sets the GeoIP lookup used to evaluate per-link country rules
+// If no lookup is set, country rules are ignored
+func (h *RedirectHandler) SetCountryLookup(lookup services.CountryLookup) {
+	h.countryLookup = lookup
+}
+
+// resolveDestination picks the URL to send this visitor to
+// A matching device rule wins, then a matching country rule, then a weighted A/B split,
+// otherwise the short URL's own URL is used. perVisitor reports whether the pick depends on
+// the visitor or the link's state, so other visits may go elsewhere
+func (h *RedirectHandler) resolveDestination(c *gin.Context, entry services.RedirectCacheEntry, userAgent utils.UserAgentInfo) (destination string, perVisitor bool) {
+	shortURL := entry.ShortURL
+
+	if utils.TargetablePlatforms[userAgent.Platform] {
+		for _, rule := range entry.DeviceRules {
+			if rule.Platform == userAgent.Platform {
+				return rule.URL, true
+			}
+		}
 	}
 
-	// Parse the path to determine if we have a namespace or not
-	path := c.Request.URL.Path
-	
-	// Skip dashboard and API routes - these should be handled by other routes
-	if strings.HasPrefix(path, "/dashboard") || strings.HasPrefix(path, "/api") {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Route not found",
-		})
+	if h.countryLookup != nil && len(entry.GeoRules) > 0 {
+		if country := h.countryLookup.LookupCountry(services.GetClientIP(c)); country != "" {
+			for _, rule := range entry.GeoRules {
+				if rule.CountryCode == country {
+					return rule.URL, true
+				}
+			}
+		}
+	}
+
+	if len(shortURL.Destinations) > 0 {
+		return h.pickSplitDestination(c, shortURL), true
+	}
+
+	// While the health checker finds the destination down, send visitors to the fallback instead
+	if shortURL.Health.IsBroken() && shortURL.HealthFallbackURL != "" {
+		return shortURL.HealthFallbackURL, true
+	}
+
+	return shortURL.URL, false
+}
+
+// splitCookieMaxAge is how long a sticky A/B variant is remembered, in seconds
+const splitCookieMaxAge = 30 * 24 * 60 * 60
+
+// splitCookieName returns the cookie that remembers a visitor's A/B variant for a short URL
+func splitCookieName(shortURL *models.ShortURL) string {
+	return "osp_split_" + shortURL.ID
+}
+
+// pickSplitDestination picks one of the short URL's weighted destinations
+// Sticky links reuse the variant stored in the visitor's cookie and remember new picks
+func (h *RedirectHandler) pickSplitDestination(c *gin.Context, shortURL *models.ShortURL) string {
+	destinations := shortURL.Destinations
+
+	if shortURL.StickyDestinations {
+		if value, err := c.Cookie(splitCookieName(shortURL)); err == nil {
+			if index, err := strconv.Atoi(value); err == nil && index >= 0 && index < len(destinations) {
+				return destinations[index].URL
+			}
+		}
+	}
+
+	index := len(destinations) - 1
+	if total := destinations.TotalWeight(); total > 0 {
+		index = destinations.Choose(rand.IntN(total))
+	}
+
+	if shortURL.StickyDestinations {
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(splitCookieName(shortURL), strconv.Itoa(index), splitCookieMaxAge, "/", "", false, true)
+	}
+
+	return destinations[index].URL
+}
+
+// appendQueryParams adds the visitor's query parameters (if the link forwards them) and any missing
+// UTM defaults to the destination. A link's UTM defaults win over its namespace's
+// The destination is returned unchanged if there is nothing to add
+func appendQueryParams(c *gin.Context, destination string, shortURL *models.ShortURL, namespace *models.Namespace) string {
+	utmDefaults := shortURL.UTMDefaults.Params()
+	if namespace != nil {
+		for key, value := range namespace.UTMDefaults.Params() {
+			if _, ok := utmDefaults[key]; !ok {
+				utmDefaults[key] = value
+			}
+		}
+	}
+
+	incoming := c.Request.URL.Query()
+	if len(utmDefaults) == 0 && (!shortURL.ForwardQuery || len(incoming) == 0) {
+		return destination
+	}
+
+	parsed, err := url.Parse(destination)
+	if err != nil {
+		return destination
+	}
+
+	query := parsed.Query()
+	changed := false
+
+	// Incoming parameters replace parameters of the same name in the destination
+	if shortURL.ForwardQuery {
+		for key, values := range incoming {
+			query[key] = values
+			changed = true
+		}
+	}
+
+	for key, value := range utmDefaults {
+		if query.Get(key) == "" {
+			query.Set(key, value)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return destination
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// redirectTo records the click and issues the redirect to the target of the entry's short URL
+// The entry's namespace is the one the link was resolved through, or nil for links without one
+// forwardedPath is the escaped rest of the visited path, appended to the destination
+func (h *RedirectHandler) redirectTo(c *gin.Context, entry services.RedirectCacheEntry, forwardedPath string) {
+	// Cached entries are shared between requests, so work on a copy
+	shortURLCopy := *entry.ShortURL
+	shortURL := &shortURLCopy
+	entry.ShortURL = shortURL
+	namespace := entry.Namespace
+
+	now := time.Now().UTC()
+	if shortURL.IsExpired(now) {
+		h.respondExpired(c)
 		return
 	}
-	
-	pathParts := strings.Split(strings.Trim(path, "/"), "/")
 
-	// If we have 2 parts, it's namespace/slug
-	// If we have 1 part, it's just slug
-	if len(pathParts) == 2 {
-		// Handle namespace/slug pattern
-		namespaceName := pathParts[0]
-		slug := pathParts[1]
+	// Password-protected links only redirect after a successful unlock
+	unlocked := false
+	if shortURL.HasPassword {
+		if !h.unlockLink(c, shortURL) {
+			return
+		}
+		unlocked = true
+	}
+
+	userAgent := utils.ParseUserAgent(c.Request.UserAgent())
+	destination, perVisitor := h.resolveDestination(c, entry, userAgent)
+	destination = appendForwardedPath(destination, forwardedPath)
+	destination = appendQueryParams(c, destination, shortURL, namespace)
+
+	// Link preview crawlers get the link's Open Graph tags instead of a redirect and don't count as clicks
+	// Expired and used up links were turned away above. Capped links leave the destination out of the
+	// page, so a crawler's uncounted visit can't be used to reach it
+	if !shortURL.OpenGraph.IsEmpty() && utils.IsLinkPreviewCrawler(c.Request.UserAgent()) {
+		namespaceName := ""
+		if namespace != nil {
+			namespaceName = namespace.Name
+		}
+		previewDestination := destination
+		if shortURL.MaxClicks != nil {
+			previewDestination = ""
+		}
+		renderOpenGraphPage(c, shortURL.OpenGraph, shortLinkURL(shortURL.Domain, namespaceName, shortURL.Slug), previewDestination)
+		return
+	}
 
-		if namespaceName == "" || slug == "" {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "Namespace or slug not found",
+	// Links with a click allowance are counted synchronously so the cap can't be overshot
+	counted := false
+	if shortURL.MaxClicks != nil {
+		result := h.db.Model(&models.ShortURL{}).
+			Where("id = ? AND click_count < ?", shortURL.ID, *shortURL.MaxClicks).
+			UpdateColumn("click_count", gorm.Expr("click_count + ?", 1))
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"details": result.Error.Error(),
 			})
 			return
 		}
+		if result.RowsAffected == 0 {
+			// Another visitor used up the last click
+			h.respondExpired(c)
+			return
+		}
+		counted = true
+	}
+
+	if h.clickRecorder != nil {
+		h.clickRecorder.Record(models.ClickEvent{
+			ShortURLID:  shortURL.ID,
+			ClickedAt:   now,
+			Referrer:    c.Request.Referer(),
+			UserAgent:   c.Request.UserAgent(),
+			IPAddress:   services.GetClientIP(c),
+			Platform:    userAgent.Platform,
+			DeviceType:  userAgent.DeviceType,
+			Browser:     userAgent.Browser,
+			Destination: destination,
+			Counted:     counted,
+		})
+	}
+
+	// Mobile visitors of deep links get a bridge page that tries the app before the web
+	if appURL := deepLinkAppURL(shortURL.DeepLink, userAgent); appURL != "" {
+		fallbackURL := destination
+		if shortURL.DeepLink.FallbackURL != "" {
+			fallbackURL = appendQueryParams(c, appendForwardedPath(shortURL.DeepLink.FallbackURL, forwardedPath), shortURL, namespace)
+		}
+		renderDeepLinkBridge(c, appURL, fallbackURL)
+		return
+	}
+
+	if unlocked {
+		// Redirect the unlock POST as a GET and keep the destination out of caches
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(http.StatusSeeOther, destination)
+		return
+	}
+
+	// Browsers keep permanent redirects, so only a fixed destination may be redirected to permanently.
+	// Rules, splits and health fallbacks pick per visit, and capped or expiring links must stop working
+	if perVisitor || shortURL.MaxClicks != nil || shortURL.ExpiresAt != nil {
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(temporaryRedirectStatus(h.redirectStatus(shortURL)), destination)
+		return
+	}
+
+	c.Redirect(h.redirectStatus(shortURL), destination)
+}
+
+// temporaryRedirectStatus returns the temporary counterpart of a permanent redirect status
+// 308 becomes 307 so the request method is still kept; other permanent redirects become 302
+func temporaryRedirectStatus(status int) int {
+	switch status {
+	case http.StatusPermanentRedirect:
+		return http.StatusTemporaryRedirect
+	case http.StatusMovedPermanently:
+		return http.StatusFound
+	default:
+		return status
+	}
+}
+
+// respondExpired redirects to the configured fallback URL for expired links, or returns 410 Gone
+func (h *RedirectHandler) respondExpired(c *gin.Context) {
+	if h.cfg.ExpiredLinkURL != "" {
+		c.Redirect(http.StatusFound, h.cfg.ExpiredLinkURL)
+		return
+	}
+	c.JSON(http.StatusGone, gin.H{
+		"error": "Short URL has expired",
+	})
+}
+
+// redirectStatus returns the HTTP status to redirect with for a short URL
+// Falls back to the configured default, then to 301
+func (h *RedirectHandler) redirectStatus(shortURL *models.ShortURL) int {
+	if config.IsValidRedirectType(shortURL.RedirectType) {
+		return shortURL.RedirectType
+	}
+	if config.IsValidRedirectType(h.cfg.DefaultRedirectType) {
+		return h.cfg.DefaultRedirectType
+	}
+	return http.StatusMovedPermanently
+}
+
+// GetCacheStats handles GET /api/v1/__admin/redirect-cache
+// Returns the redirect cache's size and hit/miss counters
+func (h *RedirectHandler) GetCacheStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": h.redirectCache != nil,
+		"stats":   h.redirectCache.Stats(),
+	})
+}
+
+// lookupShortURL finds the short URL for a host, namespace name ("" for none) and slug
+// Results, including not-found results, are served from and stored in the redirect cache
+// Found links come with their device rules, and their country rules when a country lookup is set
+func (h *RedirectHandler) lookupShortURL(hostname, namespaceName, slug string) (services.RedirectCacheEntry, error) {
+	key := services.RedirectCacheKey{Host: hostname, Namespace: namespaceName, Slug: slug}
+	if entry, ok := h.redirectCache.Get(key); ok {
+		return entry, nil
+	}
 
+	var entry services.RedirectCacheEntry
+	if namespaceName != "" {
 		// First, find the namespace by name and domain
 		var namespace models.Namespace
 		namespaceResult := h.db.Where("domain = ? AND name = ?", hostname, namespaceName).First(&namespace)
 		if namespaceResult.Error != nil {
 			if namespaceResult.Error == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusNotFound, gin.H{
-					"error": "Namespace not found",
-				})
-				return
+				h.redirectCache.Set(key, entry)
+				return entry, nil
 			}
-			// Database error
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Database error",
-				"details": namespaceResult.Error.Error(),
-			})
-			return
+			return entry, namespaceResult.Error
 		}
+		entry.Namespace = &namespace
 
 		// Query database for ShortURL matching domain, namespace_id, and slug
 		var shortURL models.ShortURL
 		result := h.db.Where("domain = ? AND namespace_id = ? AND slug = ?", hostname, namespace.ID, slug).First(&shortURL)
 		if result.Error != nil {
 			if result.Error == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusNotFound, gin.H{
-					"error": "Short URL not found",
-				})
-				return
+				h.redirectCache.Set(key, entry)
+				return entry, nil
 			}
-			// Database error
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Database error",
-				"details": result.Error.Error(),
-			})
-			return
+			return entry, result.Error
 		}
-
-		// Return 301 redirect to target URL
-		c.Redirect(http.StatusMovedPermanently, shortURL.URL)
-		return
-	} else if len(pathParts) == 1 {
-		// Handle single slug pattern (no namespace)
-		slug := pathParts[0]
-		if slug == "" {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "Slug not found",
-			})
-			return
-		}
-
+		entry.ShortURL = &shortURL
+	} else {
 		// Query database for ShortURL matching domain and slug (without namespace)
 		var shortURL models.ShortURL
 		result := h.db.Where("domain = ? AND slug = ? AND namespace_id IS NULL", hostname, slug).First(&shortURL)
 		if result.Error != nil {
 			if result.Error == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusNotFound, gin.H{
-					"error": "Short URL not found",
-				})
-				return
+				h.redirectCache.Set(key, entry)
+				return entry, nil
 			}
-			// Database error
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Database error",
-				"details": result.Error.Error(),
-			})
-			return
+			return entry, result.Error
 		}
+		entry.ShortURL = &shortURL
+	}
 
-		// Return 301 redirect to target URL
-		c.Redirect(http.StatusMovedPermanently, shortURL.URL)
-		return
+	// Targeting rules are cached with the link so redirects don't query them on every visit
+	if err := h.db.Where("short_url_id = ?", entry.ShortURL.ID).Find(&entry.DeviceRules).Error; err != nil {
+		return entry, err
+	}
+	if h.countryLookup != nil {
+		if err := h.db.Where("short_url_id = ?", entry.ShortURL.ID).Find(&entry.GeoRules).Error; err != nil {
+			return entry, err
+		}
 	}
 
-	// Invalid path format
-	c.JSON(http.StatusNotFound, gin.H{
-		"error": "Invalid path format",
-	})
+	h.redirectCache.Set(key, entry)
+	return entry, nil
 }
-
@@ -15,6 +15,8 @@ import (
 	"gorm.io/gorm"
 
 	"openshortpath/server/config"
+	"openshortpath/server/models"
+	"openshortpath/server/services"
 )
 
 func setupTestDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock, *sql.DB) {
@@ -33,7 +35,13 @@ func setupTestDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock, *sql.DB) {
 	return gormDB, mock, sqlDB
 }
 
-func TestRedirectHandler_Redirect_Success(t *testing.T) {
+// expectNoDeviceRules expects the device rule lookup that follows a found short URL, finding none
+func expectNoDeviceRules(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT (.+) FROM "device_rules" WHERE short_url_id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "short_url_id", "platform", "url", "created_at", "updated_at"}))
+}
+
+func TestShortLinkRouter_Handle_Success(t *testing.T) {
 	// Setup
 	db, mock, sqlDB := setupTestDB(t)
 	defer sqlDB.Close()
@@ -52,6 +60,7 @@ func TestRedirectHandler_Redirect_Success(t *testing.T) {
 	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
 		WithArgs("example.com", "abc123").
 		WillReturnRows(rows)
+	expectNoDeviceRules(mock)
 
 	// Setup Gin context
 	gin.SetMode(gin.TestMode)
@@ -62,7 +71,7 @@ func TestRedirectHandler_Redirect_Success(t *testing.T) {
 	c.Request.URL.Path = "/abc123"
 
 	// Execute
-	handler.Redirect(c)
+	newTestRouterFor(handler).Handle(c)
 
 	// Assert
 	assert.Equal(t, http.StatusMovedPermanently, w.Code)
@@ -70,7 +79,7 @@ func TestRedirectHandler_Redirect_Success(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestRedirectHandler_Redirect_InvalidDomain(t *testing.T) {
+func TestShortLinkRouter_Handle_InvalidDomain(t *testing.T) {
 	// Setup
 	db, _, sqlDB := setupTestDB(t)
 	defer sqlDB.Close()
@@ -90,13 +99,14 @@ func TestRedirectHandler_Redirect_InvalidDomain(t *testing.T) {
 	c.Request.URL.Path = "/abc123"
 
 	// Execute
-	handler.Redirect(c)
+	newTestRouterFor(handler).Handle(c)
 
-	// Assert
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	// Assert - other hosts are served the landing page
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "landing", w.Body.String())
 }
 
-func TestRedirectHandler_Redirect_EmptySlug(t *testing.T) {
+func TestShortLinkRouter_Handle_EmptySlug(t *testing.T) {
 	// Setup
 	db, _, sqlDB := setupTestDB(t)
 	defer sqlDB.Close()
@@ -116,13 +126,14 @@ func TestRedirectHandler_Redirect_EmptySlug(t *testing.T) {
 	c.Request.URL.Path = "/"
 
 	// Execute
-	handler.Redirect(c)
+	newTestRouterFor(handler).Handle(c)
 
-	// Assert
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	// Assert - the root path is the landing page
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "landing", w.Body.String())
 }
 
-func TestRedirectHandler_Redirect_SlugNotFound(t *testing.T) {
+func TestShortLinkRouter_Handle_SlugNotFound(t *testing.T) {
 	// Setup
 	db, mock, sqlDB := setupTestDB(t)
 	defer sqlDB.Close()
@@ -147,14 +158,15 @@ func TestRedirectHandler_Redirect_SlugNotFound(t *testing.T) {
 	c.Request.URL.Path = "/nonexistent"
 
 	// Execute
-	handler.Redirect(c)
+	newTestRouterFor(handler).Handle(c)
 
-	// Assert
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	// Assert - without a not-found fallback, unknown links are served by the landing page
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "landing", w.Body.String())
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestRedirectHandler_Redirect_DatabaseError(t *testing.T) {
+func TestShortLinkRouter_Handle_DatabaseError(t *testing.T) {
 	// Setup
 	db, mock, sqlDB := setupTestDB(t)
 	defer sqlDB.Close()
@@ -179,14 +191,14 @@ func TestRedirectHandler_Redirect_DatabaseError(t *testing.T) {
 	c.Request.URL.Path = "/abc123"
 
 	// Execute
-	handler.Redirect(c)
+	newTestRouterFor(handler).Handle(c)
 
 	// Assert
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestRedirectHandler_Redirect_LocalhostDomain(t *testing.T) {
+func TestShortLinkRouter_Handle_LocalhostDomain(t *testing.T) {
 	// Setup
 	db, mock, sqlDB := setupTestDB(t)
 	defer sqlDB.Close()
@@ -205,6 +217,7 @@ func TestRedirectHandler_Redirect_LocalhostDomain(t *testing.T) {
 	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
 		WithArgs("localhost:3000", "test123").
 		WillReturnRows(rows)
+	expectNoDeviceRules(mock)
 
 	// Setup Gin context
 	gin.SetMode(gin.TestMode)
@@ -215,7 +228,7 @@ func TestRedirectHandler_Redirect_LocalhostDomain(t *testing.T) {
 	c.Request.URL.Path = "/test123"
 
 	// Execute
-	handler.Redirect(c)
+	newTestRouterFor(handler).Handle(c)
 
 	// Assert
 	assert.Equal(t, http.StatusMovedPermanently, w.Code)
@@ -223,7 +236,7 @@ func TestRedirectHandler_Redirect_LocalhostDomain(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestRedirectHandler_RedirectWithNamespace_Success(t *testing.T) {
+func TestShortLinkRouter_Handle_NamespaceSuccess(t *testing.T) {
 	// Setup
 	db, mock, sqlDB := setupTestDB(t)
 	defer sqlDB.Close()
@@ -251,6 +264,7 @@ func TestRedirectHandler_RedirectWithNamespace_Success(t *testing.T) {
 	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
 		WithArgs("example.com", namespaceID, "abc123").
 		WillReturnRows(shortURLRows)
+	expectNoDeviceRules(mock)
 
 	// Setup Gin context
 	gin.SetMode(gin.TestMode)
@@ -261,7 +275,7 @@ func TestRedirectHandler_RedirectWithNamespace_Success(t *testing.T) {
 	c.Request.URL.Path = "/my-namespace/abc123"
 
 	// Execute
-	handler.Redirect(c)
+	newTestRouterFor(handler).Handle(c)
 
 	// Assert
 	assert.Equal(t, http.StatusMovedPermanently, w.Code)
@@ -269,7 +283,7 @@ func TestRedirectHandler_RedirectWithNamespace_Success(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestRedirectHandler_RedirectWithNamespace_NamespaceNotFound(t *testing.T) {
+func TestShortLinkRouter_Handle_NamespaceNotFound(t *testing.T) {
 	// Setup
 	db, mock, sqlDB := setupTestDB(t)
 	defer sqlDB.Close()
@@ -285,6 +299,11 @@ func TestRedirectHandler_RedirectWithNamespace_NamespaceNotFound(t *testing.T) {
 		WithArgs("example.com", "nonexistent-namespace").
 		WillReturnError(gorm.ErrRecordNotFound)
 
+	// Mock the fallback lookup of the first segment as a path-forwarding slug
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "nonexistent-namespace").
+		WillReturnError(gorm.ErrRecordNotFound)
+
 	// Setup Gin context
 	gin.SetMode(gin.TestMode)
 	w := httptest.NewRecorder()
@@ -294,14 +313,15 @@ func TestRedirectHandler_RedirectWithNamespace_NamespaceNotFound(t *testing.T) {
 	c.Request.URL.Path = "/nonexistent-namespace/abc123"
 
 	// Execute
-	handler.Redirect(c)
+	newTestRouterFor(handler).Handle(c)
 
-	// Assert
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	// Assert - without a not-found fallback, unknown links are served by the landing page
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "landing", w.Body.String())
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestRedirectHandler_RedirectWithNamespace_ShortURLNotFound(t *testing.T) {
+func TestShortLinkRouter_Handle_NamespaceShortURLNotFound(t *testing.T) {
 	// Setup
 	db, mock, sqlDB := setupTestDB(t)
 	defer sqlDB.Close()
@@ -327,6 +347,11 @@ func TestRedirectHandler_RedirectWithNamespace_ShortURLNotFound(t *testing.T) {
 		WithArgs("example.com", namespaceID, "nonexistent-slug").
 		WillReturnError(gorm.ErrRecordNotFound)
 
+	// Mock the fallback lookup of the first segment as a path-forwarding slug
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "my-namespace").
+		WillReturnError(gorm.ErrRecordNotFound)
+
 	// Setup Gin context
 	gin.SetMode(gin.TestMode)
 	w := httptest.NewRecorder()
@@ -336,9 +361,570 @@ func TestRedirectHandler_RedirectWithNamespace_ShortURLNotFound(t *testing.T) {
 	c.Request.URL.Path = "/my-namespace/nonexistent-slug"
 
 	// Execute
-	handler.Redirect(c)
+	newTestRouterFor(handler).Handle(c)
+
+	// Assert - without a not-found fallback, unknown links are served by the landing page
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "landing", w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortLinkRouter_Handle_RecordsClick(t *testing.T) {
+	// Setup
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	cfg := &config.Config{
+		AvailableShortDomains: []string{"example.com", "localhost:3000"},
+	}
+
+	handler := NewRedirectHandler(db, cfg)
+	recorder := services.NewClickRecorder(db, 10, 10, time.Hour)
+	handler.SetClickRecorder(recorder)
+
+	shortURLID := uuid.New().String()
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "namespace_id", "created_at", "updated_at"}).
+		AddRow(shortURLID, "example.com", "abc123", "https://example.com/target", "", nil, now, now)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "abc123").
+		WillReturnRows(rows)
+	expectNoDeviceRules(mock)
+
+	// The click is written when the recorder flushes
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "click_events"`).
+		WithArgs(sqlmock.AnyArg(), shortURLID, sqlmock.AnyArg(), "https://referrer.example/", "test-agent", "203.0.113.7", "other", "unknown", "other", "https://example.com/target", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "short_urls" SET "click_count"`).
+		WithArgs(1, shortURLID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Setup Gin context
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/abc123", nil)
+	c.Request.Host = "example.com"
+	c.Request.URL.Path = "/abc123"
+	c.Request.Header.Set("Referer", "https://referrer.example/")
+	c.Request.Header.Set("User-Agent", "test-agent")
+	c.Request.Header.Set("X-Forwarded-For", "203.0.113.7")
+
+	// Execute
+	newTestRouterFor(handler).Handle(c)
+	recorder.Close()
 
 	// Assert
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortLinkRouter_Handle_RedirectType(t *testing.T) {
+	tests := []struct {
+		name         string
+		redirectType int
+		defaultType  int
+		expected     int
+	}{
+		{"link type overrides default", http.StatusFound, http.StatusMovedPermanently, http.StatusFound},
+		{"temporary redirect", http.StatusTemporaryRedirect, 0, http.StatusTemporaryRedirect},
+		{"permanent redirect", http.StatusPermanentRedirect, 0, http.StatusPermanentRedirect},
+		{"unset uses config default", 0, http.StatusFound, http.StatusFound},
+		{"unset without config default", 0, 0, http.StatusMovedPermanently},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, sqlDB := setupTestDB(t)
+			defer sqlDB.Close()
+
+			cfg := &config.Config{
+				AvailableShortDomains: []string{"example.com"},
+				DefaultRedirectType:   tt.defaultType,
+			}
+
+			handler := NewRedirectHandler(db, cfg)
+
+			now := time.Now()
+			rows := sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "namespace_id", "redirect_type", "created_at", "updated_at"}).
+				AddRow(uuid.New().String(), "example.com", "abc123", "https://example.com/target", "", nil, tt.redirectType, now, now)
+
+			mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+				WithArgs("example.com", "abc123").
+				WillReturnRows(rows)
+			expectNoDeviceRules(mock)
+
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/abc123", nil)
+			c.Request.Host = "example.com"
+			c.Request.URL.Path = "/abc123"
+
+			newTestRouterFor(handler).Handle(c)
+
+			assert.Equal(t, tt.expected, w.Code)
+			assert.Equal(t, "https://example.com/target", w.Header().Get("Location"))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestShortLinkRouter_Handle_ExpiringLinkNotCached(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	cfg := &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	}
+
+	handler := NewRedirectHandler(db, cfg)
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "namespace_id", "redirect_type", "expires_at", "created_at", "updated_at"}).
+		AddRow(uuid.New().String(), "example.com", "abc123", "https://example.com/target", "", nil, http.StatusPermanentRedirect, now.Add(time.Hour), now, now)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "abc123").
+		WillReturnRows(rows)
+	expectNoDeviceRules(mock)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/abc123", nil)
+	c.Request.Host = "example.com"
+	c.Request.URL.Path = "/abc123"
+
+	newTestRouterFor(handler).Handle(c)
+
+	// A permanent type is downgraded, so browsers don't keep redirecting after expiry
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "https://example.com/target", w.Header().Get("Location"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortLinkRouter_Handle_Expired(t *testing.T) {
+	tests := []struct {
+		name             string
+		expiredLinkURL   string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{"returns 410 without fallback", "", http.StatusGone, ""},
+		{"redirects to fallback", "https://example.com/expired", http.StatusFound, "https://example.com/expired"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, sqlDB := setupTestDB(t)
+			defer sqlDB.Close()
+
+			cfg := &config.Config{
+				AvailableShortDomains: []string{"example.com"},
+				ExpiredLinkURL:        tt.expiredLinkURL,
+			}
+
+			handler := NewRedirectHandler(db, cfg)
+
+			now := time.Now()
+			expiresAt := now.Add(-time.Hour)
+			rows := sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "expires_at", "created_at", "updated_at"}).
+				AddRow(uuid.New().String(), "example.com", "abc123", "https://example.com/target", "", expiresAt, now, now)
+
+			mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+				WithArgs("example.com", "abc123").
+				WillReturnRows(rows)
+			expectNoDeviceRules(mock)
+
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/abc123", nil)
+			c.Request.Host = "example.com"
+			c.Request.URL.Path = "/abc123"
+
+			newTestRouterFor(handler).Handle(c)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.expectedLocation, w.Header().Get("Location"))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestShortLinkRouter_Handle_MaxClicks(t *testing.T) {
+	tests := []struct {
+		name           string
+		rowsAffected   int64
+		expectedStatus int
+	}{
+		{"click allowance remaining", 1, http.StatusFound},
+		{"last click taken concurrently", 0, http.StatusGone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, sqlDB := setupTestDB(t)
+			defer sqlDB.Close()
+
+			cfg := &config.Config{
+				AvailableShortDomains: []string{"example.com"},
+			}
+
+			handler := NewRedirectHandler(db, cfg)
+
+			shortURLID := uuid.New().String()
+			now := time.Now()
+			rows := sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "max_clicks", "click_count", "created_at", "updated_at"}).
+				AddRow(shortURLID, "example.com", "invite", "https://example.com/target", "", 1, 0, now, now)
+
+			mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+				WithArgs("example.com", "invite").
+				WillReturnRows(rows)
+			expectNoDeviceRules(mock)
+
+			// Conditional increment guards against overshooting the allowance
+			mock.ExpectBegin()
+			mock.ExpectExec(`UPDATE "short_urls" SET "click_count"=click_count \+ \$1 WHERE id = \$2 AND click_count < \$3`).
+				WithArgs(1, shortURLID, 1).
+				WillReturnResult(sqlmock.NewResult(0, tt.rowsAffected))
+			mock.ExpectCommit()
+
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/invite", nil)
+			c.Request.Host = "example.com"
+			c.Request.URL.Path = "/invite"
+
+			newTestRouterFor(handler).Handle(c)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusFound {
+				assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// fakeCountryLookup resolves every IP to a fixed country
+type fakeCountryLookup struct {
+	country string
+}
+
+func (f fakeCountryLookup) LookupCountry(ip string) string {
+	return f.country
+}
+
+func TestShortLinkRouter_Handle_GeoRule(t *testing.T) {
+	tests := []struct {
+		name             string
+		ruleURL          string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{
+			name:             "matching rule",
+			ruleURL:          "https://example.de/target",
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "https://example.de/target",
+		},
+		{
+			name:             "no matching rule",
+			expectedStatus:   http.StatusMovedPermanently,
+			expectedLocation: "https://example.com/target",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			db, mock, sqlDB := setupTestDB(t)
+			defer sqlDB.Close()
+
+			cfg := &config.Config{
+				AvailableShortDomains: []string{"example.com"},
+			}
+
+			handler := NewRedirectHandler(db, cfg)
+			handler.SetCountryLookup(fakeCountryLookup{country: "DE"})
+
+			id := uuid.New().String()
+			now := time.Now()
+			mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+				WithArgs("example.com", "abc123").
+				WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "namespace_id", "created_at", "updated_at"}).
+					AddRow(id, "example.com", "abc123", "https://example.com/target", "", nil, now, now))
+			expectNoDeviceRules(mock)
+
+			ruleRows := sqlmock.NewRows([]string{"id", "short_url_id", "country_code", "url", "created_at", "updated_at"})
+			if tt.ruleURL != "" {
+				ruleRows.AddRow(uuid.New().String(), id, "FR", "https://example.com/fr", now, now)
+				ruleRows.AddRow(uuid.New().String(), id, "DE", tt.ruleURL, now, now)
+			}
+			mock.ExpectQuery(`SELECT (.+) FROM "geo_rules" WHERE short_url_id = \$1`).
+				WithArgs(id).
+				WillReturnRows(ruleRows)
+
+			// Setup Gin context
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/abc123", nil)
+			c.Request.Host = "example.com"
+			c.Request.URL.Path = "/abc123"
+
+			// Execute
+			newTestRouterFor(handler).Handle(c)
+
+			// Assert
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.expectedLocation, w.Header().Get("Location"))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestShortLinkRouter_Handle_DeviceRule(t *testing.T) {
+	// Setup
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	cfg := &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	}
+
+	handler := NewRedirectHandler(db, cfg)
+
+	id := uuid.New().String()
+	now := time.Now()
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "abc123").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "namespace_id", "created_at", "updated_at"}).
+			AddRow(id, "example.com", "abc123", "https://example.com/target", "", nil, now, now))
+	mock.ExpectQuery(`SELECT (.+) FROM "device_rules" WHERE short_url_id = \$1`).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "short_url_id", "platform", "url", "created_at", "updated_at"}).
+			AddRow(uuid.New().String(), id, "android", "https://play.google.com/store/apps/details?id=com.example", now, now).
+			AddRow(uuid.New().String(), id, "ios", "https://apps.apple.com/app/id123", now, now))
+
+	// Setup Gin context
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/abc123", nil)
+	c.Request.Host = "example.com"
+	c.Request.URL.Path = "/abc123"
+	c.Request.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1")
+
+	// Execute
+	newTestRouterFor(handler).Handle(c)
+
+	// Assert
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "https://apps.apple.com/app/id123", w.Header().Get("Location"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortLinkRouter_Handle_SplitDestinations(t *testing.T) {
+	// Setup
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	cfg := &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	}
+
+	handler := NewRedirectHandler(db, cfg)
+
+	id := uuid.New().String()
+	now := time.Now()
+	destinations := `[{"url":"https://example.com/a","weight":70},{"url":"https://example.com/b","weight":30}]`
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "abc123").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "namespace_id", "destinations", "sticky_destinations", "created_at", "updated_at"}).
+			AddRow(id, "example.com", "abc123", "https://example.com/target", "", nil, destinations, true, now, now))
+	expectNoDeviceRules(mock)
+
+	// Setup Gin context
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/abc123", nil)
+	c.Request.Host = "example.com"
+	c.Request.URL.Path = "/abc123"
+
+	// Execute
+	newTestRouterFor(handler).Handle(c)
+
+	// Assert a variant was picked and remembered
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	location := w.Header().Get("Location")
+	assert.Contains(t, []string{"https://example.com/a", "https://example.com/b"}, location)
+	assert.Contains(t, w.Header().Get("Set-Cookie"), "osp_split_"+id+"=")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortLinkRouter_Handle_SplitDestinationsSticky(t *testing.T) {
+	// Setup
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	cfg := &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	}
+
+	handler := NewRedirectHandler(db, cfg)
+
+	id := uuid.New().String()
+	now := time.Now()
+	destinations := `[{"url":"https://example.com/a","weight":99},{"url":"https://example.com/b","weight":1}]`
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "abc123").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "namespace_id", "destinations", "sticky_destinations", "created_at", "updated_at"}).
+			AddRow(id, "example.com", "abc123", "https://example.com/target", "", nil, destinations, true, now, now))
+	expectNoDeviceRules(mock)
+
+	// Setup Gin context with a returning visitor's cookie
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/abc123", nil)
+	c.Request.Host = "example.com"
+	c.Request.URL.Path = "/abc123"
+	c.Request.AddCookie(&http.Cookie{Name: "osp_split_" + id, Value: "1"})
+
+	// Execute
+	newTestRouterFor(handler).Handle(c)
+
+	// Assert
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.com/b", w.Header().Get("Location"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAppendQueryParams(t *testing.T) {
+	tests := []struct {
+		name        string
+		requestURL  string
+		destination string
+		shortURL    models.ShortURL
+		namespace   *models.Namespace
+		expected    string
+	}{
+		{
+			name:        "query dropped without forwarding",
+			requestURL:  "/abc?ref=x",
+			destination: "https://example.com/target",
+			shortURL:    models.ShortURL{},
+			expected:    "https://example.com/target",
+		},
+		{
+			name:        "query forwarded",
+			requestURL:  "/abc?ref=x",
+			destination: "https://example.com/target?page=1",
+			shortURL:    models.ShortURL{ForwardQuery: true},
+			expected:    "https://example.com/target?page=1&ref=x",
+		},
+		{
+			name:        "incoming parameter replaces destination parameter",
+			requestURL:  "/abc?page=2",
+			destination: "https://example.com/target?page=1",
+			shortURL:    models.ShortURL{ForwardQuery: true},
+			expected:    "https://example.com/target?page=2",
+		},
+		{
+			name:        "utm defaults added when missing",
+			requestURL:  "/abc",
+			destination: "https://example.com/target?utm_source=twitter",
+			shortURL:    models.ShortURL{UTMDefaults: models.UTMDefaults{UTMSource: "newsletter", UTMMedium: "email"}},
+			expected:    "https://example.com/target?utm_medium=email&utm_source=twitter",
+		},
+		{
+			name:        "link utm defaults win over namespace",
+			requestURL:  "/ns/abc",
+			destination: "https://example.com/target",
+			shortURL:    models.ShortURL{UTMDefaults: models.UTMDefaults{UTMCampaign: "spring"}},
+			namespace:   &models.Namespace{UTMDefaults: models.UTMDefaults{UTMSource: "newsletter", UTMCampaign: "default"}},
+			expected:    "https://example.com/target?utm_campaign=spring&utm_source=newsletter",
+		},
+		{
+			name:        "forwarded utm parameter is kept",
+			requestURL:  "/abc?utm_source=partner",
+			destination: "https://example.com/target",
+			shortURL:    models.ShortURL{ForwardQuery: true, UTMDefaults: models.UTMDefaults{UTMSource: "newsletter"}},
+			expected:    "https://example.com/target?utm_source=partner",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, tt.requestURL, nil)
+
+			assert.Equal(t, tt.expected, appendQueryParams(c, tt.destination, &tt.shortURL, tt.namespace))
+		})
+	}
+}
+
+func TestShortLinkRouter_Handle_Cache(t *testing.T) {
+	// Setup
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	cfg := &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	}
+
+	handler := NewRedirectHandler(db, cfg)
+	cache := services.NewRedirectCache(10, time.Minute)
+	handler.SetRedirectCache(cache)
+
+	// Only the first lookup of each slug reaches the database
+	now := time.Now()
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "abc123").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "namespace_id", "created_at", "updated_at"}).
+			AddRow(uuid.New().String(), "example.com", "abc123", "https://example.com/target", "", nil, now, now))
+	expectNoDeviceRules(mock)
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "missing").
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range []struct {
+		slug     string
+		expected int
+	}{
+		{"abc123", http.StatusMovedPermanently},
+		{"abc123", http.StatusMovedPermanently},
+		{"missing", http.StatusOK},
+		{"missing", http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/"+tt.slug, nil)
+		c.Request.Host = "example.com"
+		c.Request.URL.Path = "/" + tt.slug
+
+		newTestRouterFor(handler).Handle(c)
+
+		assert.Equal(t, tt.expected, w.Code)
+	}
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(2), stats.Hits)
+	assert.Equal(t, uint64(2), stats.Misses)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
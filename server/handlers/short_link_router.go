@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"openshortpath/server/services"
+)
+
+// RouteKind is the outcome of resolving a path that no registered route matched
+type RouteKind int
+
+const (
+	// RouteLanding serves the landing page (reserved paths, other domains and paths that can't be short links)
+	RouteLanding RouteKind = iota
+	// RouteShortLink redirects through a short link
+	RouteShortLink
+	// RouteLinkNotFound is a path on a short domain with no matching link
+	RouteLinkNotFound
+	// RouteNotFound is an unknown API or dashboard path
+	RouteNotFound
+	// RouteQRCode renders a short link's QR code (/slug.qr or /namespace/slug.qr)
+	RouteQRCode
+)
+
+// Route is the result of ShortLinkRouter.Resolve
+type Route struct {
+	Kind RouteKind
+	// Namespace and Slug are parsed from the path for RouteShortLink, RouteLinkNotFound and RouteQRCode
+	Namespace string
+	Slug      string
+	// Entry holds the short URL for RouteShortLink and RouteQRCode
+	// For RouteLinkNotFound, Entry.Namespace is set if the namespace exists but the slug doesn't
+	Entry services.RedirectCacheEntry
+	// ForwardedPath is the escaped rest of the path for links that forward paths, without a leading slash
+	ForwardedPath string
+}
+
+// ShortLinkRouter resolves every path without a registered route to a short link,
+// the landing page or a 404, and serves it
+type ShortLinkRouter struct {
+	redirectHandler *RedirectHandler
+	landing         gin.HandlerFunc
+	reservedPaths   []string
+}
+
+// NewShortLinkRouter creates a router that serves short links through redirectHandler
+// and everything else through landing; reservedPaths always go to the landing page
+func NewShortLinkRouter(redirectHandler *RedirectHandler, landing gin.HandlerFunc, reservedPaths []string) *ShortLinkRouter {
+	return &ShortLinkRouter{
+		redirectHandler: redirectHandler,
+		landing:         landing,
+		reservedPaths:   reservedPaths,
+	}
+}
+
+// isReservedPath checks if the path is one of the reserved landing page paths or below one
+func (r *ShortLinkRouter) isReservedPath(path string) bool {
+	for _, reserved := range r.reservedPaths {
+		if path == reserved || strings.HasPrefix(path, reserved+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve decides how to serve a request for host and path
+// Only paths on a short domain reach the database
+func (r *ShortLinkRouter) Resolve(host, path string) (Route, error) {
+	// API and dashboard routes are registered explicitly, so anything left under them is unknown
+	if strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/dashboard/") {
+		return Route{Kind: RouteNotFound}, nil
+	}
+
+	if r.isReservedPath(path) {
+		return Route{Kind: RouteLanding}, nil
+	}
+
+	segments, ok := splitShortLinkPath(path)
+	if !ok {
+		return Route{Kind: RouteLanding}, nil
+	}
+
+	// Reserved namespace names (e.g. /docs/...) belong to the landing page
+	if IsReservedNamespaceName(segments[0]) {
+		return Route{Kind: RouteLanding}, nil
+	}
+
+	if !isValidDomain(host, r.redirectHandler.cfg.AvailableShortDomains) {
+		return Route{Kind: RouteLanding}, nil
+	}
+
+	// /slug.qr and /namespace/slug.qr are QR codes of exact links; otherwise .qr is part of the slug
+	if last := segments[len(segments)-1]; len(segments) <= 2 && strings.HasSuffix(last, qrCodeSuffix) && last != qrCodeSuffix {
+		linkSegments := append(segments[:len(segments)-1:len(segments)-1], strings.TrimSuffix(last, qrCodeSuffix))
+		route, err := r.redirectHandler.resolveShortLinkPath(host, linkSegments, false)
+		if err != nil {
+			return Route{}, err
+		}
+		if route.Kind == RouteShortLink && route.ForwardedPath == "" {
+			route.Kind = RouteQRCode
+			return route, nil
+		}
+	}
+
+	return r.redirectHandler.resolveShortLinkPath(host, segments, strings.HasSuffix(path, "/"))
+}
+
+// Handle serves a request that no registered route matched
+func (r *ShortLinkRouter) Handle(c *gin.Context) {
+	route, err := r.Resolve(c.Request.Host, c.Request.URL.Path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	switch route.Kind {
+	case RouteShortLink:
+		r.redirectHandler.redirectTo(c, route.Entry, route.ForwardedPath)
+	case RouteQRCode:
+		c.Header("Cache-Control", "public, max-age=3600")
+		renderQRCode(c, shortLinkURL(c.Request.Host, route.Namespace, route.Slug))
+	case RouteNotFound:
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Route not found",
+		})
+	case RouteLinkNotFound:
+		// Unknown short links use the namespace's or domain's fallback, otherwise the landing page's own 404
+		r.redirectHandler.respondNotFound(c, c.Request.Host, route, r.landing)
+	default:
+		r.landing(c)
+	}
+}
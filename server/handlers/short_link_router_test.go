@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"openshortpath/server/config"
+)
+
+func newTestShortLinkRouter(db *gorm.DB) *ShortLinkRouter {
+	cfg := &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	}
+	return newTestRouterFor(NewRedirectHandler(db, cfg))
+}
+
+// newTestRouterFor serves short links through handler; every other path gets a "landing" page
+func newTestRouterFor(handler *RedirectHandler) *ShortLinkRouter {
+	landing := func(c *gin.Context) {
+		c.String(http.StatusOK, "landing")
+	}
+	return NewShortLinkRouter(handler, landing, config.DefaultLandingReservedPaths)
+}
+
+func TestShortLinkRouter_Resolve_WithoutDatabase(t *testing.T) {
+	tests := []struct {
+		name     string
+		host     string
+		path     string
+		expected RouteKind
+	}{
+		{"unknown api route", "example.com", "/api/v1/unknown", RouteNotFound},
+		{"unknown dashboard route", "example.com", "/dashboard/unknown", RouteNotFound},
+		{"reserved path", "example.com", "/_next/static/app.js", RouteLanding},
+		{"reserved file", "example.com", "/favicon.ico", RouteLanding},
+		{"reserved namespace name", "example.com", "/docs/getting-started", RouteLanding},
+		{"empty segment", "example.com", "/a//b", RouteLanding},
+		{"domain not a short domain", "landing.example.org", "/abc123", RouteLanding},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, sqlDB := setupTestDB(t)
+			defer sqlDB.Close()
+
+			router := newTestShortLinkRouter(db)
+
+			route, err := router.Resolve(tt.host, tt.path)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, route.Kind)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestShortLinkRouter_Resolve_ShortLink(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	router := newTestShortLinkRouter(db)
+
+	id := uuid.New().String()
+	now := time.Now()
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "abc123").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "namespace_id", "created_at", "updated_at"}).
+			AddRow(id, "example.com", "abc123", "https://example.com/target", "", nil, now, now))
+	expectNoDeviceRules(mock)
+
+	route, err := router.Resolve("example.com", "/abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, RouteShortLink, route.Kind)
+	assert.Equal(t, "abc123", route.Slug)
+	assert.Equal(t, id, route.Entry.ShortURL.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortLinkRouter_Resolve_NamespaceWithoutLink(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	router := newTestShortLinkRouter(db)
+
+	namespaceID := uuid.New().String()
+	now := time.Now()
+	mock.ExpectQuery(`SELECT (.+) FROM "namespaces"`).
+		WithArgs("example.com", "marketing").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "domain", "user_id", "created_at", "updated_at"}).
+			AddRow(namespaceID, "marketing", "example.com", "user123", now, now))
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", namespaceID, "missing").
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "marketing").
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	route, err := router.Resolve("example.com", "/marketing/missing")
+	assert.NoError(t, err)
+	assert.Equal(t, RouteLinkNotFound, route.Kind)
+	assert.Equal(t, namespaceID, route.Entry.Namespace.ID)
+	assert.Nil(t, route.Entry.ShortURL)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortLinkRouter_Handle(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	router := newTestShortLinkRouter(db)
+
+	now := time.Now()
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "abc123").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "namespace_id", "redirect_type", "created_at", "updated_at"}).
+			AddRow(uuid.New().String(), "example.com", "abc123", "https://example.com/target", "", nil, 302, now, now))
+	expectNoDeviceRules(mock)
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "missing").
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		path             string
+		expectedCode     int
+		expectedLocation string
+		expectedBody     string
+	}{
+		{"/abc123", http.StatusFound, "https://example.com/target", ""},
+		{"/missing", http.StatusOK, "", "landing"},
+		{"/robots.txt", http.StatusOK, "", "landing"},
+		{"/api/v1/unknown", http.StatusNotFound, "", `{"error":"Route not found"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, tt.path, nil)
+			c.Request.Host = "example.com"
+
+			router.Handle(c)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Equal(t, tt.expectedLocation, w.Header().Get("Location"))
+			if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"openshortpath/server/constants"
+	"openshortpath/server/models"
+	"openshortpath/server/services"
+)
+
+// exportBatchSize is how many short URLs are loaded and written at a time while exporting
+const exportBatchSize = 500
+
+// exportContentTypes maps export formats to their response content type
+var exportContentTypes = map[string]string{
+	services.LinkFormatCSV:    "text/csv; charset=utf-8",
+	services.LinkFormatNDJSON: "application/x-ndjson",
+}
+
+// Export handles GET /api/v1/short-urls/export
+// Streams all of the user's short URLs as CSV (default) or NDJSON, optionally only those
+// in one namespace (?namespace_id=) or on one domain (?domain=)
+func (h *ShortURLsHandler) Export(c *gin.Context) {
+	// Get user ID from context (set by RequireAuth middleware)
+	userIDValue, exists := c.Get(constants.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User ID not found in context",
+		})
+		return
+	}
+
+	userID, ok := userIDValue.(string)
+	if !ok || userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid user ID in context",
+		})
+		return
+	}
+
+	format := c.DefaultQuery("format", services.LinkFormatCSV)
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid format parameter (must be %s or %s)", services.LinkFormatCSV, services.LinkFormatNDJSON),
+		})
+		return
+	}
+
+	query := h.db.Where("user_id = ?", userID)
+	if namespaceID := c.Query("namespace_id"); namespaceID != "" {
+		query = query.Where("namespace_id = ?", namespaceID)
+	}
+	if domain := c.Query("domain"); domain != "" {
+		query = query.Where("domain = ?", domain)
+	}
+
+	writer, err := services.NewLinkExportWriter(format, c.Writer)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to start export",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="short-urls.%s"`, format))
+
+	// Links are written one batch at a time, so memory use doesn't grow with the number of links
+	var batch []models.ShortURL
+	result := query.FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			if err := writer.Write(&batch[i]); err != nil {
+				return err
+			}
+		}
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if result.Error != nil {
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Type")
+			c.Writer.Header().Del("Content-Disposition")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Database error",
+				"details": result.Error.Error(),
+			})
+			return
+		}
+		// The response has started, so the truncated export can only be logged
+		log.Printf("Export for user %s failed: %v", userID, result.Error)
+		return
+	}
+
+	if err := writer.Flush(); err != nil {
+		log.Printf("Export for user %s failed: %v", userID, err)
+	}
+}
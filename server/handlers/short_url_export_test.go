@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"openshortpath/server/config"
+	"openshortpath/server/constants"
+	"openshortpath/server/models"
+)
+
+func TestShortURLsHandler_Export_CSV(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewShortURLsHandler(db, &config.Config{})
+
+	userID := "user123"
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	mock.ExpectQuery(`SELECT \* FROM "short_urls" WHERE user_id = \$1 AND domain = \$2 ORDER BY "short_urls"."id" LIMIT 500`).
+		WithArgs(userID, "example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "password_hash", "created_at", "updated_at"}).
+			AddRow("url-1", "example.com", "abc", "https://example.com/a", userID, nil, now, now).
+			AddRow("url-2", "example.com", "def", "https://example.com/b", userID, "$argon2id$hash", now, now))
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, userID)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/short-urls/export?domain=example.com", nil)
+
+	handler.Export(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "short-urls.csv")
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "id,domain,slug,url,"))
+	assert.True(t, strings.HasPrefix(lines[1], "url-1,example.com,abc,https://example.com/a,"))
+	// Password hashes are never exported, only whether there is one
+	assert.NotContains(t, w.Body.String(), "argon2id")
+	assert.Contains(t, lines[2], ",true,")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortURLsHandler_Export_NDJSON(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewShortURLsHandler(db, &config.Config{})
+
+	userID := "user123"
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM "short_urls" WHERE user_id = \$1 AND namespace_id = \$2 ORDER BY "short_urls"."id" LIMIT 500`).
+		WithArgs(userID, "ns-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "namespace_id", "created_at", "updated_at"}).
+			AddRow("url-1", "example.com", "abc", "https://example.com/a", userID, "ns-1", now, now))
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, userID)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/short-urls/export?format=ndjson&namespace_id=ns-1", nil)
+
+	handler.Export(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	var exported models.ShortURL
+	assert.NoError(t, json.Unmarshal([]byte(strings.TrimSpace(w.Body.String())), &exported))
+	assert.Equal(t, "abc", exported.Slug)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortURLsHandler_Export_Errors(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewShortURLsHandler(db, &config.Config{})
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, "user123")
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/short-urls/export?format=xml", nil)
+	handler.Export(c)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// A failure before anything was written is still reported as JSON
+	mock.ExpectQuery(`SELECT \* FROM "short_urls"`).
+		WillReturnError(fmt.Errorf("connection lost"))
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, "user123")
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/short-urls/export", nil)
+	handler.Export(c)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,752 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"openshortpath/server/config"
+	"openshortpath/server/constants"
+	"openshortpath/server/models"
+	"openshortpath/server/services"
+	"openshortpath/server/utils"
+)
+
+// What an import does when a record's slug is already taken on its domain
+const (
+	ImportConflictSkip      = "skip"      // Keep the existing link and leave the record out
+	ImportConflictOverwrite = "overwrite" // Replace the existing link's settings, if it is the user's own
+	ImportConflictRename    = "rename"    // Import the record under a newly generated slug
+)
+
+const (
+	// maxImportFileSize is the largest import file accepted
+	maxImportFileSize = 64 << 20
+	// importSyncMaxSize is the largest import file imported before responding; larger files become background jobs
+	importSyncMaxSize = 1 << 20
+	// maxImportResults is how many record outcomes an import report keeps
+	maxImportResults = 1000
+	// importProgressInterval is how many records a background job imports between progress updates
+	importProgressInterval = 100
+	// maxImportedSlugLength is the longest slug the short_urls table stores
+	maxImportedSlugLength = 255
+)
+
+// errImportJobInterrupted is the error of a job stopped by a server shutdown
+var errImportJobInterrupted = errors.New("import was interrupted by a server shutdown")
+
+type ImportHandler struct {
+	db            *gorm.DB
+	cfg           *config.Config
+	shortener     *ShortenHandler // Validates and creates links the same way POST /api/v1/shorten does
+	redirectCache *services.RedirectCache
+	// ctx is cancelled by Close to stop background jobs, which jobs waits for
+	ctx    context.Context
+	cancel context.CancelFunc
+	jobs   sync.WaitGroup
+}
+
+func NewImportHandler(db *gorm.DB, cfg *config.Config) *ImportHandler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ImportHandler{
+		db:        db,
+		cfg:       cfg,
+		shortener: NewShortenHandler(db, cfg),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Close stops background import jobs and waits for them to record that they were interrupted
+func (h *ImportHandler) Close() {
+	h.cancel()
+	h.jobs.Wait()
+}
+
+// FailInterruptedImportJobs marks jobs left pending or running by a previous run of the server as failed
+// Their files were temporary, so they can't be resumed. Returns the number of jobs marked
+func FailInterruptedImportJobs(db *gorm.DB) (int64, error) {
+	result := db.Model(&models.ImportJob{}).
+		Where("status IN ?", []string{models.ImportJobPending, models.ImportJobRunning}).
+		Updates(map[string]interface{}{
+			"status":      models.ImportJobFailed,
+			"error":       errImportJobInterrupted.Error(),
+			"finished_at": time.Now().UTC(),
+		})
+	return result.RowsAffected, result.Error
+}
+
+// SetRedirectCache sets the redirect cache to invalidate when imported links are created or overwritten
+func (h *ImportHandler) SetRedirectCache(cache *services.RedirectCache) {
+	h.redirectCache = cache
+	h.shortener.SetRedirectCache(cache)
+}
+
+// SetURLPolicy sets the policy imported destination URLs must pass
+func (h *ImportHandler) SetURLPolicy(policy *services.URLPolicy) {
+	h.shortener.SetURLPolicy(policy)
+}
+
+// importOptions are the settings of one import
+type importOptions struct {
+	userID    string
+	format    string
+	conflict  string
+	dryRun    bool
+	domain    string            // Replaces the domain of every record if set
+	namespace *models.Namespace // Replaces the namespace of every record if set
+}
+
+// importState is what an import remembers between records
+type importState struct {
+	opts          importOptions
+	report        models.ImportReport
+	namespaces    map[string]*models.Namespace
+	seen          map[string]bool // Slugs taken by earlier records, which a dry run doesn't store
+	identifier    string
+	limitType     string
+	limitPerMonth int
+	limitExceeded *apiFailure // Set once the monthly link limit runs out
+}
+
+// Import handles POST /api/v1/short-urls/import
+// The file is sent as the request body or as the "file" field of a multipart form. Small files are
+// imported before responding with the completed job; larger ones are imported in the background
+// and the pending job is returned with 202 so its progress can be polled
+// Besides our own CSV and NDJSON exports, ?format= accepts YOURLS, Bitly and Kutt exports,
+// which keep their slugs and creation times and are imported onto the ?domain= given
+func (h *ImportHandler) Import(c *gin.Context) {
+	// Get user ID from context (set by RequireAuth middleware)
+	userIDValue, exists := c.Get(constants.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User ID not found in context",
+		})
+		return
+	}
+
+	userID, ok := userIDValue.(string)
+	if !ok || userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid user ID in context",
+		})
+		return
+	}
+
+	opts := importOptions{
+		userID:   userID,
+		format:   c.DefaultQuery("format", services.LinkFormatCSV),
+		conflict: c.DefaultQuery("conflict", ImportConflictSkip),
+		domain:   c.Query("domain"),
+	}
+	if opts.conflict != ImportConflictSkip && opts.conflict != ImportConflictOverwrite && opts.conflict != ImportConflictRename {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid conflict parameter (must be %s, %s or %s)", ImportConflictSkip, ImportConflictOverwrite, ImportConflictRename),
+		})
+		return
+	}
+	if dryRunStr := c.Query("dry_run"); dryRunStr != "" {
+		dryRun, err := strconv.ParseBool(dryRunStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid dry_run parameter (must be true or false)",
+			})
+			return
+		}
+		opts.dryRun = dryRun
+	}
+	if opts.domain != "" && !isValidDomain(opts.domain, h.cfg.AvailableShortDomains) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Domain '%s' is not in the list of available short domains", opts.domain),
+		})
+		return
+	}
+	// Links from other shorteners are on their domains, so they need one of ours
+	if opts.domain == "" && services.IsForeignLinkFormat(opts.format) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("The domain parameter is required for %s imports", opts.format),
+		})
+		return
+	}
+	if namespaceID := c.Query("namespace_id"); namespaceID != "" {
+		namespace, failure := h.shortener.userNamespace(userID, &namespaceID)
+		if failure != nil {
+			c.JSON(failure.status, failure.body)
+			return
+		}
+		opts.namespace = namespace
+	}
+
+	path, size, err := spoolImportFile(c)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": fmt.Sprintf("Import files must not be larger than %d MB", maxImportFileSize>>20),
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to read import file",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// Check the format and header before accepting the file
+	file, err := os.Open(path)
+	if err != nil {
+		os.Remove(path)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to read import file",
+			"details": err.Error(),
+		})
+		return
+	}
+	_, err = services.NewLinkImportReader(opts.format, file)
+	file.Close()
+	if err != nil {
+		os.Remove(path)
+		if errors.Is(err, services.ErrUnknownLinkFormat) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid format parameter (must be one of %s)", strings.Join(services.LinkImportFormats, ", ")),
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid import file",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	job := &models.ImportJob{
+		UserID:   userID,
+		Format:   opts.format,
+		Conflict: opts.conflict,
+		DryRun:   opts.dryRun,
+		Status:   models.ImportJobPending,
+	}
+	if err := h.db.Create(job).Error; err != nil {
+		os.Remove(path)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to create import job",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if size > importSyncMaxSize {
+		// The job is updated while it runs, so respond with a copy
+		pending := *job
+		h.jobs.Add(1)
+		go func() {
+			defer h.jobs.Done()
+			h.runImportJob(job, opts, path)
+		}()
+		c.Header("Location", "/api/v1/import-jobs/"+pending.ID)
+		c.JSON(http.StatusAccepted, pending)
+		return
+	}
+
+	h.runImportJob(job, opts, path)
+	c.JSON(http.StatusOK, job)
+}
+
+// GetImportJob handles GET /api/v1/import-jobs/:id
+// Returns the progress of an import, or its report once it has finished
+func (h *ImportHandler) GetImportJob(c *gin.Context) {
+	// Get user ID from context (set by RequireAuth middleware)
+	userIDValue, exists := c.Get(constants.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User ID not found in context",
+		})
+		return
+	}
+
+	userID, ok := userIDValue.(string)
+	if !ok || userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid user ID in context",
+		})
+		return
+	}
+
+	var job models.ImportJob
+	if err := h.db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Import job not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// spoolImportFile copies the uploaded file to a temporary file and returns its path and size
+func spoolImportFile(c *gin.Context) (string, int64, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportFileSize)
+
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return "", 0, err
+		}
+		upload, err := header.Open()
+		if err != nil {
+			return "", 0, err
+		}
+		defer upload.Close()
+		body = upload
+	}
+
+	file, err := os.CreateTemp("", "openshortpath-import-*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	size, err := io.Copy(file, body)
+	if err != nil {
+		os.Remove(file.Name())
+		return "", 0, err
+	}
+	return file.Name(), size, nil
+}
+
+// runImportJob imports the file at path for job, records the outcome and removes the file
+// The job's counts are saved as it goes, so its progress can be polled. Close stops it between records
+func (h *ImportHandler) runImportJob(job *models.ImportJob, opts importOptions, path string) {
+	defer os.Remove(path)
+
+	fail := func(err error) {
+		job.Status = models.ImportJobFailed
+		job.Error = err.Error()
+		if len(job.Error) > 512 {
+			job.Error = job.Error[:512]
+		}
+		h.finishImportJob(job)
+	}
+
+	job.Status = models.ImportJobRunning
+	if err := h.db.Model(job).UpdateColumn("status", job.Status).Error; err != nil {
+		log.Printf("Failed to start import job %s: %v", job.ID, err)
+	}
+
+	// Count the records first so progress can be shown as a fraction
+	total, err := countImportFile(opts.format, path)
+	if err != nil {
+		fail(err)
+		return
+	}
+	job.Report.Total = total
+
+	file, err := os.Open(path)
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer file.Close()
+	reader, err := services.NewLinkImportReader(opts.format, file)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	report, err := h.importLinks(h.ctx, opts, reader, total, func(progress models.ImportReport) {
+		if err := h.db.Model(job).UpdateColumns(map[string]interface{}{
+			"total":       progress.Total,
+			"processed":   progress.Processed,
+			"created":     progress.Created,
+			"overwritten": progress.Overwritten,
+			"skipped":     progress.Skipped,
+			"renamed":     progress.Renamed,
+			"failed":      progress.Failed,
+		}).Error; err != nil {
+			log.Printf("Failed to save progress of import job %s: %v", job.ID, err)
+		}
+	})
+	job.Report = report
+	if errors.Is(err, context.Canceled) {
+		fail(errImportJobInterrupted)
+		return
+	}
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	job.Status = models.ImportJobCompleted
+	h.finishImportJob(job)
+}
+
+// finishImportJob saves a job's final status and report
+func (h *ImportHandler) finishImportJob(job *models.ImportJob) {
+	finishedAt := time.Now().UTC()
+	job.FinishedAt = &finishedAt
+	if err := h.db.Save(job).Error; err != nil {
+		log.Printf("Failed to save import job %s: %v", job.ID, err)
+	}
+}
+
+// countImportFile returns the number of records in the import file at path
+func countImportFile(format, path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader, err := services.NewLinkImportReader(format, file)
+	if err != nil {
+		return 0, err
+	}
+	return services.CountImportRecords(reader)
+}
+
+// importLinks imports every record of reader and calls progress every importProgressInterval records
+// An error means the file couldn't be read to the end or ctx was cancelled; the report covers the records before it
+func (h *ImportHandler) importLinks(ctx context.Context, opts importOptions, reader services.LinkImportReader, total int, progress func(models.ImportReport)) (models.ImportReport, error) {
+	state := &importState{
+		opts:       opts,
+		report:     models.ImportReport{Total: total},
+		namespaces: make(map[string]*models.Namespace),
+		seen:       make(map[string]bool),
+	}
+	if !opts.dryRun {
+		state.identifier, state.limitType, state.limitPerMonth = h.shortener.monthlyLinkLimit(opts.userID, "")
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return state.report, err
+		}
+
+		record, err := reader.Next()
+		if err == io.EOF {
+			return state.report, nil
+		}
+		if err != nil {
+			return state.report, fmt.Errorf("failed to read record %d: %w", state.report.Processed+1, err)
+		}
+
+		result := h.importRecord(ctx, state, record)
+		report := &state.report
+		report.Processed++
+		switch result.Action {
+		case models.ImportActionCreate:
+			report.Created++
+		case models.ImportActionOverwrite:
+			report.Overwritten++
+		case models.ImportActionSkip:
+			report.Skipped++
+		case models.ImportActionRename:
+			report.Renamed++
+		case models.ImportActionFail:
+			report.Failed++
+		}
+		if len(report.Results) < maxImportResults {
+			report.Results = append(report.Results, result)
+		}
+		if progress != nil && report.Processed%importProgressInterval == 0 {
+			progress(*report)
+		}
+	}
+}
+
+// importRecord validates one record like POST /api/v1/shorten, resolves a slug conflict and stores it
+func (h *ImportHandler) importRecord(ctx context.Context, state *importState, record *services.ImportRecord) models.ImportResult {
+	opts := state.opts
+	link := record.ShortURL
+	if opts.domain != "" {
+		link.Domain = opts.domain
+	}
+	if opts.namespace != nil {
+		link.NamespaceID = &opts.namespace.ID
+	}
+
+	result := models.ImportResult{
+		Record: record.Number,
+		Domain: link.Domain,
+		Slug:   link.Slug,
+	}
+	fail := func(message string) models.ImportResult {
+		result.Action = models.ImportActionFail
+		result.Error = message
+		return result
+	}
+
+	if record.Err != nil {
+		return fail(record.Err.Error())
+	}
+	if link.Domain == "" {
+		return fail("domain is required")
+	}
+	if link.URL == "" {
+		return fail("url is required")
+	}
+	if link.HasPassword && record.Password == "" {
+		return fail("The link is password protected, but exports don't contain passwords; add a password to import it")
+	}
+	if link.ClickCount < 0 {
+		return fail("click_count must not be negative")
+	}
+	if link.Slug != "" {
+		if reason := h.unsupportedSlugReason(link.Slug); reason != "" {
+			return fail(fmt.Sprintf("Unsupported slug '%s': %s", link.Slug, reason))
+		}
+	}
+
+	req := ShortenRequest{
+		Domain:             link.Domain,
+		URL:                link.URL,
+		Slug:               link.Slug,
+		NamespaceID:        link.NamespaceID,
+		RedirectType:       link.RedirectType,
+		MaxClicks:          link.MaxClicks,
+		Destinations:       link.Destinations,
+		StickyDestinations: link.StickyDestinations,
+		ForwardQuery:       link.ForwardQuery,
+		ForwardPath:        link.ForwardPath,
+		DeepLink:           link.DeepLink,
+		OpenGraph:          link.OpenGraph,
+		HealthFallbackURL:  link.HealthFallbackURL,
+		UTMDefaults:        link.UTMDefaults,
+	}
+	// Links that already expired are imported as they are
+	if failure := h.shortener.validateShortenRequest(ctx, &req); failure != nil {
+		return fail(failureMessage(failure))
+	}
+	link.URL = req.URL
+	link.DeepLink.FallbackURL = req.DeepLink.FallbackURL
+	link.HealthFallbackURL = req.HealthFallbackURL
+
+	namespace, failure := h.importNamespace(state, link.NamespaceID)
+	if failure != nil {
+		return fail(failureMessage(failure))
+	}
+
+	action := models.ImportActionCreate
+	var existing models.ShortURL
+	if link.Slug != "" {
+		key := link.Domain + "/" + link.Slug
+		taken := opts.dryRun && state.seen[key]
+		if taken {
+			// Taken by an earlier record of this dry run, which would belong to the user
+			existing.UserID = opts.userID
+		} else {
+			err := h.db.Where("domain = ? AND slug = ?", link.Domain, link.Slug).First(&existing).Error
+			if err != nil && err != gorm.ErrRecordNotFound {
+				return fail(fmt.Sprintf("Database error: %v", err))
+			}
+			taken = err == nil
+		}
+
+		if taken {
+			switch opts.conflict {
+			case ImportConflictSkip:
+				result.Action = models.ImportActionSkip
+				return result
+			case ImportConflictOverwrite:
+				if existing.UserID != opts.userID {
+					return fail(fmt.Sprintf("Slug '%s' on domain '%s' belongs to another user's link", link.Slug, link.Domain))
+				}
+				action = models.ImportActionOverwrite
+			case ImportConflictRename:
+				action = models.ImportActionRename
+				result.OldSlug = link.Slug
+				result.Slug = ""
+				link.Slug = ""
+			}
+		}
+		if opts.dryRun && link.Slug != "" {
+			state.seen[key] = true
+		}
+	}
+
+	if opts.dryRun {
+		result.Action = action
+		return result
+	}
+
+	var passwordHash *string
+	if record.Password != "" {
+		hashed, err := utils.HashPassword(record.Password)
+		if err != nil {
+			return fail(fmt.Sprintf("Failed to hash password: %v", err))
+		}
+		passwordHash = &hashed
+	}
+
+	if action == models.ImportActionOverwrite {
+		updateFields := map[string]interface{}{
+			"url":                    link.URL,
+			"url_hash":               utils.URLHash(link.URL),
+			"namespace_id":           link.NamespaceID,
+			"redirect_type":          link.RedirectType,
+			"expires_at":             link.ExpiresAt,
+			"max_clicks":             link.MaxClicks,
+			"password_hash":          passwordHash,
+			"destinations":           link.Destinations,
+			"sticky_destinations":    link.StickyDestinations,
+			"forward_query":          link.ForwardQuery,
+			"forward_path":           link.ForwardPath,
+			"utm_source":             link.UTMSource,
+			"utm_medium":             link.UTMMedium,
+			"utm_campaign":           link.UTMCampaign,
+			"deep_link_ios_url":      link.DeepLink.IOSURL,
+			"deep_link_android_url":  link.DeepLink.AndroidURL,
+			"deep_link_fallback_url": link.DeepLink.FallbackURL,
+			"og_title":               link.OpenGraph.Title,
+			"og_description":         link.OpenGraph.Description,
+			"og_image_url":           link.OpenGraph.ImageURL,
+			"health_fallback_url":    link.HealthFallbackURL,
+		}
+		// The destination may have changed, so its health is checked again
+		resetLinkHealth(updateFields)
+		if err := h.db.Model(&existing).Updates(updateFields).Error; err != nil {
+			return fail(fmt.Sprintf("Failed to overwrite short URL: %v", err))
+		}
+		h.redirectCache.InvalidateShortURL(&existing)
+		result.Action = action
+		return result
+	}
+
+	// New links count against the monthly link limit
+	if state.limitExceeded != nil {
+		return fail(failureMessage(state.limitExceeded))
+	}
+	granted, monthlyLimitInfo, err := services.ReserveMonthlyLinks(h.db, state.identifier, state.limitType, state.limitPerMonth, 1, false)
+	if err != nil {
+		return fail(fmt.Sprintf("Monthly link limit check failed: %v", err))
+	}
+	if granted == 0 {
+		state.limitExceeded = monthlyLinkLimitExceeded(monthlyLimitInfo)
+		return fail(failureMessage(state.limitExceeded))
+	}
+
+	if link.Slug == "" {
+		slug, err := h.shortener.generateSlug(h.db, link.Domain, namespace)
+		if err != nil {
+			h.shortener.releaseMonthlyLinks(state.identifier, state.limitType, state.limitPerMonth, 1)
+			return fail(fmt.Sprintf("Failed to generate slug: %v", err))
+		}
+		link.Slug = slug
+	}
+
+	shortURL := models.ShortURL{
+		ID:                 uuid.New().String(),
+		Domain:             link.Domain,
+		Slug:               link.Slug,
+		URL:                link.URL,
+		UserID:             opts.userID,
+		NamespaceID:        link.NamespaceID,
+		RedirectType:       link.RedirectType,
+		ExpiresAt:          link.ExpiresAt,
+		MaxClicks:          link.MaxClicks,
+		ClickCount:         link.ClickCount,
+		PasswordHash:       passwordHash,
+		Destinations:       link.Destinations,
+		StickyDestinations: link.StickyDestinations,
+		ForwardQuery:       link.ForwardQuery,
+		ForwardPath:        link.ForwardPath,
+		UTMDefaults:        link.UTMDefaults,
+		DeepLink:           link.DeepLink,
+		OpenGraph:          link.OpenGraph,
+		HealthFallbackURL:  link.HealthFallbackURL,
+		CreatedAt:          link.CreatedAt, // Kept from the file; left zero it becomes the current time
+	}
+	if err := h.db.Create(&shortURL).Error; err != nil {
+		h.shortener.releaseMonthlyLinks(state.identifier, state.limitType, state.limitPerMonth, 1)
+		return fail(fmt.Sprintf("Failed to create short URL: %v", err))
+	}
+
+	// Drop any cached 404 for the new link
+	h.redirectCache.InvalidateShortURL(&shortURL)
+	result.Action = action
+	result.Slug = shortURL.Slug
+	return result
+}
+
+// importNamespace returns the namespace a record is imported into, checking the user owns it once per namespace
+func (h *ImportHandler) importNamespace(state *importState, namespaceID *string) (*models.Namespace, *apiFailure) {
+	if namespaceID == nil || *namespaceID == "" {
+		return nil, nil
+	}
+	if state.opts.namespace != nil && *namespaceID == state.opts.namespace.ID {
+		return state.opts.namespace, nil
+	}
+	if namespace, ok := state.namespaces[*namespaceID]; ok {
+		return namespace, nil
+	}
+
+	namespace, failure := h.shortener.userNamespace(state.opts.userID, namespaceID)
+	if failure != nil {
+		return nil, failure
+	}
+	state.namespaces[*namespaceID] = namespace
+	return namespace, nil
+}
+
+// unsupportedSlugReason explains why an imported slug couldn't be reached as a short link, or returns ""
+// Slugs from other shorteners may use characters that are separators here or names the app's own routes take
+func (h *ImportHandler) unsupportedSlugReason(slug string) string {
+	if len(slug) > maxImportedSlugLength {
+		return fmt.Sprintf("it is longer than %d characters", maxImportedSlugLength)
+	}
+	if slug == "." || slug == ".." {
+		return "it is a relative path segment"
+	}
+	for _, ch := range slug {
+		if !unicode.IsLetter(ch) && !unicode.IsDigit(ch) && !strings.ContainsRune("-_.~", ch) {
+			return "it may only contain letters, digits, '-', '_', '.' and '~'"
+		}
+	}
+	if IsReservedNamespaceName(slug) {
+		return "it is a reserved name"
+	}
+	// /slug.qr serves the QR code of /slug, so such a slug would be shadowed by another link's QR code
+	if strings.HasSuffix(slug, qrCodeSuffix) {
+		return fmt.Sprintf("it ends in '%s', which is reserved for QR codes", qrCodeSuffix)
+	}
+	for _, reserved := range h.cfg.LandingReservedPaths {
+		if strings.EqualFold("/"+slug, reserved) {
+			return "it is reserved for the landing page"
+		}
+	}
+	return ""
+}
+
+// failureMessage flattens an error response into one line for an import report
+func failureMessage(failure *apiFailure) string {
+	message, _ := failure.body["error"].(string)
+	if details, ok := failure.body["details"].(string); ok && details != "" {
+		message += ": " + details
+	}
+	if field, ok := failure.body["field"].(string); ok && field != "" {
+		message += " (" + field + ")"
+	}
+	return message
+}
@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"openshortpath/server/config"
+	"openshortpath/server/constants"
+	"openshortpath/server/models"
+	"openshortpath/server/services"
+)
+
+func performImport(handler *ImportHandler, query, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, "user123")
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/short-urls/import?"+query, strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "text/csv")
+	handler.Import(c)
+	return w
+}
+
+func TestImportHandler_Import(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewImportHandler(db, &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	})
+
+	// The job is recorded and marked as running
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "import_jobs"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "import_jobs" SET "status"=\$1 WHERE "id" = \$2`).
+		WithArgs(models.ImportJobRunning, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectQuery(`SELECT (.+) FROM "users"`).
+		WithArgs("user123").
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	// Record 1: the slug is taken, so it is skipped
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "taken").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "user_id"}).AddRow("url-1", "example.com", "taken", "someone"))
+
+	// Record 2: created with a generated slug and its original creation time
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM "monthly_link_limits"`).
+		WithArgs("user123", "user", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectExec(`INSERT INTO "monthly_link_limits"`).
+		WithArgs(sqlmock.AnyArg(), "user123", "user", 1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+	createdAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "short_urls"`).
+		WithArgs(sqlmock.AnyArg(), "example.com", sqlmock.AnyArg(), "https://example.com/b", sqlmock.AnyArg(), "user123", nil, 0, nil, nil, 12, nil, nil, false, false, false, "", "", "", "", "", "", "", "", "", "", 0, 0, nil, "", 0, "", createdAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// Record 3 has a domain that isn't available, so it fails without touching the database
+
+	// The finished job is saved with its report
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "import_jobs"`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	body := "domain,slug,url,click_count,created_at\n" +
+		"example.com,taken,https://example.com/a,0,\n" +
+		"example.com,,https://example.com/b,12,2020-01-02T03:04:05Z\n" +
+		"other.com,c,https://example.com/c,0,\n"
+	w := performImport(handler, "conflict=skip", body)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var job models.ImportJob
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, models.ImportJobCompleted, job.Status)
+	assert.NotNil(t, job.FinishedAt)
+	assert.Equal(t, 3, job.Report.Total)
+	assert.Equal(t, 3, job.Report.Processed)
+	assert.Equal(t, 1, job.Report.Skipped)
+	assert.Equal(t, 1, job.Report.Created)
+	assert.Equal(t, 1, job.Report.Failed)
+	assert.Len(t, job.Report.Results, 3)
+	assert.Equal(t, models.ImportActionSkip, job.Report.Results[0].Action)
+	assert.NotEmpty(t, job.Report.Results[1].Slug)
+	assert.Contains(t, job.Report.Results[2].Error, "other.com")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestImportHandler_Import_DryRun(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewImportHandler(db, &config.Config{
+		AvailableShortDomains: []string{"example.com", "short.example"},
+	})
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "import_jobs"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "import_jobs" SET "status"`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Record 1 collides with another user's link and is renamed; nothing is charged or created.
+	// Every record is moved to the domain given in the query
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("short.example", "taken").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "user_id"}).AddRow("url-1", "short.example", "taken", "someone"))
+	// Record 2 is new
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("short.example", "fresh").
+		WillReturnError(gorm.ErrRecordNotFound)
+	// Record 3 repeats record 2, which the dry run remembers instead of asking the database
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "import_jobs"`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	body := "{\"domain\": \"example.com\", \"slug\": \"taken\", \"url\": \"https://example.com/a\"}\n" +
+		"{\"domain\": \"example.com\", \"slug\": \"fresh\", \"url\": \"https://example.com/b\"}\n" +
+		"{\"domain\": \"example.com\", \"slug\": \"fresh\", \"url\": \"https://example.com/c\"}\n" +
+		"{\"domain\": \"example.com\", \"url\": \"https://example.com/d\", \"has_password\": true}\n"
+	w := performImport(handler, "format=ndjson&conflict=rename&dry_run=true&domain=short.example", body)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var job models.ImportJob
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.True(t, job.DryRun)
+	assert.Equal(t, 2, job.Report.Renamed)
+	assert.Equal(t, 1, job.Report.Created)
+	assert.Equal(t, 1, job.Report.Failed)
+	assert.Equal(t, "taken", job.Report.Results[0].OldSlug)
+	assert.Equal(t, "short.example", job.Report.Results[1].Domain)
+	assert.Equal(t, models.ImportActionRename, job.Report.Results[2].Action)
+	assert.Contains(t, job.Report.Results[3].Error, "password")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestImportHandler_Import_InvalidRequests(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewImportHandler(db, &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	})
+
+	tests := []struct {
+		name  string
+		query string
+		body  string
+	}{
+		{"unknown conflict strategy", "conflict=merge", "url\nhttps://example.com\n"},
+		{"invalid dry_run", "dry_run=maybe", "url\nhttps://example.com\n"},
+		{"unavailable domain", "domain=other.com", "url\nhttps://example.com\n"},
+		{"unknown format", "format=xml", "<links/>"},
+		{"no url column", "", "domain,slug\nexample.com,a\n"},
+		{"foreign format without a domain", "format=bitly-csv", "link,long_url\nbit.ly/a,https://example.com\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := performImport(handler, tt.query, tt.body)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestImportHandler_ImportLinks_ReportsProgress(t *testing.T) {
+	handler := NewImportHandler(nil, &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	})
+
+	var body strings.Builder
+	body.WriteString("domain,url\n")
+	for i := 0; i < 250; i++ {
+		fmt.Fprintf(&body, "example.com,https://example.com/%d\n", i)
+	}
+	reader, err := services.NewLinkImportReader(services.LinkFormatCSV, strings.NewReader(body.String()))
+	assert.NoError(t, err)
+
+	var progress []int
+	report, err := handler.importLinks(context.Background(), importOptions{userID: "user123", conflict: ImportConflictSkip, dryRun: true}, reader, 250, func(report models.ImportReport) {
+		progress = append(progress, report.Processed)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{100, 200}, progress)
+	assert.Equal(t, 250, report.Created)
+}
+
+func TestImportHandler_ImportLinks_StopsWhenCancelled(t *testing.T) {
+	handler := NewImportHandler(nil, &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	})
+
+	reader, err := services.NewLinkImportReader(services.LinkFormatCSV, strings.NewReader("domain,url\nexample.com,https://example.com/a\n"))
+	assert.NoError(t, err)
+
+	// A shutdown stops the import before its next record
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report, err := handler.importLinks(ctx, importOptions{userID: "user123", conflict: ImportConflictSkip, dryRun: true}, reader, 1, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, report.Processed)
+}
+
+func TestFailInterruptedImportJobs(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "import_jobs" SET .+ WHERE status IN \(\$\d+,\$\d+\)`).
+		WithArgs(errImportJobInterrupted.Error(), sqlmock.AnyArg(), models.ImportJobFailed, sqlmock.AnyArg(), models.ImportJobPending, models.ImportJobRunning).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	marked, err := FailInterruptedImportJobs(db)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), marked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestImportHandler_ImportLinks_ReportsUnsupportedSlugs(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewImportHandler(db, &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+		LandingReservedPaths:  []string{"/docs", "/favicon.ico"},
+	})
+
+	// Only the supported slug reaches the database
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "Launch-2024").
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	body := `{"data": [
+		{"address": "Launch-2024", "target": "https://example.com/a"},
+		{"address": "api", "target": "https://example.com/b"},
+		{"address": "docs", "target": "https://example.com/c"},
+		{"address": "a/b", "target": "https://example.com/d"},
+		{"address": "..", "target": "https://example.com/e"},
+		{"address": "launch.qr", "target": "https://example.com/f"}
+	]}`
+	reader, err := services.NewLinkImportReader(services.LinkFormatKuttJSON, strings.NewReader(body))
+	assert.NoError(t, err)
+
+	opts := importOptions{userID: "user123", conflict: ImportConflictSkip, dryRun: true, domain: "example.com"}
+	report, err := handler.importLinks(context.Background(), opts, reader, 6, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 5, report.Failed)
+	assert.Equal(t, "Launch-2024", report.Results[0].Slug)
+	assert.Contains(t, report.Results[1].Error, "reserved name")
+	assert.Contains(t, report.Results[2].Error, "landing page")
+	assert.Contains(t, report.Results[3].Error, "may only contain")
+	assert.Contains(t, report.Results[4].Error, "relative path")
+	assert.Contains(t, report.Results[5].Error, "QR codes")
+	for _, result := range report.Results[1:] {
+		assert.Equal(t, models.ImportActionFail, result.Action)
+		assert.Contains(t, result.Error, "Unsupported slug")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestImportHandler_GetImportJob(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewImportHandler(db, &config.Config{})
+
+	mock.ExpectQuery(`SELECT (.+) FROM "import_jobs" WHERE id = \$1 AND user_id = \$2`).
+		WithArgs("job-1", "user123").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status", "total", "processed", "results"}).
+			AddRow("job-1", "user123", models.ImportJobRunning, 5000, 1200, nil))
+	mock.ExpectQuery(`SELECT (.+) FROM "import_jobs" WHERE id = \$1 AND user_id = \$2`).
+		WithArgs("job-2", "user123").
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, "user123")
+	c.Params = gin.Params{{Key: "id", Value: "job-1"}}
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/import-jobs/job-1", nil)
+	handler.GetImportJob(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var job models.ImportJob
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, 1200, job.Report.Processed)
+	assert.Equal(t, 5000, job.Report.Total)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, "user123")
+	c.Params = gin.Params{{Key: "id", Value: "job-2"}}
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/import-jobs/job-2", nil)
+	handler.GetImportJob(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -11,18 +13,36 @@ import (
 	"openshortpath/server/config"
 	"openshortpath/server/constants"
 	"openshortpath/server/models"
+	"openshortpath/server/services"
+	"openshortpath/server/utils"
 )
 
 type ShortURLsHandler struct {
-	db  *gorm.DB
-	cfg *config.Config
+	db            *gorm.DB
+	cfg           *config.Config
+	redirectCache *services.RedirectCache
+	urlPolicy     *services.URLPolicy
 }
 
 type UpdateShortURLRequest struct {
-	URL         string  `json:"url,omitempty"`
-	Slug        string  `json:"slug,omitempty"`
-	Domain      string  `json:"domain,omitempty"`
-	NamespaceID *string `json:"namespace_id,omitempty"`
+	URL          string  `json:"url,omitempty"`
+	Slug         string  `json:"slug,omitempty"`
+	Domain       string  `json:"domain,omitempty"`
+	NamespaceID  *string `json:"namespace_id,omitempty"`
+	RedirectType *int    `json:"redirect_type,omitempty"` // 0 resets the link to the configured default
+	ExpiresAt    *string `json:"expires_at,omitempty"`    // RFC3339 time; empty string removes the expiry date
+	MaxClicks    *int    `json:"max_clicks,omitempty"`    // 0 removes the click allowance
+	Password     *string `json:"password,omitempty"`      // Empty string removes the password
+	// Weighted A/B variants; an empty list removes the split
+	Destinations       *models.SplitDestinations `json:"destinations,omitempty"`
+	StickyDestinations *bool                     `json:"sticky_destinations,omitempty"`
+	ForwardQuery       *bool                     `json:"forward_query,omitempty"`
+	ForwardPath        *bool                     `json:"forward_path,omitempty"`
+	DeepLink           *models.DeepLink          `json:"deep_link,omitempty"`           // Replaces all deep link URLs; an empty object removes the deep link
+	OpenGraph          *models.OpenGraph         `json:"open_graph,omitempty"`          // Replaces the whole link preview; an empty object removes it
+	HealthFallbackURL  *string                   `json:"health_fallback_url,omitempty"` // Empty string removes the fallback
+	UTMDefaultsUpdate
+	Tags *[]string `json:"tags,omitempty"` // Replaces the link's tags; missing tags are created and an empty list removes them all
 }
 
 type ListResponse struct {
@@ -40,6 +60,17 @@ func NewShortURLsHandler(db *gorm.DB, cfg *config.Config) *ShortURLsHandler {
 	}
 }
 
+// SetRedirectCache sets the redirect cache to invalidate when short URLs are updated or deleted
+func (h *ShortURLsHandler) SetRedirectCache(cache *services.RedirectCache) {
+	h.redirectCache = cache
+}
+
+// SetURLPolicy sets the policy changed destination URLs must pass
+// If no policy is set, destinations are stored as given
+func (h *ShortURLsHandler) SetURLPolicy(policy *services.URLPolicy) {
+	h.urlPolicy = policy
+}
+
 // isValidDomain checks if the domain exists in the available short domains list
 func isValidDomainForUpdate(domain string, availableDomains []string) bool {
 	for _, availableDomain := range availableDomains {
@@ -50,7 +81,71 @@ func isValidDomainForUpdate(domain string, availableDomains []string) bool {
 	return false
 }
 
+// UTMDefaultsUpdate holds optional UTM default changes; an empty string removes a default
+type UTMDefaultsUpdate struct {
+	UTMSource   *string `json:"utm_source,omitempty"`
+	UTMMedium   *string `json:"utm_medium,omitempty"`
+	UTMCampaign *string `json:"utm_campaign,omitempty"`
+}
+
+// addUpdateFields adds the provided UTM defaults to a map of column updates
+func (u UTMDefaultsUpdate) addUpdateFields(updateFields map[string]interface{}) {
+	if u.UTMSource != nil {
+		updateFields["utm_source"] = *u.UTMSource
+	}
+	if u.UTMMedium != nil {
+		updateFields["utm_medium"] = *u.UTMMedium
+	}
+	if u.UTMCampaign != nil {
+		updateFields["utm_campaign"] = *u.UTMCampaign
+	}
+}
+
+// maxSplitDestinations is the maximum number of weighted variants a short URL can carry
+const maxSplitDestinations = 10
+
+// validateSplitDestinations checks that an A/B split has between 2 and maxSplitDestinations variants,
+// each with a URL and a positive weight
+func validateSplitDestinations(destinations models.SplitDestinations) error {
+	if len(destinations) < 2 {
+		return fmt.Errorf("destinations must contain at least 2 entries")
+	}
+	if len(destinations) > maxSplitDestinations {
+		return fmt.Errorf("destinations must not contain more than %d entries", maxSplitDestinations)
+	}
+	for i, destination := range destinations {
+		if destination.URL == "" {
+			return fmt.Errorf("destinations[%d].url is required", i)
+		}
+		if destination.Weight <= 0 {
+			return fmt.Errorf("destinations[%d].weight must be greater than 0", i)
+		}
+	}
+	return nil
+}
+
+// deleteShortURLDependents deletes the records that belong to the given short URLs
+// shortURLIDs may be a slice of IDs or a subquery selecting short URL IDs
+func deleteShortURLDependents(db *gorm.DB, shortURLIDs interface{}) error {
+	if err := db.Where("short_url_id IN (?)", shortURLIDs).Delete(&models.GeoRule{}).Error; err != nil {
+		return fmt.Errorf("failed to delete geo rules: %w", err)
+	}
+	if err := db.Where("short_url_id IN (?)", shortURLIDs).Delete(&models.DeviceRule{}).Error; err != nil {
+		return fmt.Errorf("failed to delete device rules: %w", err)
+	}
+	if err := db.Where("short_url_id IN (?)", shortURLIDs).Delete(&models.ShortURLTag{}).Error; err != nil {
+		return fmt.Errorf("failed to delete tags: %w", err)
+	}
+	if err := db.Where("short_url_id IN (?)", shortURLIDs).Delete(&models.ClickEvent{}).Error; err != nil {
+		return fmt.Errorf("failed to delete click events: %w", err)
+	}
+	return nil
+}
+
 // List returns a paginated list of shortened URLs for the authenticated user
+// ?q= searches slugs and destinations; ?domain=, ?namespace_id=, ?created_after= and ?created_before= filter them
+// ?tags=a,b only lists links with all of the tags, or with any of them when ?tag_mode=any
+// ?sort= orders by created_at (default), updated_at, slug or click_count, and ?order= is asc or desc (default)
 func (h *ShortURLsHandler) List(c *gin.Context) {
 	// Get user ID from context (set by RequireAuth middleware)
 	userIDValue, exists := c.Get(constants.ContextKeyUserID)
@@ -92,9 +187,85 @@ func (h *ShortURLsHandler) List(c *gin.Context) {
 	// Calculate offset
 	offset := (page - 1) * limit
 
+	// Build base query with optional filters
+	query := h.db.Model(&models.ShortURL{}).Where("user_id = ?", userID)
+
+	if search := strings.TrimSpace(c.Query("q")); search != "" {
+		query = searchColumns(query, search, "slug", "url")
+	}
+
+	if domain := c.Query("domain"); domain != "" {
+		query = query.Where("domain = ?", domain)
+	}
+
+	if namespaceID := c.Query("namespace_id"); namespaceID != "" {
+		query = query.Where("namespace_id = ?", namespaceID)
+	}
+
+	query, err := filterByCreatedAt(c, query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	order, err := listOrder(c, "created_at", "updated_at", "slug", "click_count")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if expiredStr := c.Query("expired"); expiredStr != "" {
+		expired, err := strconv.ParseBool(expiredStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid expired parameter (must be true or false)",
+			})
+			return
+		}
+		now := time.Now().UTC()
+		if expired {
+			query = query.Where("(expires_at IS NOT NULL AND expires_at <= ?) OR (max_clicks IS NOT NULL AND click_count >= max_clicks)", now)
+		} else {
+			query = query.Where("(expires_at IS NULL OR expires_at > ?) AND (max_clicks IS NULL OR click_count < max_clicks)", now)
+		}
+	}
+
+	if health := c.Query("health"); health != "" {
+		var ok bool
+		query, ok = filterByHealth(query, health)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid health parameter (must be broken, healthy or unchecked)",
+			})
+			return
+		}
+	}
+
+	if tagsParam := c.Query("tags"); tagsParam != "" {
+		tags, err := normalizeTagNames(strings.Split(tagsParam, ","))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid tags parameter: " + err.Error(),
+			})
+			return
+		}
+		var ok bool
+		query, ok = filterByTags(h.db, query, userID, tags, c.Query("tag_mode"))
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid tag_mode parameter (must be %s or %s)", TagModeAll, TagModeAny),
+			})
+			return
+		}
+	}
+
 	// Query total count
 	var total int64
-	if err := h.db.Model(&models.ShortURL{}).Where("user_id = ?", userID).Count(&total).Error; err != nil {
+	if err := query.Count(&total).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Database error",
 			"details": err.Error(),
@@ -104,8 +275,8 @@ func (h *ShortURLsHandler) List(c *gin.Context) {
 
 	// Query paginated results
 	var urls []models.ShortURL
-	if err := h.db.Where("user_id = ?", userID).
-		Order("created_at DESC").
+	if err := query.
+		Order(order).
 		Offset(offset).
 		Limit(limit).
 		Find(&urls).Error; err != nil {
@@ -116,6 +287,14 @@ func (h *ShortURLsHandler) List(c *gin.Context) {
 		return
 	}
 
+	if err := loadShortURLTags(h.db, urls); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to load tags",
+			"details": err.Error(),
+		})
+		return
+	}
+
 	// Calculate total pages
 	totalPages := int(total) / limit
 	if int(total)%limit > 0 {
@@ -178,6 +357,14 @@ func (h *ShortURLsHandler) Get(c *gin.Context) {
 		return
 	}
 
+	if err := h.loadTags(&shortURL); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to load tags",
+			"details": err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, shortURL)
 }
 
@@ -236,11 +423,34 @@ func (h *ShortURLsHandler) Update(c *gin.Context) {
 		return
 	}
 
+	// Check changed destinations against the URL policy
+	var destinations models.SplitDestinations
+	if req.Destinations != nil {
+		destinations = *req.Destinations
+	}
+	var fallbackURL *string
+	if req.DeepLink != nil {
+		fallbackURL = &req.DeepLink.FallbackURL
+	}
+	if !applyURLPolicy(c, h.urlPolicy, destinations,
+		policyURL{"url", &req.URL},
+		policyURL{"deep_link.fallback_url", fallbackURL},
+		policyURL{"health_fallback_url", req.HealthFallbackURL},
+	) {
+		return
+	}
+
 	// Update fields if provided
 	updateFields := make(map[string]interface{})
 
 	if req.URL != "" {
 		updateFields["url"] = req.URL
+		updateFields["url_hash"] = utils.URLHash(req.URL)
+
+		// A new destination hasn't been checked yet, so forget the old one's health
+		if req.URL != shortURL.URL {
+			resetLinkHealth(updateFields)
+		}
 	}
 
 	if req.Domain != "" {
@@ -307,14 +517,171 @@ func (h *ShortURLsHandler) Update(c *gin.Context) {
 		}
 	}
 
+	// Handle redirect_type update
+	if req.RedirectType != nil {
+		if *req.RedirectType != 0 && !config.IsValidRedirectType(*req.RedirectType) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid redirect_type %d (must be 301, 302, 307 or 308)", *req.RedirectType),
+			})
+			return
+		}
+		updateFields["redirect_type"] = *req.RedirectType
+	}
+
+	// Handle expires_at update
+	if req.ExpiresAt != nil {
+		if *req.ExpiresAt == "" {
+			updateFields["expires_at"] = nil
+		} else {
+			expiresAt, err := time.Parse(time.RFC3339, *req.ExpiresAt)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Invalid expires_at (must be RFC3339)",
+					"details": err.Error(),
+				})
+				return
+			}
+			updateFields["expires_at"] = expiresAt.UTC()
+		}
+	}
+
+	// Handle max_clicks update
+	if req.MaxClicks != nil {
+		if *req.MaxClicks < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "max_clicks must not be negative",
+			})
+			return
+		}
+		if *req.MaxClicks == 0 {
+			updateFields["max_clicks"] = nil
+		} else {
+			updateFields["max_clicks"] = *req.MaxClicks
+		}
+	}
+
+	// Handle password update
+	if req.Password != nil {
+		if *req.Password == "" {
+			updateFields["password_hash"] = nil
+		} else {
+			hashed, err := utils.HashPassword(*req.Password)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "Failed to hash password",
+					"details": err.Error(),
+				})
+				return
+			}
+			updateFields["password_hash"] = hashed
+		}
+	}
+
+	// Handle destinations update
+	if req.Destinations != nil {
+		if len(*req.Destinations) == 0 {
+			updateFields["destinations"] = nil
+		} else {
+			if err := validateSplitDestinations(*req.Destinations); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			updateFields["destinations"] = *req.Destinations
+		}
+	}
+
+	if req.StickyDestinations != nil {
+		updateFields["sticky_destinations"] = *req.StickyDestinations
+	}
+
+	if req.ForwardQuery != nil {
+		updateFields["forward_query"] = *req.ForwardQuery
+	}
+
+	if req.ForwardPath != nil {
+		updateFields["forward_path"] = *req.ForwardPath
+	}
+
+	req.UTMDefaultsUpdate.addUpdateFields(updateFields)
+
+	// Handle deep_link update
+	if req.DeepLink != nil {
+		if err := validateDeepLink(*req.DeepLink); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		updateFields["deep_link_ios_url"] = req.DeepLink.IOSURL
+		updateFields["deep_link_android_url"] = req.DeepLink.AndroidURL
+		updateFields["deep_link_fallback_url"] = req.DeepLink.FallbackURL
+	}
+
+	// Handle open_graph update
+	if req.OpenGraph != nil {
+		if err := validateOpenGraph(*req.OpenGraph); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		updateFields["og_title"] = req.OpenGraph.Title
+		updateFields["og_description"] = req.OpenGraph.Description
+		updateFields["og_image_url"] = req.OpenGraph.ImageURL
+	}
+
+	// Handle health_fallback_url update
+	if req.HealthFallbackURL != nil {
+		if err := validateHealthFallbackURL(*req.HealthFallbackURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		updateFields["health_fallback_url"] = *req.HealthFallbackURL
+	}
+
+	// Handle tags update
+	var tags []string
+	if req.Tags != nil {
+		var err error
+		tags, err = normalizeTagNames(*req.Tags)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+
 	// If no fields to update, return the existing record
-	if len(updateFields) == 0 {
+	if len(updateFields) == 0 && req.Tags == nil {
+		if err := h.loadTags(&shortURL); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to load tags",
+				"details": err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusOK, shortURL)
 		return
 	}
 
-	// Update the record
-	if err := h.db.Model(&shortURL).Updates(updateFields).Error; err != nil {
+	// Update the record and its tags together
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if len(updateFields) > 0 {
+			if err := tx.Model(&shortURL).Updates(updateFields).Error; err != nil {
+				return err
+			}
+		}
+		if req.Tags != nil {
+			return tagShortURL(tx, &shortURL, tags)
+		}
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to update short URL",
 			"details": err.Error(),
@@ -330,10 +697,30 @@ func (h *ShortURLsHandler) Update(c *gin.Context) {
 		})
 		return
 	}
+	if err := h.loadTags(&shortURL); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to load tags",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// Drop cached lookups of the link and any cached 404 for its new location
+	h.redirectCache.InvalidateShortURL(&shortURL)
 
 	c.JSON(http.StatusOK, shortURL)
 }
 
+// loadTags sets the Tags of a single short URL
+func (h *ShortURLsHandler) loadTags(shortURL *models.ShortURL) error {
+	urls := []models.ShortURL{*shortURL}
+	if err := loadShortURLTags(h.db, urls); err != nil {
+		return err
+	}
+	shortURL.Tags = urls[0].Tags
+	return nil
+}
+
 // Delete deletes a shortened URL by ID
 func (h *ShortURLsHandler) Delete(c *gin.Context) {
 	// Get user ID from context
@@ -379,8 +766,14 @@ func (h *ShortURLsHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	// Delete the record
-	if err := h.db.Delete(&shortURL).Error; err != nil {
+	// Delete the record together with the records that belong to it, so a failure leaves both in place
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := deleteShortURLDependents(tx, []string{shortURL.ID}); err != nil {
+			return err
+		}
+		return tx.Delete(&shortURL).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to delete short URL",
 			"details": err.Error(),
@@ -388,5 +781,8 @@ func (h *ShortURLsHandler) Delete(c *gin.Context) {
 		return
 	}
 
+	// Drop cached lookups of the deleted link
+	h.redirectCache.InvalidateShortURL(&shortURL)
+
 	c.AbortWithStatus(http.StatusNoContent)
 }
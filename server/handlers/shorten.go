@@ -1,10 +1,12 @@
 package handlers
 
 import (
-	"crypto/rand"
+	"context"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -14,18 +16,39 @@ import (
 	"openshortpath/server/constants"
 	"openshortpath/server/models"
 	"openshortpath/server/services"
+	"openshortpath/server/utils"
 )
 
 type ShortenHandler struct {
-	db  *gorm.DB
-	cfg *config.Config
+	db            *gorm.DB
+	cfg           *config.Config
+	redirectCache *services.RedirectCache
+	urlPolicy     *services.URLPolicy
 }
 
 type ShortenRequest struct {
-	Domain      string  `json:"domain" binding:"required"`
-	URL         string  `json:"url" binding:"required"`
-	Slug        string  `json:"slug,omitempty"`
-	NamespaceID *string `json:"namespace_id,omitempty"`
+	Domain       string     `json:"domain" binding:"required"`
+	URL          string     `json:"url" binding:"required"`
+	Slug         string     `json:"slug,omitempty"`
+	NamespaceID  *string    `json:"namespace_id,omitempty"`
+	RedirectType int        `json:"redirect_type,omitempty"` // 301, 302, 307 or 308; omitted uses the configured default
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`    // RFC3339 time after which the link stops working
+	MaxClicks    *int       `json:"max_clicks,omitempty"`    // Number of redirects after which the link stops working
+	Password     string     `json:"password,omitempty"`      // Visitors must enter this password before being redirected
+	// Weighted A/B variants picked from on each visit instead of URL
+	Destinations       models.SplitDestinations `json:"destinations,omitempty"`
+	StickyDestinations bool                     `json:"sticky_destinations,omitempty"` // Remember each visitor's variant in a cookie
+	ForwardQuery       bool                     `json:"forward_query,omitempty"`       // Merge the visitor's query string into the destination
+	ForwardPath        bool                     `json:"forward_path,omitempty"`        // Append the rest of the visited path (/slug/rest) to the destination
+	DeepLink           models.DeepLink          `json:"deep_link,omitempty"`           // App URLs tried on iOS and Android before the web destination
+	OpenGraph          models.OpenGraph         `json:"open_graph,omitempty"`          // Link preview served to social media crawlers
+	HealthFallbackURL  string                   `json:"health_fallback_url,omitempty"` // Served instead of URL while the link health checker finds it broken
+	models.UTMDefaults
+	Tags []string `json:"tags,omitempty"` // Names of the user's tags to put on the link; missing tags are created
+	// Return the caller's existing link to the same destination instead of creating another;
+	// omitted uses the user's dedup_links setting. Only POST /api/v1/shorten deduplicates, and only
+	// requests that set no other link options
+	Dedup *bool `json:"dedup,omitempty"`
 }
 
 func NewShortenHandler(db *gorm.DB, cfg *config.Config) *ShortenHandler {
@@ -35,22 +58,41 @@ func NewShortenHandler(db *gorm.DB, cfg *config.Config) *ShortenHandler {
 	}
 }
 
-// generateRandomSlug generates a random 5-character alphanumeric string
-func generateRandomSlug() (string, error) {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	const length = 5
+// SetRedirectCache sets the redirect cache to invalidate when short URLs are created
+func (h *ShortenHandler) SetRedirectCache(cache *services.RedirectCache) {
+	h.redirectCache = cache
+}
 
-	bytes := make([]byte, length)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", err
-	}
+// SetURLPolicy sets the policy destination URLs must pass
+// If no policy is set, destinations are stored as given
+func (h *ShortenHandler) SetURLPolicy(policy *services.URLPolicy) {
+	h.urlPolicy = policy
+}
+
+// maxGeneratedSlugInserts is how many generated slugs are tried when inserts lose races for them
+const maxGeneratedSlugInserts = 3
 
-	result := make([]byte, length)
-	for i := range result {
-		result[i] = charset[bytes[i]%byte(len(charset))]
+// slugGeneration returns the slug generation settings for a new link
+// A namespace with its own strategy overrides the deployment's settings
+func (h *ShortenHandler) slugGeneration(namespace *models.Namespace) models.SlugGeneration {
+	if namespace != nil && namespace.SlugGeneration.Strategy != "" {
+		return namespace.SlugGeneration
+	}
+	return models.SlugGeneration{
+		Strategy: h.cfg.SlugGeneration.Strategy,
+		Length:   h.cfg.SlugGeneration.Length,
+		Alphabet: h.cfg.SlugGeneration.Alphabet,
 	}
+}
 
-	return string(result), nil
+// generateSlug generates an unused slug on domain with the strategy in effect for namespace
+// db may be a transaction, so slugs created earlier in it are seen as taken
+func (h *ShortenHandler) generateSlug(db *gorm.DB, domain string, namespace *models.Namespace) (string, error) {
+	generator, err := services.NewSlugGenerator(db, h.slugGeneration(namespace), domain)
+	if err != nil {
+		return "", err
+	}
+	return services.GenerateUniqueSlug(db, generator, domain)
 }
 
 // isValidDomain checks if the domain exists in the available short domains list
@@ -63,72 +105,117 @@ func isValidDomain(domain string, availableDomains []string) bool {
 	return false
 }
 
-func (h *ShortenHandler) Shorten(c *gin.Context) {
-	var req ShortenRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request body",
-			"details": err.Error(),
-		})
-		return
-	}
+// apiFailure is the error response for an API request that can't be fulfilled
+type apiFailure struct {
+	status int
+	body   gin.H
+}
 
+// validateShortenRequest checks the fields of a shorten request that don't need the database
+// Destination URLs are normalized in place by the URL policy
+func (h *ShortenHandler) validateShortenRequest(ctx context.Context, req *ShortenRequest) *apiFailure {
 	// Validate domain
 	if !isValidDomain(req.Domain, h.cfg.AvailableShortDomains) {
-		c.JSON(http.StatusBadRequest, gin.H{
+		return &apiFailure{http.StatusBadRequest, gin.H{
 			"error": fmt.Sprintf("Domain '%s' is not in the list of available short domains", req.Domain),
-		})
-		return
+		}}
 	}
 
-	// Generate slug if not provided
-	slug := req.Slug
-	if slug == "" {
-		var err error
-		slug, err = generateRandomSlug()
+	// Validate redirect type if provided
+	if req.RedirectType != 0 && !config.IsValidRedirectType(req.RedirectType) {
+		return &apiFailure{http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid redirect_type %d (must be 301, 302, 307 or 308)", req.RedirectType),
+		}}
+	}
+
+	// Validate expiration settings if provided
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return &apiFailure{http.StatusBadRequest, gin.H{
+			"error": "expires_at must be in the future",
+		}}
+	}
+	if req.MaxClicks != nil && *req.MaxClicks <= 0 {
+		return &apiFailure{http.StatusBadRequest, gin.H{
+			"error": "max_clicks must be greater than 0",
+		}}
+	}
+
+	// Validate split destinations if provided
+	if len(req.Destinations) > 0 {
+		if err := validateSplitDestinations(req.Destinations); err != nil {
+			return &apiFailure{http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			}}
+		}
+	}
+
+	// Validate deep link if provided
+	if err := validateDeepLink(req.DeepLink); err != nil {
+		return &apiFailure{http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		}}
+	}
+
+	// Validate health fallback URL if provided
+	if err := validateHealthFallbackURL(req.HealthFallbackURL); err != nil {
+		return &apiFailure{http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		}}
+	}
+
+	// Check destinations against the URL policy
+	if rejection := urlPolicyRejection(ctx, h.urlPolicy, req.Destinations,
+		policyURL{"url", &req.URL},
+		policyURL{"deep_link.fallback_url", &req.DeepLink.FallbackURL},
+		policyURL{"health_fallback_url", &req.HealthFallbackURL},
+	); rejection != nil {
+		return &apiFailure{http.StatusBadRequest, rejection}
+	}
+
+	// Validate Open Graph preview if provided
+	if err := validateOpenGraph(req.OpenGraph); err != nil {
+		return &apiFailure{http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		}}
+	}
+
+	// Validate tags if provided
+	if len(req.Tags) > 0 {
+		tags, err := normalizeTagNames(req.Tags)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Failed to generate random slug",
-			})
-			return
+			return &apiFailure{http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			}}
 		}
+		req.Tags = tags
 	}
 
-	// Check for duplicate (domain, slug) combination
+	return nil
+}
+
+// checkSlugAvailable checks that a chosen slug isn't already used on domain
+func (h *ShortenHandler) checkSlugAvailable(domain, slug string) *apiFailure {
 	var existing models.ShortURL
-	result := h.db.Where("domain = ? AND slug = ?", req.Domain, slug).First(&existing)
+	result := h.db.Where("domain = ? AND slug = ?", domain, slug).First(&existing)
 	if result.Error == nil {
 		// Record exists
-		c.JSON(http.StatusConflict, gin.H{
-			"error": fmt.Sprintf("Short URL with domain '%s' and slug '%s' already exists", req.Domain, slug),
-		})
-		return
+		return &apiFailure{http.StatusConflict, gin.H{
+			"error": fmt.Sprintf("Short URL with domain '%s' and slug '%s' already exists", domain, slug),
+		}}
 	}
 	if result.Error != gorm.ErrRecordNotFound {
 		// Database error
-		c.JSON(http.StatusInternalServerError, gin.H{
+		return &apiFailure{http.StatusInternalServerError, gin.H{
 			"error":   "Database error",
 			"details": result.Error.Error(),
-		})
-		return
+		}}
 	}
+	return nil
+}
 
-	// Get user ID from context if available (from JWT token)
-	userID := ""
-	if userIDValue, exists := c.Get(constants.ContextKeyUserID); exists {
-		if userIDStr, ok := userIDValue.(string); ok {
-			userID = userIDStr
-		}
-	}
-
-	// Check monthly link limit before creating the link
-	clientIP := services.GetClientIP(c)
-	var monthlyLimitInfo *services.MonthlyLinkLimitInfo
-	var err error
-	var limitType string
-	var identifier string
-	var limitPerMonth int
-
+// monthlyLinkLimit returns who new links are counted against and their monthly limit
+// Authenticated users are limited by plan, anonymous users by IP
+func (h *ShortenHandler) monthlyLinkLimit(userID, clientIP string) (string, string, int) {
 	if userID != "" {
 		// Authenticated user - check user-level monthly limit
 		plan, planErr := services.GetUserPlan(h.db, userID)
@@ -136,32 +223,15 @@ func (h *ShortenHandler) Shorten(c *gin.Context) {
 			// If we can't get the plan, default to hobbyist limit
 			plan = constants.PlanHobbyist
 		}
-
-		limitPerMonth = services.GetMonthlyLinkLimitForPlan(plan)
-		limitType = constants.RateLimitTypeUser
-		identifier = userID
-
-		// Check monthly link limit
-		monthlyLimitInfo, err = services.CheckMonthlyLinkLimit(h.db, identifier, limitType, limitPerMonth)
-	} else {
-		// Anonymous user - check IP-level monthly limit
-		limitPerMonth = 1000 // Anonymous users: 1,000 links per month per IP
-		limitType = constants.RateLimitTypeIP
-		identifier = clientIP
-
-		// Check monthly link limit
-		monthlyLimitInfo, err = services.CheckMonthlyLinkLimit(h.db, identifier, limitType, limitPerMonth)
+		return userID, constants.RateLimitTypeUser, services.GetMonthlyLinkLimitForPlan(plan)
 	}
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Monthly link limit check failed",
-			"details": err.Error(),
-		})
-		return
-	}
+	// Anonymous user - check IP-level monthly limit
+	return clientIP, constants.RateLimitTypeIP, 1000 // Anonymous users: 1,000 links per month per IP
+}
 
-	// Set monthly link limit headers
+// setMonthlyLinkLimitHeaders sets the X-Monthly-Link-* response headers
+func setMonthlyLinkLimitHeaders(c *gin.Context, monthlyLimitInfo *services.MonthlyLinkLimitInfo) {
 	if monthlyLimitInfo.Limit > 0 {
 		c.Header("X-Monthly-Link-Limit", strconv.Itoa(monthlyLimitInfo.Limit))
 		if monthlyLimitInfo.Remaining >= 0 {
@@ -171,42 +241,148 @@ func (h *ShortenHandler) Shorten(c *gin.Context) {
 			c.Header("X-Monthly-Link-Reset", strconv.FormatInt(monthlyLimitInfo.Reset.Unix(), 10))
 		}
 	}
+}
 
-	if monthlyLimitInfo.Exceeded {
-		resetTimeStr := "the start of next month"
-		if !monthlyLimitInfo.Reset.IsZero() {
-			resetTimeStr = monthlyLimitInfo.Reset.Format("2006-01-02 15:04:05 UTC")
+// monthlyLinkLimitExceeded returns the error for a request over the monthly link limit
+func monthlyLinkLimitExceeded(monthlyLimitInfo *services.MonthlyLinkLimitInfo) *apiFailure {
+	resetTimeStr := "the start of next month"
+	if !monthlyLimitInfo.Reset.IsZero() {
+		resetTimeStr = monthlyLimitInfo.Reset.Format("2006-01-02 15:04:05 UTC")
+	}
+	return &apiFailure{http.StatusTooManyRequests, gin.H{
+		"error": fmt.Sprintf("Monthly link limit exceeded. Limit: %d links per month. Reset time: %s", monthlyLimitInfo.Limit, resetTimeStr),
+	}}
+}
+
+// userNamespace loads the namespace a new link is created in and checks the user owns it
+// Returns nil without error when no namespace was requested
+func (h *ShortenHandler) userNamespace(userID string, namespaceID *string) (*models.Namespace, *apiFailure) {
+	if namespaceID == nil || *namespaceID == "" {
+		return nil, nil
+	}
+
+	if userID == "" {
+		return nil, &apiFailure{http.StatusUnauthorized, gin.H{
+			"error": "Authentication required to use namespace",
+		}}
+	}
+
+	namespace := &models.Namespace{}
+	result := h.db.Where("id = ? AND user_id = ?", *namespaceID, userID).First(namespace)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, &apiFailure{http.StatusForbidden, gin.H{
+				"error": "Namespace not found or you do not have permission to use it",
+			}}
 		}
-		c.JSON(http.StatusTooManyRequests, gin.H{
-			"error": fmt.Sprintf("Monthly link limit exceeded. Limit: %d links per month. Reset time: %s", monthlyLimitInfo.Limit, resetTimeStr),
-		})
-		return
+		return nil, &apiFailure{http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"details": result.Error.Error(),
+		}}
 	}
+	return namespace, nil
+}
 
-	// Validate namespace ownership if namespace_id is provided
-	if req.NamespaceID != nil && *req.NamespaceID != "" {
-		if userID == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Authentication required to use namespace",
-			})
-			return
+// hasLinkOptions reports whether a shorten request sets any link option besides its destination
+// Slug, namespace and the dedup flag itself aren't options of the stored link
+func (req *ShortenRequest) hasLinkOptions() bool {
+	return req.RedirectType != 0 ||
+		req.ExpiresAt != nil ||
+		req.MaxClicks != nil ||
+		req.Password != "" ||
+		len(req.Destinations) > 0 ||
+		req.StickyDestinations ||
+		req.ForwardQuery ||
+		req.ForwardPath ||
+		req.DeepLink != (models.DeepLink{}) ||
+		req.OpenGraph != (models.OpenGraph{}) ||
+		req.HealthFallbackURL != "" ||
+		req.UTMDefaults != (models.UTMDefaults{}) ||
+		len(req.Tags) > 0
+}
+
+// shouldDedup reports whether a shorten request reuses an existing link to its destination
+// A chosen slug or any other link option always gets a new link, so a caller asking for e.g. a
+// password or click limit never gets back a plain link. Otherwise the request's dedup flag wins
+// over the user's default. Anonymous links have no owner to match, so they are never deduplicated
+func (h *ShortenHandler) shouldDedup(req *ShortenRequest, userID string) (bool, *apiFailure) {
+	if userID == "" || req.Slug != "" || req.hasLinkOptions() {
+		return false, nil
+	}
+	if req.Dedup != nil {
+		return *req.Dedup, nil
+	}
+
+	var user models.User
+	result := h.db.Select("dedup_links").Where("user_id = ?", userID).First(&user)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return false, nil
 		}
+		return false, &apiFailure{http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"details": result.Error.Error(),
+		}}
+	}
+	return user.DedupLinks, nil
+}
+
+// findDuplicateShortURL returns the user's oldest link to the same normalized destination on the
+// request's domain and namespace, or nil if there is none. Expired links aren't reused
+func (h *ShortenHandler) findDuplicateShortURL(req *ShortenRequest, userID string) (*models.ShortURL, *apiFailure) {
+	query := h.db.Where("user_id = ? AND url_hash = ? AND domain = ?", userID, utils.URLHash(req.URL), req.Domain)
+	if req.NamespaceID != nil && *req.NamespaceID != "" {
+		query = query.Where("namespace_id = ?", *req.NamespaceID)
+	} else {
+		query = query.Where("namespace_id IS NULL")
+	}
+
+	var candidates []models.ShortURL
+	if err := query.Order("created_at ASC").Find(&candidates).Error; err != nil {
+		return nil, &apiFailure{http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"details": err.Error(),
+		}}
+	}
 
-		var namespace models.Namespace
-		result := h.db.Where("id = ? AND user_id = ?", *req.NamespaceID, userID).First(&namespace)
-		if result.Error != nil {
-			if result.Error == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusForbidden, gin.H{
-					"error": "Namespace not found or you do not have permission to use it",
-				})
-				return
+	// The hash only narrows the search, so the destinations themselves are compared too
+	normalizedURL := utils.NormalizeURL(req.URL)
+	now := time.Now()
+	for i := range candidates {
+		if !candidates[i].IsExpired(now) && utils.NormalizeURL(candidates[i].URL) == normalizedURL {
+			if err := loadShortURLTags(h.db, candidates[i:i+1]); err != nil {
+				return nil, &apiFailure{http.StatusInternalServerError, gin.H{
+					"error":   "Failed to load tags",
+					"details": err.Error(),
+				}}
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Database error",
-				"details": result.Error.Error(),
-			})
-			return
+			return &candidates[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// createShortURL generates a slug if none was chosen and stores a validated shorten request
+// db may be a transaction; the redirect cache is left for the caller to invalidate once committed
+func (h *ShortenHandler) createShortURL(db *gorm.DB, req *ShortenRequest, userID string, namespace *models.Namespace) (*models.ShortURL, *apiFailure) {
+	// Hash link password if provided
+	var passwordHash *string
+	if req.Password != "" {
+		hashed, err := utils.HashPassword(req.Password)
+		if err != nil {
+			return nil, &apiFailure{http.StatusInternalServerError, gin.H{
+				"error":   "Failed to hash password",
+				"details": err.Error(),
+			}}
 		}
+		passwordHash = &hashed
+	}
+
+	// Store expiry in UTC like updates do, so stored times compare correctly
+	expiresAt := req.ExpiresAt
+	if expiresAt != nil {
+		utc := expiresAt.UTC()
+		expiresAt = &utc
 	}
 
 	// Generate UUID for ID field
@@ -214,22 +390,159 @@ func (h *ShortenHandler) Shorten(c *gin.Context) {
 
 	// Create new ShortURL record
 	shortURL := models.ShortURL{
-		ID:          id,
-		Domain:      req.Domain,
-		Slug:        slug,
-		URL:         req.URL,
-		UserID:      userID,
-		NamespaceID: req.NamespaceID,
+		ID:                 id,
+		Domain:             req.Domain,
+		Slug:               req.Slug,
+		URL:                req.URL,
+		UserID:             userID,
+		NamespaceID:        req.NamespaceID,
+		RedirectType:       req.RedirectType,
+		ExpiresAt:          expiresAt,
+		MaxClicks:          req.MaxClicks,
+		PasswordHash:       passwordHash,
+		Destinations:       req.Destinations,
+		StickyDestinations: req.StickyDestinations,
+		ForwardQuery:       req.ForwardQuery,
+		ForwardPath:        req.ForwardPath,
+		UTMDefaults:        req.UTMDefaults,
+		DeepLink:           req.DeepLink,
+		OpenGraph:          req.OpenGraph,
+		HealthFallbackURL:  req.HealthFallbackURL,
+	}
+
+	// A generated slug is only checked before the insert, so another request can take it first. The insert
+	// then fails on the unique index and is retried with a new slug. Each insert runs in its own transaction
+	// (a savepoint when db already is one), so a failed insert leaves the caller's transaction usable
+	for attempt := 1; ; attempt++ {
+		if req.Slug == "" {
+			slug, err := h.generateSlug(db, req.Domain, namespace)
+			if err != nil {
+				return nil, &apiFailure{http.StatusInternalServerError, gin.H{
+					"error":   "Failed to generate slug",
+					"details": err.Error(),
+				}}
+			}
+			shortURL.Slug = slug
+		}
+
+		// Tags are added in the same transaction, so a link is never stored without them
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&shortURL).Error; err != nil {
+				return err
+			}
+			if len(req.Tags) == 0 {
+				return nil
+			}
+			return tagShortURL(tx, &shortURL, req.Tags)
+		})
+		if err == nil {
+			return &shortURL, nil
+		}
+		if req.Slug != "" || attempt >= maxGeneratedSlugInserts || !isUniqueViolation(err) {
+			return nil, &apiFailure{http.StatusInternalServerError, gin.H{
+				"error":   "Failed to create short URL",
+				"details": err.Error(),
+			}}
+		}
+	}
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation
+func isUniqueViolation(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, "UNIQUE constraint") || strings.Contains(message, "duplicate key")
+}
+
+func (h *ShortenHandler) Shorten(c *gin.Context) {
+	var req ShortenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if failure := h.validateShortenRequest(c.Request.Context(), &req); failure != nil {
+		c.JSON(failure.status, failure.body)
+		return
+	}
+
+	// Check for duplicate (domain, slug) combination if a slug was chosen
+	// Generated slugs are checked when they are generated
+	if req.Slug != "" {
+		if failure := h.checkSlugAvailable(req.Domain, req.Slug); failure != nil {
+			c.JSON(failure.status, failure.body)
+			return
+		}
+	}
+
+	// Get user ID from context if available (from JWT token)
+	userID := ""
+	if userIDValue, exists := c.Get(constants.ContextKeyUserID); exists {
+		if userIDStr, ok := userIDValue.(string); ok {
+			userID = userIDStr
+		}
+	}
+
+	if failure := requireTagOwner(req.Tags, userID); failure != nil {
+		c.JSON(failure.status, failure.body)
+		return
+	}
+
+	// Return the caller's existing link to the same destination if deduplication is on
+	// A reused link doesn't count against the monthly link limit
+	dedup, failure := h.shouldDedup(&req, userID)
+	if failure != nil {
+		c.JSON(failure.status, failure.body)
+		return
+	}
+	if dedup {
+		existing, failure := h.findDuplicateShortURL(&req, userID)
+		if failure != nil {
+			c.JSON(failure.status, failure.body)
+			return
+		}
+		if existing != nil {
+			c.JSON(http.StatusOK, existing)
+			return
+		}
 	}
 
-	if err := h.db.Create(&shortURL).Error; err != nil {
+	// Check monthly link limit before creating the link
+	identifier, limitType, limitPerMonth := h.monthlyLinkLimit(userID, services.GetClientIP(c))
+	monthlyLimitInfo, err := services.CheckMonthlyLinkLimit(h.db, identifier, limitType, limitPerMonth)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to create short URL",
+			"error":   "Monthly link limit check failed",
 			"details": err.Error(),
 		})
 		return
 	}
 
+	setMonthlyLinkLimitHeaders(c, monthlyLimitInfo)
+	if monthlyLimitInfo.Exceeded {
+		failure := monthlyLinkLimitExceeded(monthlyLimitInfo)
+		c.JSON(failure.status, failure.body)
+		return
+	}
+
+	// Validate namespace ownership if namespace_id is provided
+	namespace, failure := h.userNamespace(userID, req.NamespaceID)
+	if failure != nil {
+		c.JSON(failure.status, failure.body)
+		return
+	}
+
+	shortURL, failure := h.createShortURL(h.db, &req, userID, namespace)
+	if failure != nil {
+		c.JSON(failure.status, failure.body)
+		return
+	}
+
+	// Drop any cached 404 for the new link
+	h.redirectCache.InvalidateShortURL(shortURL)
+
 	// Return the full ShortURL object
 	c.JSON(http.StatusCreated, shortURL)
 }
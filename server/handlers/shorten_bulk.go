@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"openshortpath/server/constants"
+	"openshortpath/server/models"
+	"openshortpath/server/services"
+)
+
+// Bulk shorten modes
+const (
+	BulkShortenModeTransactional = "transactional" // Every item is created or none are
+	BulkShortenModeBestEffort    = "best_effort"   // Valid items are created, the rest are reported
+)
+
+// MaxBulkShortenItems is the largest number of items accepted by one bulk shorten request
+const MaxBulkShortenItems = 500
+
+type BulkShortenRequest struct {
+	Mode  string           `json:"mode,omitempty"` // "transactional" (default) or "best_effort"
+	Items []ShortenRequest `json:"items" binding:"required"`
+}
+
+// BulkShortenResult is the outcome of one item of a bulk shorten request
+// Status is the HTTP status POST /api/v1/shorten would have returned for the item
+type BulkShortenResult struct {
+	Index    int              `json:"index"`
+	Status   int              `json:"status"`
+	ShortURL *models.ShortURL `json:"short_url,omitempty"`
+	Error    string           `json:"error,omitempty"`
+	Details  string           `json:"details,omitempty"`
+	Code     string           `json:"code,omitempty"`  // URL policy error code
+	Field    string           `json:"field,omitempty"` // Field rejected by the URL policy
+}
+
+type BulkShortenResponse struct {
+	Mode    string              `json:"mode"`
+	Created int                 `json:"created"`
+	Failed  int                 `json:"failed"`
+	Error   string              `json:"error,omitempty"`
+	Results []BulkShortenResult `json:"results"`
+}
+
+// setFailure fills in the result of an item that could not be created
+func (r *BulkShortenResult) setFailure(failure *apiFailure) {
+	r.Status = failure.status
+	r.Error, _ = failure.body["error"].(string)
+	r.Details, _ = failure.body["details"].(string)
+	r.Code, _ = failure.body["code"].(string)
+	r.Field, _ = failure.body["field"].(string)
+}
+
+// bulkShortenTxError carries an item's failure out of the transaction that creates a transactional batch
+type bulkShortenTxError struct {
+	index   int
+	failure *apiFailure
+}
+
+func (e *bulkShortenTxError) Error() string {
+	return fmt.Sprintf("item %d failed", e.index)
+}
+
+// ShortenBulk handles POST /api/v1/shorten/bulk
+// Items are validated like POST /api/v1/shorten. In transactional mode any invalid item rejects the
+// whole batch; in best effort mode every item gets its own result. Only created links count
+// against the monthly link limit
+func (h *ShortenHandler) ShortenBulk(c *gin.Context) {
+	var req BulkShortenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if req.Mode == "" {
+		req.Mode = BulkShortenModeTransactional
+	}
+	if req.Mode != BulkShortenModeTransactional && req.Mode != BulkShortenModeBestEffort {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid mode '%s' (must be '%s' or '%s')", req.Mode, BulkShortenModeTransactional, BulkShortenModeBestEffort),
+		})
+		return
+	}
+	if len(req.Items) == 0 || len(req.Items) > MaxBulkShortenItems {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("items must contain between 1 and %d short URLs", MaxBulkShortenItems),
+		})
+		return
+	}
+
+	// Get user ID from context if available (from JWT token)
+	userID := ""
+	if userIDValue, exists := c.Get(constants.ContextKeyUserID); exists {
+		if userIDStr, ok := userIDValue.(string); ok {
+			userID = userIDStr
+		}
+	}
+
+	response := BulkShortenResponse{
+		Mode:    req.Mode,
+		Results: make([]BulkShortenResult, len(req.Items)),
+	}
+	namespaces := make([]*models.Namespace, len(req.Items))
+
+	// Validate every item before anything is charged or created
+	var valid []int
+	chosenSlugs := make(map[string]int)
+	loadedNamespaces := make(map[string]*models.Namespace)
+	for i := range req.Items {
+		item := &req.Items[i]
+		response.Results[i].Index = i
+
+		failure := h.validateBulkShortenItem(c, item, i, userID, chosenSlugs, loadedNamespaces)
+		if failure != nil {
+			response.Results[i].setFailure(failure)
+			response.Failed++
+			continue
+		}
+		if item.NamespaceID != nil && *item.NamespaceID != "" {
+			namespaces[i] = loadedNamespaces[*item.NamespaceID]
+		}
+		valid = append(valid, i)
+	}
+
+	if req.Mode == BulkShortenModeTransactional && response.Failed > 0 {
+		h.rejectBulkShorten(c, &response, fmt.Sprintf("No short URLs were created because %d of %d items are invalid", response.Failed, len(req.Items)))
+		return
+	}
+
+	// Charge the monthly link limit for the valid items up front; whatever isn't created is handed back
+	identifier, limitType, limitPerMonth := h.monthlyLinkLimit(userID, services.GetClientIP(c))
+	granted, monthlyLimitInfo, err := services.ReserveMonthlyLinks(h.db, identifier, limitType, limitPerMonth, len(valid), req.Mode == BulkShortenModeBestEffort)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Monthly link limit check failed",
+			"details": err.Error(),
+		})
+		return
+	}
+	setMonthlyLinkLimitHeaders(c, monthlyLimitInfo)
+
+	if granted < len(valid) {
+		exceeded := monthlyLinkLimitExceeded(monthlyLimitInfo)
+		if req.Mode == BulkShortenModeTransactional {
+			c.JSON(exceeded.status, exceeded.body)
+			return
+		}
+		for _, i := range valid[granted:] {
+			response.Results[i].setFailure(exceeded)
+			response.Failed++
+		}
+		valid = valid[:granted]
+	}
+
+	// Items with a chosen slug are created first, so generated slugs can't take them
+	ordered := make([]int, 0, len(valid))
+	for _, i := range valid {
+		if req.Items[i].Slug != "" {
+			ordered = append(ordered, i)
+		}
+	}
+	for _, i := range valid {
+		if req.Items[i].Slug == "" {
+			ordered = append(ordered, i)
+		}
+	}
+
+	created := make([]*models.ShortURL, 0, len(ordered))
+	if req.Mode == BulkShortenModeTransactional {
+		err = h.db.Transaction(func(tx *gorm.DB) error {
+			for _, i := range ordered {
+				shortURL, failure := h.createShortURL(tx, &req.Items[i], userID, namespaces[i])
+				if failure != nil {
+					return &bulkShortenTxError{index: i, failure: failure}
+				}
+				response.Results[i].Status = http.StatusCreated
+				response.Results[i].ShortURL = shortURL
+				created = append(created, shortURL)
+			}
+			return nil
+		})
+		if err != nil {
+			h.releaseMonthlyLinks(identifier, limitType, limitPerMonth, granted)
+
+			var txErr *bulkShortenTxError
+			if !errors.As(err, &txErr) {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "Failed to create short URLs",
+					"details": err.Error(),
+				})
+				return
+			}
+			for i := range response.Results {
+				response.Results[i].Status = 0
+				response.Results[i].ShortURL = nil
+			}
+			response.Results[txErr.index].setFailure(txErr.failure)
+			response.Failed = 1
+			h.rejectBulkShorten(c, &response, fmt.Sprintf("No short URLs were created because item %d failed", txErr.index))
+			return
+		}
+	} else {
+		for _, i := range ordered {
+			shortURL, failure := h.createShortURL(h.db, &req.Items[i], userID, namespaces[i])
+			if failure != nil {
+				response.Results[i].setFailure(failure)
+				response.Failed++
+				continue
+			}
+			response.Results[i].Status = http.StatusCreated
+			response.Results[i].ShortURL = shortURL
+			created = append(created, shortURL)
+		}
+		h.releaseMonthlyLinks(identifier, limitType, limitPerMonth, granted-len(created))
+	}
+
+	for _, shortURL := range created {
+		// Drop any cached 404 for the new link
+		h.redirectCache.InvalidateShortURL(shortURL)
+	}
+	response.Created = len(created)
+
+	if response.Failed > 0 {
+		c.JSON(http.StatusMultiStatus, response)
+		return
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// validateBulkShortenItem runs the checks POST /api/v1/shorten makes before creating a link
+// chosenSlugs remembers the slugs chosen earlier in the batch, and loadedNamespaces the namespaces
+// already found, so repeated namespaces are only looked up once
+func (h *ShortenHandler) validateBulkShortenItem(c *gin.Context, item *ShortenRequest, index int, userID string, chosenSlugs map[string]int, loadedNamespaces map[string]*models.Namespace) *apiFailure {
+	if item.Domain == "" || item.URL == "" {
+		return &apiFailure{http.StatusBadRequest, gin.H{
+			"error": "domain and url are required",
+		}}
+	}
+
+	if failure := h.validateShortenRequest(c.Request.Context(), item); failure != nil {
+		return failure
+	}
+
+	if failure := requireTagOwner(item.Tags, userID); failure != nil {
+		return failure
+	}
+
+	if item.Slug != "" {
+		key := item.Domain + "/" + item.Slug
+		if first, ok := chosenSlugs[key]; ok {
+			return &apiFailure{http.StatusConflict, gin.H{
+				"error": fmt.Sprintf("Slug '%s' on domain '%s' is already used by item %d", item.Slug, item.Domain, first),
+			}}
+		}
+		if failure := h.checkSlugAvailable(item.Domain, item.Slug); failure != nil {
+			return failure
+		}
+		chosenSlugs[key] = index
+	}
+
+	if item.NamespaceID != nil && *item.NamespaceID != "" {
+		if _, ok := loadedNamespaces[*item.NamespaceID]; !ok {
+			namespace, failure := h.userNamespace(userID, item.NamespaceID)
+			if failure != nil {
+				return failure
+			}
+			loadedNamespaces[*item.NamespaceID] = namespace
+		}
+	}
+
+	return nil
+}
+
+// rejectBulkShorten responds to a transactional batch that created nothing
+// The status is that of the first failed item, and only failed items are listed
+func (h *ShortenHandler) rejectBulkShorten(c *gin.Context, response *BulkShortenResponse, message string) {
+	failed := make([]BulkShortenResult, 0, response.Failed)
+	for _, result := range response.Results {
+		if result.Status != 0 {
+			failed = append(failed, result)
+		}
+	}
+	response.Results = failed
+	response.Created = 0
+	response.Error = message
+	c.JSON(failed[0].Status, response)
+}
+
+// releaseMonthlyLinks hands back reserved links that were not created
+// Failing to do so only overcharges the user, so the error is logged rather than returned
+func (h *ShortenHandler) releaseMonthlyLinks(identifier, limitType string, limitPerMonth, count int) {
+	if limitPerMonth == 0 {
+		return
+	}
+	if err := services.ReleaseMonthlyLinks(h.db, identifier, limitType, count); err != nil {
+		log.Printf("Failed to release %d monthly links for %s %s: %v", count, limitType, identifier, err)
+	}
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"openshortpath/server/config"
+)
+
+func performBulkShorten(handler *ShortenHandler, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/shorten/bulk", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	handler.ShortenBulk(c)
+	return w
+}
+
+func TestShortenHandler_ShortenBulk_Transactional(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewShortenHandler(db, &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	})
+
+	// The chosen slug is checked while validating
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "launch").
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	// Both links are charged at once
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM "monthly_link_limits"`).
+		WithArgs(sqlmock.AnyArg(), "ip", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectExec(`INSERT INTO "monthly_link_limits"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "ip", 2, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// Both links are created in one transaction, the chosen slug first
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "short_urls"`).
+		WithArgs(sqlmock.AnyArg(), "example.com", "launch", "https://example.com/b", sqlmock.AnyArg(), "", nil, 0, nil, nil, 0, nil, nil, false, false, false, "", "", "", "", "", "", "", "", "", "", 0, 0, nil, "", 0, "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectExec(`INSERT INTO "short_urls"`).
+		WithArgs(sqlmock.AnyArg(), "example.com", sqlmock.AnyArg(), "https://example.com/a", sqlmock.AnyArg(), "", nil, 0, nil, nil, 0, nil, nil, false, false, false, "", "", "", "", "", "", "", "", "", "", 0, 0, nil, "", 0, "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	w := performBulkShorten(handler, `{"items": [
+		{"domain": "example.com", "url": "https://example.com/a"},
+		{"domain": "example.com", "url": "https://example.com/b", "slug": "launch"}
+	]}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "998", w.Header().Get("X-Monthly-Link-Remaining"))
+
+	var response BulkShortenResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, BulkShortenModeTransactional, response.Mode)
+	assert.Equal(t, 2, response.Created)
+	assert.Equal(t, 0, response.Failed)
+	assert.Len(t, response.Results, 2)
+	// Results stay in request order
+	assert.Equal(t, "https://example.com/a", response.Results[0].ShortURL.URL)
+	assert.Equal(t, "launch", response.Results[1].ShortURL.Slug)
+	assert.Equal(t, http.StatusCreated, response.Results[1].Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortenHandler_ShortenBulk_TransactionalRejectsInvalidItem(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewShortenHandler(db, &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	})
+
+	// Nothing is charged or created
+	w := performBulkShorten(handler, `{"mode": "transactional", "items": [
+		{"domain": "example.com", "url": "https://example.com/a"},
+		{"domain": "other.com", "url": "https://example.com/b"}
+	]}`)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response BulkShortenResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 0, response.Created)
+	assert.Equal(t, 1, response.Failed)
+	assert.NotEmpty(t, response.Error)
+	assert.Len(t, response.Results, 1)
+	assert.Equal(t, 1, response.Results[0].Index)
+	assert.Contains(t, response.Results[0].Error, "other.com")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortenHandler_ShortenBulk_DuplicateSlugInBatch(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewShortenHandler(db, &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	})
+
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "launch").
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	w := performBulkShorten(handler, `{"items": [
+		{"domain": "example.com", "url": "https://example.com/a", "slug": "launch"},
+		{"domain": "example.com", "url": "https://example.com/b", "slug": "launch"}
+	]}`)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	var response BulkShortenResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Results, 1)
+	assert.Equal(t, 1, response.Results[0].Index)
+	assert.Contains(t, response.Results[0].Error, "item 0")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortenHandler_ShortenBulk_TransactionalOverMonthlyLimit(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewShortenHandler(db, &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	})
+
+	// Only one link is left this month, so neither is charged
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM "monthly_link_limits"`).
+		WithArgs(sqlmock.AnyArg(), "ip", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "identifier", "type", "link_count", "month_start"}).
+			AddRow("limit-1", "192.0.2.1", "ip", 999, time.Now()))
+	mock.ExpectCommit()
+
+	w := performBulkShorten(handler, `{"items": [
+		{"domain": "example.com", "url": "https://example.com/a"},
+		{"domain": "example.com", "url": "https://example.com/b"}
+	]}`)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-Monthly-Link-Remaining"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortenHandler_ShortenBulk_BestEffort(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewShortenHandler(db, &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	})
+
+	// One link is left this month, so only the first valid item is charged
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM "monthly_link_limits"`).
+		WithArgs(sqlmock.AnyArg(), "ip", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "identifier", "type", "link_count", "month_start"}).
+			AddRow("limit-1", "192.0.2.1", "ip", 999, time.Now()))
+	mock.ExpectExec(`UPDATE "monthly_link_limits"`).
+		WithArgs("192.0.2.1", "ip", 1000, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "limit-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "short_urls"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	w := performBulkShorten(handler, `{"mode": "best_effort", "items": [
+		{"domain": "example.com", "url": "https://example.com/a"},
+		{"domain": "example.com", "url": "https://example.com/b", "max_clicks": -1},
+		{"domain": "example.com", "url": "https://example.com/c"}
+	]}`)
+
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var response BulkShortenResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, BulkShortenModeBestEffort, response.Mode)
+	assert.Equal(t, 1, response.Created)
+	assert.Equal(t, 2, response.Failed)
+	assert.Len(t, response.Results, 3)
+	assert.Equal(t, http.StatusCreated, response.Results[0].Status)
+	assert.NotNil(t, response.Results[0].ShortURL)
+	assert.Equal(t, http.StatusBadRequest, response.Results[1].Status)
+	assert.Equal(t, http.StatusTooManyRequests, response.Results[2].Status)
+	assert.Nil(t, response.Results[2].ShortURL)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortenHandler_ShortenBulk_BestEffortReleasesFailedLinks(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewShortenHandler(db, &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM "monthly_link_limits"`).
+		WithArgs(sqlmock.AnyArg(), "ip", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectExec(`INSERT INTO "monthly_link_limits"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "ip", 1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "short_urls"`).
+		WillReturnError(sqlmock.ErrCancelled)
+	mock.ExpectRollback()
+
+	// The link that wasn't created is handed back
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "monthly_link_limits" SET "link_count"=link_count - \$1 WHERE identifier = \$2 AND type = \$3 AND month_start = \$4 AND link_count >= \$5`).
+		WithArgs(1, sqlmock.AnyArg(), "ip", sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := performBulkShorten(handler, `{"mode": "best_effort", "items": [
+		{"domain": "example.com", "url": "https://example.com/a"}
+	]}`)
+
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var response BulkShortenResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 0, response.Created)
+	assert.Equal(t, http.StatusInternalServerError, response.Results[0].Status)
+	assert.Equal(t, "Failed to create short URL", response.Results[0].Error)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortenHandler_ShortenBulk_InvalidRequest(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewShortenHandler(db, &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	})
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"unknown mode", `{"mode": "eventually", "items": [{"domain": "example.com", "url": "https://example.com"}]}`},
+		{"no items", `{"items": []}`},
+		{"not an object", `[{"domain": "example.com", "url": "https://example.com"}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := performBulkShorten(handler, tt.body)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
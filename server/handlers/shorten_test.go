@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -16,6 +18,8 @@ import (
 
 	"openshortpath/server/config"
 	"openshortpath/server/constants"
+	"openshortpath/server/models"
+	"openshortpath/server/utils"
 )
 
 func TestShortenHandler_Shorten_Success(t *testing.T) {
@@ -29,12 +33,6 @@ func TestShortenHandler_Shorten_Success(t *testing.T) {
 
 	handler := NewShortenHandler(db, cfg)
 
-	// Mock database queries
-	// First query: check for existing record (should return no rows)
-	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
-		WithArgs("example.com", sqlmock.AnyArg()).
-		WillReturnError(gorm.ErrRecordNotFound)
-
 	// Mock monthly link limit check for anonymous user (IP-based)
 	// GetClientIP will return RemoteAddr, which we need to mock
 	mock.ExpectBegin()
@@ -46,10 +44,15 @@ func TestShortenHandler_Shorten_Success(t *testing.T) {
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
-	// Second query: insert new record
+	// Generated slugs are checked for collisions after the quota and namespace checks
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	// Insert new record
 	mock.ExpectBegin()
 	mock.ExpectExec(`INSERT INTO "short_urls"`).
-		WithArgs(sqlmock.AnyArg(), "example.com", sqlmock.AnyArg(), "https://example.com/target", "", nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), "example.com", sqlmock.AnyArg(), "https://example.com/target", sqlmock.AnyArg(), "", nil, 0, nil, nil, 0, nil, nil, false, false, false, "", "", "", "", "", "", "", "", "", "", 0, 0, nil, "", 0, "", sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
@@ -90,10 +93,10 @@ func TestShortenHandler_Shorten_WithJWTToken(t *testing.T) {
 	userID := "user123"
 	now := time.Now()
 
-	// Mock database queries
-	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
-		WithArgs("example.com", sqlmock.AnyArg()).
-		WillReturnError(gorm.ErrRecordNotFound)
+	// Mock user query for the deduplication default
+	mock.ExpectQuery(`SELECT "dedup_links" FROM "users"`).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"dedup_links"}).AddRow(false))
 
 	// Mock user query to get plan (for monthly limit check)
 	userRows := sqlmock.NewRows([]string{"user_id", "username", "hashed_password", "active", "plan", "created_at", "updated_at"}).
@@ -112,9 +115,14 @@ func TestShortenHandler_Shorten_WithJWTToken(t *testing.T) {
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
+	// Generated slugs are checked for collisions after the quota and namespace checks
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+
 	mock.ExpectBegin()
 	mock.ExpectExec(`INSERT INTO "short_urls"`).
-		WithArgs(sqlmock.AnyArg(), "example.com", sqlmock.AnyArg(), "https://example.com/target", userID, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), "example.com", sqlmock.AnyArg(), "https://example.com/target", sqlmock.AnyArg(), userID, nil, 0, nil, nil, 0, nil, nil, false, false, false, "", "", "", "", "", "", "", "", "", "", 0, 0, nil, "", 0, "", sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
@@ -169,7 +177,7 @@ func TestShortenHandler_Shorten_WithCustomSlug(t *testing.T) {
 
 	mock.ExpectBegin()
 	mock.ExpectExec(`INSERT INTO "short_urls"`).
-		WithArgs(sqlmock.AnyArg(), "example.com", "custom-slug", "https://example.com/target", "", nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), "example.com", "custom-slug", "https://example.com/target", sqlmock.AnyArg(), "", nil, 0, nil, nil, 0, nil, nil, false, false, false, "", "", "", "", "", "", "", "", "", "", 0, 0, nil, "", 0, "", sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
@@ -312,9 +320,9 @@ func TestShortenHandler_Shorten_DatabaseErrorOnCheck(t *testing.T) {
 
 	handler := NewShortenHandler(db, cfg)
 
-	// Mock database query returning an error
+	// Mock duplicate check for the custom slug returning an error
 	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
-		WithArgs("example.com", sqlmock.AnyArg()).
+		WithArgs("example.com", "my-slug").
 		WillReturnError(sql.ErrConnDone)
 
 	// Setup Gin context
@@ -322,7 +330,7 @@ func TestShortenHandler_Shorten_DatabaseErrorOnCheck(t *testing.T) {
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 
-	reqBody := `{"domain": "example.com", "url": "https://example.com/target"}`
+	reqBody := `{"domain": "example.com", "url": "https://example.com/target", "slug": "my-slug"}`
 	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/shorten", strings.NewReader(reqBody))
 	c.Request.Header.Set("Content-Type", "application/json")
 
@@ -350,11 +358,6 @@ func TestShortenHandler_Shorten_DatabaseErrorOnInsert(t *testing.T) {
 
 	handler := NewShortenHandler(db, cfg)
 
-	// Mock database queries
-	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
-		WithArgs("example.com", sqlmock.AnyArg()).
-		WillReturnError(gorm.ErrRecordNotFound)
-
 	// Mock monthly link limit check for anonymous user (IP-based)
 	mock.ExpectBegin()
 	mock.ExpectQuery(`SELECT (.+) FROM "monthly_link_limits"`).
@@ -365,9 +368,14 @@ func TestShortenHandler_Shorten_DatabaseErrorOnInsert(t *testing.T) {
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
+	// Generated slugs are checked for collisions after the quota and namespace checks
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+
 	mock.ExpectBegin()
 	mock.ExpectExec(`INSERT INTO "short_urls"`).
-		WithArgs(sqlmock.AnyArg(), "example.com", sqlmock.AnyArg(), "https://example.com/target", "", nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), "example.com", sqlmock.AnyArg(), "https://example.com/target", sqlmock.AnyArg(), "", nil, 0, nil, nil, 0, nil, nil, false, false, false, "", "", "", "", "", "", "", "", "", "", 0, 0, nil, "", 0, "", sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnError(sql.ErrConnDone)
 	mock.ExpectRollback()
 
@@ -427,3 +435,599 @@ func TestShortenHandler_Shorten_MissingRequiredFields(t *testing.T) {
 	handler.Shorten(c2)
 	assert.Equal(t, http.StatusBadRequest, w2.Code)
 }
+
+func TestShortenHandler_Shorten_WithRedirectType(t *testing.T) {
+	// Setup
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	cfg := &config.Config{
+		AvailableShortDomains: []string{"example.com", "localhost:3000"},
+	}
+
+	handler := NewShortenHandler(db, cfg)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "temp-link").
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM "monthly_link_limits"`).
+		WithArgs(sqlmock.AnyArg(), "ip", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectExec(`INSERT INTO "monthly_link_limits"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "ip", 1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "short_urls"`).
+		WithArgs(sqlmock.AnyArg(), "example.com", "temp-link", "https://example.com/target", sqlmock.AnyArg(), "", nil, 302, nil, nil, 0, nil, nil, false, false, false, "", "", "", "", "", "", "", "", "", "", 0, 0, nil, "", 0, "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	reqBody := `{"domain": "example.com", "url": "https://example.com/target", "slug": "temp-link", "redirect_type": 302}`
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/shorten", strings.NewReader(reqBody))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	// Execute
+	handler.Shorten(c)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(302), response["redirect_type"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortenHandler_Shorten_InvalidRedirectType(t *testing.T) {
+	// Setup
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	cfg := &config.Config{
+		AvailableShortDomains: []string{"example.com", "localhost:3000"},
+	}
+
+	handler := NewShortenHandler(db, cfg)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	reqBody := `{"domain": "example.com", "url": "https://example.com/target", "redirect_type": 200}`
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/shorten", strings.NewReader(reqBody))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	// Execute
+	handler.Shorten(c)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortenHandler_Shorten_InvalidExpiration(t *testing.T) {
+	tests := []struct {
+		name    string
+		reqBody string
+	}{
+		{"expires_at in the past", `{"domain": "example.com", "url": "https://example.com/target", "expires_at": "2000-01-01T00:00:00Z"}`},
+		{"zero max_clicks", `{"domain": "example.com", "url": "https://example.com/target", "max_clicks": 0}`},
+		{"negative max_clicks", `{"domain": "example.com", "url": "https://example.com/target", "max_clicks": -5}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, sqlDB := setupTestDB(t)
+			defer sqlDB.Close()
+
+			cfg := &config.Config{
+				AvailableShortDomains: []string{"example.com"},
+			}
+
+			handler := NewShortenHandler(db, cfg)
+
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/shorten", strings.NewReader(tt.reqBody))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			handler.Shorten(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestShortenHandler_Shorten_WithPassword(t *testing.T) {
+	// Setup
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	cfg := &config.Config{
+		AvailableShortDomains: []string{"example.com", "localhost:3000"},
+	}
+
+	handler := NewShortenHandler(db, cfg)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "private").
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM "monthly_link_limits"`).
+		WithArgs(sqlmock.AnyArg(), "ip", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectExec(`INSERT INTO "monthly_link_limits"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "ip", 1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// The password is stored as an argon2id hash
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "short_urls"`).
+		WithArgs(sqlmock.AnyArg(), "example.com", "private", "https://example.com/target", sqlmock.AnyArg(), "", nil, 0, nil, nil, 0, argon2idHashArg{}, nil, false, false, false, "", "", "", "", "", "", "", "", "", "", 0, 0, nil, "", 0, "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	reqBody := `{"domain": "example.com", "url": "https://example.com/target", "slug": "private", "password": "hunter2"}`
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/shorten", strings.NewReader(reqBody))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	// Execute
+	handler.Shorten(c)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, true, response["has_password"])
+	assert.NotContains(t, w.Body.String(), "hunter2")
+	assert.NotContains(t, w.Body.String(), "argon2id")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// argon2idHashArg matches a *string argument holding an argon2id hash
+type argon2idHashArg struct{}
+
+func (argon2idHashArg) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	return ok && strings.HasPrefix(s, "$argon2id$")
+}
+
+func TestShortenHandler_Shorten_ExpirationStoredInUTC(t *testing.T) {
+	// Setup
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	cfg := &config.Config{
+		AvailableShortDomains: []string{"example.com", "localhost:3000"},
+	}
+
+	handler := NewShortenHandler(db, cfg)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "promo").
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM "monthly_link_limits"`).
+		WithArgs(sqlmock.AnyArg(), "ip", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectExec(`INSERT INTO "monthly_link_limits"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "ip", 1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// The expiry is stored in UTC whatever offset the client sent
+	expiresAt := time.Date(2099, 1, 1, 7, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "short_urls"`).
+		WithArgs(sqlmock.AnyArg(), "example.com", "promo", "https://example.com/target", sqlmock.AnyArg(), "", nil, 0, utcTimeArg{expiresAt}, nil, 0, nil, nil, false, false, false, "", "", "", "", "", "", "", "", "", "", 0, 0, nil, "", 0, "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	reqBody := `{"domain": "example.com", "url": "https://example.com/target", "slug": "promo", "expires_at": "2099-01-01T12:00:00+05:00"}`
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/shorten", strings.NewReader(reqBody))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	// Execute
+	handler.Shorten(c)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "2099-01-01T07:00:00Z", response["expires_at"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// utcTimeArg matches a time argument equal to want and in UTC
+type utcTimeArg struct {
+	want time.Time
+}
+
+func (a utcTimeArg) Match(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	return ok && t.Location() == time.UTC && t.Equal(a.want)
+}
+
+func TestShortenHandler_Shorten_WithDestinations(t *testing.T) {
+	// Setup
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	cfg := &config.Config{
+		AvailableShortDomains: []string{"example.com", "localhost:3000"},
+	}
+
+	handler := NewShortenHandler(db, cfg)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "ab-test").
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM "monthly_link_limits"`).
+		WithArgs(sqlmock.AnyArg(), "ip", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectExec(`INSERT INTO "monthly_link_limits"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "ip", 1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "short_urls"`).
+		WithArgs(sqlmock.AnyArg(), "example.com", "ab-test", "https://example.com/target", sqlmock.AnyArg(), "", nil, 0, nil, nil, 0, nil,
+			`[{"url":"https://example.com/a","weight":70},{"url":"https://example.com/b","weight":30}]`, true, false, false, "", "", "", "", "", "", "", "", "", "", 0, 0, nil, "", 0, "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	reqBody := `{"domain": "example.com", "url": "https://example.com/target", "slug": "ab-test", "sticky_destinations": true,
+		"destinations": [{"url": "https://example.com/a", "weight": 70}, {"url": "https://example.com/b", "weight": 30}]}`
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/shorten", strings.NewReader(reqBody))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	// Execute
+	handler.Shorten(c)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response models.ShortURL
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Len(t, response.Destinations, 2)
+	assert.True(t, response.StickyDestinations)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortenHandler_Shorten_InvalidDestinations(t *testing.T) {
+	tests := []struct {
+		name    string
+		reqBody string
+	}{
+		{"single destination", `{"domain": "example.com", "url": "https://example.com/target", "destinations": [{"url": "https://example.com/a", "weight": 1}]}`},
+		{"zero weight", `{"domain": "example.com", "url": "https://example.com/target", "destinations": [{"url": "https://example.com/a", "weight": 1}, {"url": "https://example.com/b", "weight": 0}]}`},
+		{"negative weight", `{"domain": "example.com", "url": "https://example.com/target", "destinations": [{"url": "https://example.com/a", "weight": -1}, {"url": "https://example.com/b", "weight": 1}]}`},
+		{"missing url", `{"domain": "example.com", "url": "https://example.com/target", "destinations": [{"url": "https://example.com/a", "weight": 1}, {"weight": 1}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, sqlDB := setupTestDB(t)
+			defer sqlDB.Close()
+
+			cfg := &config.Config{
+				AvailableShortDomains: []string{"example.com"},
+			}
+
+			handler := NewShortenHandler(db, cfg)
+
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/shorten", strings.NewReader(tt.reqBody))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			handler.Shorten(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestShortenHandler_Shorten_GeneratedSlugCollision(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	cfg := &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+		SlugGeneration:        config.SlugGeneration{Length: 8, Alphabet: "ab"},
+	}
+	handler := NewShortenHandler(db, cfg)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM "monthly_link_limits"`).
+		WithArgs(sqlmock.AnyArg(), "ip", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectExec(`INSERT INTO "monthly_link_limits"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "ip", 1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// The first generated slug is taken, so another one is tried instead of returning 409
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug"}).AddRow("existing", "example.com", "abababab"))
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "short_urls"`).
+		WithArgs(sqlmock.AnyArg(), "example.com", sqlmock.AnyArg(), "https://example.com/target", sqlmock.AnyArg(), "", nil, 0, nil, nil, 0, nil, nil, false, false, false, "", "", "", "", "", "", "", "", "", "", 0, 0, nil, "", 0, "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	reqBody := `{"domain": "example.com", "url": "https://example.com/target"}`
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/shorten", strings.NewReader(reqBody))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	handler.Shorten(c)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Regexp(t, `^[ab]{8}$`, response["slug"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortenHandler_Shorten_GeneratedSlugInsertRace(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	cfg := &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	}
+	handler := NewShortenHandler(db, cfg)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM "monthly_link_limits"`).
+		WithArgs(sqlmock.AnyArg(), "ip", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectExec(`INSERT INTO "monthly_link_limits"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "ip", 1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// Another request takes the generated slug between the check and the insert
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "short_urls"`).
+		WillReturnError(errors.New(`duplicate key value violates unique constraint "idx_domain_slug"`))
+	mock.ExpectRollback()
+
+	// A new slug is generated and stored instead of returning 500
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "short_urls"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	reqBody := `{"domain": "example.com", "url": "https://example.com/target"}`
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/shorten", strings.NewReader(reqBody))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	handler.Shorten(c)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func performShortenAs(handler *ShortenHandler, userID, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, userID)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/shorten", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	handler.Shorten(c)
+	return w
+}
+
+func TestShortenHandler_Shorten_DedupReturnsExistingLink(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewShortenHandler(db, &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	})
+	userID := "user123"
+	past := time.Now().Add(-time.Hour)
+
+	// The request asks for deduplication, so the user's default isn't looked up.
+	// The expired link to the same destination is passed over for the live one
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls" WHERE \(user_id = \$1 AND url_hash = \$2 AND domain = \$3\) AND namespace_id IS NULL ORDER BY created_at ASC`).
+		WithArgs(userID, utils.URLHash("https://example.com/target"), "example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "expires_at"}).
+			AddRow("url-1", "example.com", "old", "https://example.com/target", userID, past).
+			AddRow("url-2", "example.com", "abc12", "https://EXAMPLE.com:443/target", userID, nil))
+	mock.ExpectQuery(`SELECT short_url_tags.short_url_id, tags.name FROM "short_url_tags"`).
+		WithArgs("url-2").
+		WillReturnRows(sqlmock.NewRows([]string{"short_url_id", "name"}).AddRow("url-2", "launch"))
+
+	w := performShortenAs(handler, userID, `{"domain": "example.com", "url": "https://example.com/target", "dedup": true}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response models.ShortURL
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "url-2", response.ID)
+	assert.Equal(t, "abc12", response.Slug)
+	assert.Equal(t, []string{"launch"}, response.Tags)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortenHandler_Shorten_DedupUserDefault(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewShortenHandler(db, &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	})
+	userID := "user123"
+	namespaceID := "ns-1"
+
+	// The user deduplicates by default, but has no link to this destination in the namespace yet
+	mock.ExpectQuery(`SELECT "dedup_links" FROM "users"`).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"dedup_links"}).AddRow(true))
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls" WHERE \(user_id = \$1 AND url_hash = \$2 AND domain = \$3\) AND namespace_id = \$4`).
+		WithArgs(userID, utils.URLHash("https://example.com/target"), "example.com", namespaceID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	// So a new link is created as usual
+	mock.ExpectQuery(`SELECT (.+) FROM "users"`).
+		WithArgs(userID).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM "monthly_link_limits"`).
+		WithArgs(userID, "user", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectExec(`INSERT INTO "monthly_link_limits"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT (.+) FROM "namespaces"`).
+		WithArgs(namespaceID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "domain", "user_id"}).AddRow(namespaceID, "team", "example.com", userID))
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "short_urls"`).
+		WithArgs(sqlmock.AnyArg(), "example.com", sqlmock.AnyArg(), "https://example.com/target", utils.URLHash("https://example.com/target"), userID, namespaceID, 0, nil, nil, 0, nil, nil, false, false, false, "", "", "", "", "", "", "", "", "", "", 0, 0, nil, "", 0, "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	w := performShortenAs(handler, userID, `{"domain": "example.com", "url": "https://example.com/target", "namespace_id": "ns-1"}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortenHandler_Shorten_DedupBypassedByExplicitSlug(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewShortenHandler(db, &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	})
+	userID := "user123"
+
+	// Only the chosen slug is checked; no existing link is looked for
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "launch").
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectQuery(`SELECT (.+) FROM "users"`).
+		WithArgs(userID).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM "monthly_link_limits"`).
+		WithArgs(userID, "user", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectExec(`INSERT INTO "monthly_link_limits"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "short_urls"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	w := performShortenAs(handler, userID, `{"domain": "example.com", "url": "https://example.com/target", "slug": "launch", "dedup": true}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortenHandler_Shorten_DedupBypassedByLinkOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"password", `{"domain": "example.com", "url": "https://example.com/target", "password": "secret", "dedup": true}`},
+		{"max clicks", `{"domain": "example.com", "url": "https://example.com/target", "max_clicks": 5, "dedup": true}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, sqlDB := setupTestDB(t)
+			defer sqlDB.Close()
+
+			handler := NewShortenHandler(db, &config.Config{
+				AvailableShortDomains: []string{"example.com"},
+			})
+			userID := "user123"
+
+			// No existing link is looked for, so a plain link to the same destination is never reused
+			mock.ExpectQuery(`SELECT (.+) FROM "users"`).
+				WithArgs(userID).
+				WillReturnError(gorm.ErrRecordNotFound)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT (.+) FROM "monthly_link_limits"`).
+				WithArgs(userID, "user", sqlmock.AnyArg()).
+				WillReturnError(gorm.ErrRecordNotFound)
+			mock.ExpectExec(`INSERT INTO "monthly_link_limits"`).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectCommit()
+			mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+				WithArgs("example.com", sqlmock.AnyArg()).
+				WillReturnError(gorm.ErrRecordNotFound)
+			mock.ExpectBegin()
+			mock.ExpectExec(`INSERT INTO "short_urls"`).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectCommit()
+
+			w := performShortenAs(handler, userID, tt.body)
+
+			assert.Equal(t, http.StatusCreated, w.Code)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
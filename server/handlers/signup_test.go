@@ -43,7 +43,7 @@ func TestSignupHandler_Signup_Success(t *testing.T) {
 	// Second query: insert new user
 	mock.ExpectBegin()
 	mock.ExpectExec(`INSERT INTO "users"`).
-		WithArgs(sqlmock.AnyArg(), "newuser", sqlmock.AnyArg(), true, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), "newuser", sqlmock.AnyArg(), true, sqlmock.AnyArg(), false, sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
@@ -245,7 +245,7 @@ func TestSignupHandler_Signup_DatabaseError_CreateUser(t *testing.T) {
 	// Second query: insert new user fails
 	mock.ExpectBegin()
 	mock.ExpectExec(`INSERT INTO "users"`).
-		WithArgs(sqlmock.AnyArg(), "newuser", sqlmock.AnyArg(), true, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), "newuser", sqlmock.AnyArg(), true, sqlmock.AnyArg(), false, sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnError(sql.ErrConnDone)
 	mock.ExpectRollback()
 
@@ -311,7 +311,7 @@ func TestSignupHandler_Signup_RS256(t *testing.T) {
 	// Second query: insert new user
 	mock.ExpectBegin()
 	mock.ExpectExec(`INSERT INTO "users"`).
-		WithArgs(sqlmock.AnyArg(), "newuser", sqlmock.AnyArg(), true, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), "newuser", sqlmock.AnyArg(), true, sqlmock.AnyArg(), false, sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
@@ -357,7 +357,7 @@ func TestSignupHandler_Signup_UserCreatedWithActiveTrue(t *testing.T) {
 	// Verify that user is created with Active: true
 	mock.ExpectBegin()
 	mock.ExpectExec(`INSERT INTO "users"`).
-		WithArgs(sqlmock.AnyArg(), "newuser", sqlmock.AnyArg(), true, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), "newuser", sqlmock.AnyArg(), true, sqlmock.AnyArg(), false, sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
@@ -398,7 +398,7 @@ func TestSignupHandler_Signup_UserIDIsUUID(t *testing.T) {
 	// Mock user creation - the user ID will be a UUID generated by uuid.New()
 	mock.ExpectBegin()
 	mock.ExpectExec(`INSERT INTO "users"`).
-		WithArgs(sqlmock.AnyArg(), "newuser", sqlmock.AnyArg(), true, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), "newuser", sqlmock.AnyArg(), true, sqlmock.AnyArg(), false, sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
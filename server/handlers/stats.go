@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"openshortpath/server/constants"
+	"openshortpath/server/models"
+	"openshortpath/server/services"
+)
+
+// Maximum time range that can be requested per bucket interval
+const (
+	maxHourlyStatsRange = 31 * 24 * time.Hour
+	maxDailyStatsRange  = 366 * 24 * time.Hour
+)
+
+type StatsHandler struct {
+	db *gorm.DB
+}
+
+func NewStatsHandler(db *gorm.DB) *StatsHandler {
+	return &StatsHandler{
+		db: db,
+	}
+}
+
+// parseStatsTime parses a stats range bound in RFC3339 or YYYY-MM-DD format
+func parseStatsTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("must be RFC3339 or YYYY-MM-DD")
+	}
+	return t.UTC(), nil
+}
+
+// GetStats handles GET /api/v1/short-urls/:id/stats
+// Query parameters: interval ("hour" or "day", default "day"), from and to (RFC3339 or YYYY-MM-DD)
+func (h *StatsHandler) GetStats(c *gin.Context) {
+	// Get user ID from context
+	userIDValue, exists := c.Get(constants.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User ID not found in context",
+		})
+		return
+	}
+
+	userID, ok := userIDValue.(string)
+	if !ok || userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid user ID in context",
+		})
+		return
+	}
+
+	// Get ID from URL parameter
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "ID parameter is required",
+		})
+		return
+	}
+
+	// Parse bucket interval
+	interval := c.DefaultQuery("interval", services.ClickIntervalDay)
+	if interval != services.ClickIntervalHour && interval != services.ClickIntervalDay {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid interval (must be 'hour' or 'day')",
+		})
+		return
+	}
+
+	// Parse time range, defaulting to the last 24 hours or 30 days depending on interval
+	to := time.Now().UTC()
+	if toStr := c.Query("to"); toStr != "" {
+		parsed, err := parseStatsTime(toStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid 'to' parameter",
+				"details": err.Error(),
+			})
+			return
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, 0, -30)
+	if interval == services.ClickIntervalHour {
+		from = to.Add(-24 * time.Hour)
+	}
+	if fromStr := c.Query("from"); fromStr != "" {
+		parsed, err := parseStatsTime(fromStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid 'from' parameter",
+				"details": err.Error(),
+			})
+			return
+		}
+		from = parsed
+	}
+
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "'from' must be before 'to'",
+		})
+		return
+	}
+
+	maxRange := maxDailyStatsRange
+	if interval == services.ClickIntervalHour {
+		maxRange = maxHourlyStatsRange
+	}
+	if to.Sub(from) > maxRange {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Time range too large for interval '%s' (maximum %d days)", interval, int(maxRange.Hours()/24)),
+		})
+		return
+	}
+
+	// Verify the short URL belongs to the user
+	var shortURL models.ShortURL
+	result := h.db.Where("id = ? AND user_id = ?", id, userID).First(&shortURL)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Short URL not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"details": result.Error.Error(),
+		})
+		return
+	}
+
+	stats, err := services.GetClickStats(h.db, shortURL.ID, interval, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get click stats",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"openshortpath/server/constants"
+	"openshortpath/server/services"
+)
+
+func TestStatsHandler_GetStats_Success(t *testing.T) {
+	// Setup
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewStatsHandler(db)
+
+	userID := "user123"
+	id := uuid.New().String()
+	now := time.Now()
+
+	// Ownership check
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs(id, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id", "created_at", "updated_at"}).
+			AddRow(id, "example.com", "abc123", "https://example.com/target", userID, now, now))
+
+	// Total count
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "click_events"`).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	// Clicks in range
+	mock.ExpectQuery(`SELECT (.+) AS bucket, COUNT\(\*\) AS count FROM "click_events"`).
+		WithArgs(id, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"bucket", "count"}).
+			AddRow("2024-03-01 00:00:00", 1).
+			AddRow("2024-03-02 00:00:00", 1))
+
+	// Setup Gin context with user_id
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, userID)
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/short-urls/"+id+"/stats?from=2024-03-01&to=2024-03-03", nil)
+
+	// Execute
+	handler.GetStats(c)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response services.ClickStats
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, id, response.ShortURLID)
+	assert.Equal(t, int64(3), response.TotalClicks)
+	assert.Equal(t, "day", response.Interval)
+	assert.Len(t, response.Buckets, 2)
+	assert.Equal(t, int64(1), response.Buckets[0].Count)
+	assert.Equal(t, int64(1), response.Buckets[1].Count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatsHandler_GetStats_NotFound(t *testing.T) {
+	// Setup
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewStatsHandler(db)
+
+	userID := "user123"
+	id := uuid.New().String()
+
+	// Short URL belongs to someone else (or doesn't exist)
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs(id, userID).
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, userID)
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/short-urls/"+id+"/stats", nil)
+
+	// Execute
+	handler.GetStats(c)
+
+	// Assert
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatsHandler_GetStats_InvalidParams(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"invalid interval", "?interval=week"},
+		{"invalid from", "?from=yesterday"},
+		{"from after to", "?from=2024-03-05&to=2024-03-01"},
+		{"hourly range too large", "?interval=hour&from=2024-01-01&to=2024-03-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, sqlDB := setupTestDB(t)
+			defer sqlDB.Close()
+
+			handler := NewStatsHandler(db)
+
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Set(constants.ContextKeyUserID, "user123")
+			c.Params = gin.Params{{Key: "id", Value: "some-id"}}
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/short-urls/some-id/stats"+tt.query, nil)
+
+			handler.GetStats(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestStatsHandler_GetStats_NoUserID(t *testing.T) {
+	db, _, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewStatsHandler(db)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "id", Value: "some-id"}}
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/short-urls/some-id/stats", nil)
+
+	handler.GetStats(c)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
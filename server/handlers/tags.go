@@ -0,0 +1,471 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"openshortpath/server/constants"
+	"openshortpath/server/models"
+)
+
+const (
+	// maxTagNameLength is the maximum length of a tag name in characters
+	maxTagNameLength = 64
+	// maxTagsPerLink is the maximum number of tags on one short URL
+	maxTagsPerLink = 20
+)
+
+// How GET /api/v1/short-urls combines several tags given in ?tags=
+const (
+	TagModeAll = "all" // Links with every one of the tags
+	TagModeAny = "any" // Links with at least one of the tags
+)
+
+type TagsHandler struct {
+	db *gorm.DB
+}
+
+type TagRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// TagWithCount is a tag with the number of short URLs it is on
+type TagWithCount struct {
+	models.Tag
+	LinkCount int64 `json:"link_count"`
+}
+
+type ListTagsResponse struct {
+	Tags []TagWithCount `json:"tags"`
+}
+
+func NewTagsHandler(db *gorm.DB) *TagsHandler {
+	return &TagsHandler{
+		db: db,
+	}
+}
+
+// normalizeTagName trims a tag name and checks it can be stored and used in a ?tags= filter
+func normalizeTagName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("tag names must not be empty")
+	}
+	if utf8.RuneCountInString(name) > maxTagNameLength {
+		return "", fmt.Errorf("tag '%s' is longer than %d characters", name, maxTagNameLength)
+	}
+	if strings.Contains(name, ",") {
+		return "", fmt.Errorf("tag '%s' must not contain commas", name)
+	}
+	return name, nil
+}
+
+// normalizeTagNames normalizes a list of tag names, dropping duplicates and sorting them by name
+func normalizeTagNames(names []string) ([]string, error) {
+	seen := make(map[string]bool, len(names))
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		name, err := normalizeTagName(name)
+		if err != nil {
+			return nil, err
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		normalized = append(normalized, name)
+	}
+	if len(normalized) > maxTagsPerLink {
+		return nil, fmt.Errorf("a short URL can't have more than %d tags", maxTagsPerLink)
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// requireTagOwner rejects tags on anonymous links, which have no user to own the tags
+func requireTagOwner(tags []string, userID string) *apiFailure {
+	if len(tags) > 0 && userID == "" {
+		return &apiFailure{http.StatusUnauthorized, gin.H{
+			"error": "Authentication required to use tags",
+		}}
+	}
+	return nil
+}
+
+// resolveTags loads the user's tags with the given names, creating the ones that don't exist yet
+func resolveTags(db *gorm.DB, userID string, names []string) ([]models.Tag, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	var tags []models.Tag
+	if err := db.Where("user_id = ? AND name IN ?", userID, names).Find(&tags).Error; err != nil {
+		return nil, fmt.Errorf("failed to load tags: %w", err)
+	}
+
+	existing := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		existing[tag.Name] = true
+	}
+	for _, name := range names {
+		if existing[name] {
+			continue
+		}
+		tag := models.Tag{UserID: userID, Name: name}
+		if err := db.Create(&tag).Error; err != nil {
+			return nil, fmt.Errorf("failed to create tag '%s': %w", name, err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// setShortURLTags replaces the tags on a short URL
+func setShortURLTags(db *gorm.DB, shortURLID string, tags []models.Tag) error {
+	if err := db.Where("short_url_id = ?", shortURLID).Delete(&models.ShortURLTag{}).Error; err != nil {
+		return fmt.Errorf("failed to remove tags: %w", err)
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	links := make([]models.ShortURLTag, len(tags))
+	for i, tag := range tags {
+		links[i] = models.ShortURLTag{ShortURLID: shortURLID, TagID: tag.ID}
+	}
+	if err := db.Create(&links).Error; err != nil {
+		return fmt.Errorf("failed to add tags: %w", err)
+	}
+	return nil
+}
+
+// tagShortURL replaces the tags on a short URL with the named tags of its owner
+func tagShortURL(db *gorm.DB, shortURL *models.ShortURL, names []string) error {
+	tags, err := resolveTags(db, shortURL.UserID, names)
+	if err != nil {
+		return err
+	}
+	if err := setShortURLTags(db, shortURL.ID, tags); err != nil {
+		return err
+	}
+	shortURL.Tags = names
+	return nil
+}
+
+// loadShortURLTags sets the Tags of each short URL, sorted by name
+func loadShortURLTags(db *gorm.DB, urls []models.ShortURL) error {
+	if len(urls) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(urls))
+	positions := make(map[string]int, len(urls))
+	for i := range urls {
+		ids[i] = urls[i].ID
+		positions[urls[i].ID] = i
+		urls[i].Tags = nil
+	}
+
+	var rows []struct {
+		ShortURLID string
+		Name       string
+	}
+	if err := db.Table("short_url_tags").
+		Select("short_url_tags.short_url_id, tags.name").
+		Joins("JOIN tags ON tags.id = short_url_tags.tag_id").
+		Where("short_url_tags.short_url_id IN ?", ids).
+		Order("tags.name ASC").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if i, ok := positions[row.ShortURLID]; ok {
+			urls[i].Tags = append(urls[i].Tags, row.Name)
+		}
+	}
+	return nil
+}
+
+// filterByTags narrows a short URL query to the user's links with all or any of the named tags
+// Returns false if mode is not TagModeAll, TagModeAny or empty (which means TagModeAll)
+func filterByTags(db, query *gorm.DB, userID string, names []string, mode string) (*gorm.DB, bool) {
+	tagged := db.Table("short_url_tags").
+		Select("short_url_tags.short_url_id").
+		Joins("JOIN tags ON tags.id = short_url_tags.tag_id").
+		Where("tags.user_id = ? AND tags.name IN ?", userID, names)
+
+	switch mode {
+	case "", TagModeAll:
+		// Names are unique, so a link has all of them if it matches as many tags as there are names
+		tagged = tagged.Group("short_url_tags.short_url_id").Having("COUNT(*) = ?", len(names))
+	case TagModeAny:
+	default:
+		return query, false
+	}
+	return query.Where("id IN (?)", tagged), true
+}
+
+// ListTags handles GET /api/v1/tags
+// Returns all of the user's tags sorted by name, each with the number of links it is on
+func (h *TagsHandler) ListTags(c *gin.Context) {
+	// Get user ID from context (set by RequireAuth middleware)
+	userIDValue, exists := c.Get(constants.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User ID not found in context",
+		})
+		return
+	}
+
+	userID, ok := userIDValue.(string)
+	if !ok || userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid user ID in context",
+		})
+		return
+	}
+
+	tags := []TagWithCount{}
+	if err := h.db.Model(&models.Tag{}).
+		Select("tags.*, COUNT(short_url_tags.short_url_id) AS link_count").
+		Joins("LEFT JOIN short_url_tags ON short_url_tags.tag_id = tags.id").
+		Where("tags.user_id = ?", userID).
+		Group("tags.id").
+		Order("tags.name ASC").
+		Scan(&tags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ListTagsResponse{Tags: tags})
+}
+
+// CreateTag handles POST /api/v1/tags
+func (h *TagsHandler) CreateTag(c *gin.Context) {
+	// Get user ID from context (set by RequireAuth middleware)
+	userIDValue, exists := c.Get(constants.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User ID not found in context",
+		})
+		return
+	}
+
+	userID, ok := userIDValue.(string)
+	if !ok || userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid user ID in context",
+		})
+		return
+	}
+
+	// Parse request body
+	var req TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	name, err := normalizeTagName(req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Check for an existing tag with the same name
+	if failure := h.checkTagNameAvailable(userID, name, ""); failure != nil {
+		c.JSON(failure.status, failure.body)
+		return
+	}
+
+	tag := models.Tag{
+		UserID: userID,
+		Name:   name,
+	}
+	if err := h.db.Create(&tag).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to create tag",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, TagWithCount{Tag: tag})
+}
+
+// RenameTag handles PUT /api/v1/tags/:id
+// The new name shows up on every link the tag is on
+func (h *TagsHandler) RenameTag(c *gin.Context) {
+	// Get user ID from context (set by RequireAuth middleware)
+	userIDValue, exists := c.Get(constants.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User ID not found in context",
+		})
+		return
+	}
+
+	userID, ok := userIDValue.(string)
+	if !ok || userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid user ID in context",
+		})
+		return
+	}
+
+	tag, failure := h.userTag(userID, c.Param("id"))
+	if failure != nil {
+		c.JSON(failure.status, failure.body)
+		return
+	}
+
+	// Parse request body
+	var req TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	name, err := normalizeTagName(req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if name != tag.Name {
+		if failure := h.checkTagNameAvailable(userID, name, tag.ID); failure != nil {
+			c.JSON(failure.status, failure.body)
+			return
+		}
+		if err := h.db.Model(tag).Update("name", name).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to rename tag",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	// Count the links the tag is on for the response
+	response := TagWithCount{Tag: *tag}
+	if err := h.db.Model(&models.ShortURLTag{}).Where("tag_id = ?", tag.ID).Count(&response.LinkCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteTag handles DELETE /api/v1/tags/:id
+// The tag is removed from all links; the links themselves are kept
+func (h *TagsHandler) DeleteTag(c *gin.Context) {
+	// Get user ID from context (set by RequireAuth middleware)
+	userIDValue, exists := c.Get(constants.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "User ID not found in context",
+		})
+		return
+	}
+
+	userID, ok := userIDValue.(string)
+	if !ok || userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid user ID in context",
+		})
+		return
+	}
+
+	tag, failure := h.userTag(userID, c.Param("id"))
+	if failure != nil {
+		c.JSON(failure.status, failure.body)
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tag_id = ?", tag.ID).Delete(&models.ShortURLTag{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(tag).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to delete tag",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+// userTag loads a tag by ID and checks the user owns it
+func (h *TagsHandler) userTag(userID, id string) (*models.Tag, *apiFailure) {
+	if id == "" {
+		return nil, &apiFailure{http.StatusBadRequest, gin.H{
+			"error": "ID parameter is required",
+		}}
+	}
+
+	tag := &models.Tag{}
+	result := h.db.Where("id = ? AND user_id = ?", id, userID).First(tag)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, &apiFailure{http.StatusNotFound, gin.H{
+				"error": "Tag not found",
+			}}
+		}
+		return nil, &apiFailure{http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"details": result.Error.Error(),
+		}}
+	}
+	return tag, nil
+}
+
+// checkTagNameAvailable checks that none of the user's other tags is called name
+// exceptID excludes the tag being renamed
+func (h *TagsHandler) checkTagNameAvailable(userID, name, exceptID string) *apiFailure {
+	query := h.db.Where("user_id = ? AND name = ?", userID, name)
+	if exceptID != "" {
+		query = query.Where("id != ?", exceptID)
+	}
+
+	var existing models.Tag
+	result := query.First(&existing)
+	if result.Error == nil {
+		return &apiFailure{http.StatusConflict, gin.H{
+			"error": fmt.Sprintf("Tag '%s' already exists", name),
+		}}
+	}
+	if result.Error != gorm.ErrRecordNotFound {
+		return &apiFailure{http.StatusInternalServerError, gin.H{
+			"error":   "Database error",
+			"details": result.Error.Error(),
+		}}
+	}
+	return nil
+}
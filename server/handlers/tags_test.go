@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"openshortpath/server/config"
+	"openshortpath/server/constants"
+)
+
+func newTagsTestContext(method, path, userID string, body []byte) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(constants.ContextKeyUserID, userID)
+	c.Request = httptest.NewRequest(method, path, bytes.NewBuffer(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	return c, w
+}
+
+func TestTagsHandler_ListTags_WithCounts(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewTagsHandler(db)
+	userID := "user123"
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT tags.\*, COUNT\(short_url_tags.short_url_id\) AS link_count FROM "tags" LEFT JOIN short_url_tags ON short_url_tags.tag_id = tags.id WHERE tags.user_id = \$1 GROUP BY "tags"."id" ORDER BY tags.name ASC`).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "created_at", "updated_at", "link_count"}).
+			AddRow("tag-1", userID, "campaign", now, now, 12).
+			AddRow("tag-2", userID, "unused", now, now, 0))
+
+	c, w := newTagsTestContext(http.MethodGet, "/api/v1/tags", userID, nil)
+	handler.ListTags(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response ListTagsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Tags, 2)
+	assert.Equal(t, "campaign", response.Tags[0].Name)
+	assert.Equal(t, int64(12), response.Tags[0].LinkCount)
+	assert.Equal(t, int64(0), response.Tags[1].LinkCount)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTagsHandler_CreateTag_Success(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewTagsHandler(db)
+	userID := "user123"
+
+	mock.ExpectQuery(`SELECT (.+) FROM "tags" WHERE user_id = \$1 AND name = \$2`).
+		WithArgs(userID, "campaign").
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "tags"`).
+		WithArgs(sqlmock.AnyArg(), userID, "campaign", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	c, w := newTagsTestContext(http.MethodPost, "/api/v1/tags", userID, []byte(`{"name": "  campaign "}`))
+	handler.CreateTag(c)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response TagWithCount
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.NotEmpty(t, response.ID)
+	assert.Equal(t, "campaign", response.Name)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTagsHandler_CreateTag_Conflict(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewTagsHandler(db)
+	userID := "user123"
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT (.+) FROM "tags" WHERE user_id = \$1 AND name = \$2`).
+		WithArgs(userID, "campaign").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "created_at", "updated_at"}).
+			AddRow("tag-1", userID, "campaign", now, now))
+
+	c, w := newTagsTestContext(http.MethodPost, "/api/v1/tags", userID, []byte(`{"name": "campaign"}`))
+	handler.CreateTag(c)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTagsHandler_CreateTag_InvalidName(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewTagsHandler(db)
+
+	for _, name := range []string{"   ", "a,b", strings.Repeat("x", maxTagNameLength+1)} {
+		body, _ := json.Marshal(TagRequest{Name: name})
+		c, w := newTagsTestContext(http.MethodPost, "/api/v1/tags", "user123", body)
+		handler.CreateTag(c)
+		assert.Equal(t, http.StatusBadRequest, w.Code, name)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTagsHandler_RenameTag_Success(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewTagsHandler(db)
+	userID := "user123"
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT (.+) FROM "tags" WHERE id = \$1 AND user_id = \$2`).
+		WithArgs("tag-1", userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "created_at", "updated_at"}).
+			AddRow("tag-1", userID, "campaign", now, now))
+	mock.ExpectQuery(`SELECT (.+) FROM "tags" WHERE \(user_id = \$1 AND name = \$2\) AND id != \$3`).
+		WithArgs(userID, "spring-campaign", "tag-1").
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "tags" SET "name"=\$1,"updated_at"=\$2 WHERE "id" = \$3`).
+		WithArgs("spring-campaign", sqlmock.AnyArg(), "tag-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "short_url_tags" WHERE tag_id = \$1`).
+		WithArgs("tag-1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	c, w := newTagsTestContext(http.MethodPut, "/api/v1/tags/tag-1", userID, []byte(`{"name": "spring-campaign"}`))
+	c.Params = gin.Params{{Key: "id", Value: "tag-1"}}
+	handler.RenameTag(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response TagWithCount
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "spring-campaign", response.Name)
+	assert.Equal(t, int64(3), response.LinkCount)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTagsHandler_RenameTag_NotFound(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewTagsHandler(db)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "tags" WHERE id = \$1 AND user_id = \$2`).
+		WithArgs("tag-1", "user123").
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	c, w := newTagsTestContext(http.MethodPut, "/api/v1/tags/tag-1", "user123", []byte(`{"name": "other"}`))
+	c.Params = gin.Params{{Key: "id", Value: "tag-1"}}
+	handler.RenameTag(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTagsHandler_DeleteTag_Success(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewTagsHandler(db)
+	userID := "user123"
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT (.+) FROM "tags" WHERE id = \$1 AND user_id = \$2`).
+		WithArgs("tag-1", userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "created_at", "updated_at"}).
+			AddRow("tag-1", userID, "campaign", now, now))
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "short_url_tags" WHERE tag_id = \$1`).
+		WithArgs("tag-1").
+		WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectExec(`DELETE FROM "tags" WHERE "tags"."id" = \$1`).
+		WithArgs("tag-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	c, w := newTagsTestContext(http.MethodDelete, "/api/v1/tags/tag-1", userID, nil)
+	c.Params = gin.Params{{Key: "id", Value: "tag-1"}}
+	handler.DeleteTag(c)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortURLsHandler_List_TagFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		pattern string
+		args    []driver.Value
+	}{
+		{
+			name:    "all tags",
+			query:   "tags=spring,campaign",
+			pattern: `WHERE user_id = \$1 AND id IN \(SELECT short_url_tags.short_url_id FROM "short_url_tags" JOIN tags ON tags.id = short_url_tags.tag_id WHERE tags.user_id = \$2 AND tags.name IN \(\$3,\$4\) GROUP BY "short_url_tags"."short_url_id" HAVING COUNT\(\*\) = \$5\)`,
+			args:    []driver.Value{"user123", "user123", "campaign", "spring", int64(2)},
+		},
+		{
+			name:    "any tag",
+			query:   "tags=spring,campaign&tag_mode=any",
+			pattern: `WHERE user_id = \$1 AND id IN \(SELECT short_url_tags.short_url_id FROM "short_url_tags" JOIN tags ON tags.id = short_url_tags.tag_id WHERE tags.user_id = \$2 AND tags.name IN \(\$3,\$4\)\)`,
+			args:    []driver.Value{"user123", "user123", "campaign", "spring"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, sqlDB := setupTestDB(t)
+			defer sqlDB.Close()
+
+			handler := NewShortURLsHandler(db, &config.Config{})
+			mock.ExpectQuery(`SELECT count\(\*\) FROM "short_urls" ` + tt.pattern).
+				WithArgs(tt.args...).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			mock.ExpectQuery(`SELECT \* FROM "short_urls" ` + tt.pattern).
+				WithArgs(tt.args...).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id"}).
+					AddRow("url-1", "example.com", "abc123", "https://example.com", "user123"))
+			mock.ExpectQuery(`SELECT short_url_tags.short_url_id, tags.name FROM "short_url_tags" JOIN tags ON tags.id = short_url_tags.tag_id WHERE short_url_tags.short_url_id IN \(\$1\) ORDER BY tags.name ASC`).
+				WithArgs("url-1").
+				WillReturnRows(sqlmock.NewRows([]string{"short_url_id", "name"}).
+					AddRow("url-1", "campaign").
+					AddRow("url-1", "spring"))
+
+			c, w := newTagsTestContext(http.MethodGet, "/api/v1/short-urls?"+tt.query, "user123", nil)
+			handler.List(c)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			var response ListResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Len(t, response.URLs, 1)
+			assert.Equal(t, []string{"campaign", "spring"}, response.URLs[0].Tags)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestShortURLsHandler_List_InvalidTagFilter(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewShortURLsHandler(db, &config.Config{})
+
+	for _, query := range []string{"tags=a,,b", "tags=a&tag_mode=both"} {
+		c, w := newTagsTestContext(http.MethodGet, "/api/v1/short-urls?"+query, "user123", nil)
+		handler.List(c)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortURLsHandler_Update_ReplacesTags(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewShortURLsHandler(db, &config.Config{})
+	userID := "user123"
+	now := time.Now()
+
+	expectOwnedShortURL(mock, "url-1", userID)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM "tags" WHERE user_id = \$1 AND name IN \(\$2,\$3\)`).
+		WithArgs(userID, "campaign", "spring").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "created_at", "updated_at"}).
+			AddRow("tag-1", userID, "campaign", now, now))
+	mock.ExpectExec(`INSERT INTO "tags"`).
+		WithArgs(sqlmock.AnyArg(), userID, "spring", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`DELETE FROM "short_url_tags" WHERE short_url_id = \$1`).
+		WithArgs("url-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO "short_url_tags" \("short_url_id","tag_id","created_at"\) VALUES \(\$1,\$2,\$3\),\(\$4,\$5,\$6\)`).
+		WithArgs("url-1", "tag-1", sqlmock.AnyArg(), "url-1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 2))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls" WHERE id = \$1`).
+		WithArgs("url-1", "url-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "user_id"}).
+			AddRow("url-1", "example.com", "abc123", "https://example.com/target", userID))
+	mock.ExpectQuery(`SELECT short_url_tags.short_url_id, tags.name FROM "short_url_tags"`).
+		WithArgs("url-1").
+		WillReturnRows(sqlmock.NewRows([]string{"short_url_id", "name"}).
+			AddRow("url-1", "campaign").
+			AddRow("url-1", "spring"))
+
+	c, w := newTagsTestContext(http.MethodPut, "/api/v1/short-urls/url-1", userID, []byte(`{"tags": ["spring", "campaign", "spring"]}`))
+	c.Params = gin.Params{{Key: "id", Value: "url-1"}}
+	handler.Update(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Tags []string `json:"tags"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []string{"campaign", "spring"}, response.Tags)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortenHandler_Shorten_WithTags(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewShortenHandler(db, &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	})
+	userID := "user123"
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "launch").
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectQuery(`SELECT (.+) FROM "users"`).
+		WithArgs(userID).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM "monthly_link_limits"`).
+		WithArgs(userID, "user", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectExec(`INSERT INTO "monthly_link_limits"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// The link and its tags are stored in one transaction
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "short_urls"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`SELECT (.+) FROM "tags" WHERE user_id = \$1 AND name IN \(\$2\)`).
+		WithArgs(userID, "campaign").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "created_at", "updated_at"}).
+			AddRow("tag-1", userID, "campaign", now, now))
+	mock.ExpectExec(`DELETE FROM "short_url_tags"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "short_url_tags"`).
+		WithArgs(sqlmock.AnyArg(), "tag-1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	w := performShortenAs(handler, userID, `{"domain": "example.com", "url": "https://example.com/target", "slug": "launch", "tags": ["campaign"]}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response struct {
+		Tags []string `json:"tags"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []string{"campaign"}, response.Tags)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShortenHandler_Shorten_TagsRequireAuthentication(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	handler := NewShortenHandler(db, &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	})
+
+	w := performShortenAs(handler, "", `{"domain": "example.com", "url": "https://example.com/target", "tags": ["campaign"]}`)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
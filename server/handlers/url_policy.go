@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"openshortpath/server/models"
+	"openshortpath/server/services"
+)
+
+// URLPolicyHandler exposes administrative actions for the destination URL policy
+type URLPolicyHandler struct {
+	policy *services.URLPolicy
+}
+
+func NewURLPolicyHandler(policy *services.URLPolicy) *URLPolicyHandler {
+	return &URLPolicyHandler{
+		policy: policy,
+	}
+}
+
+// Reload handles POST /api/v1/__admin/url-policy/reload
+// Re-reads the domain blocklist file
+func (h *URLPolicyHandler) Reload(c *gin.Context) {
+	if err := h.policy.Reload(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to reload URL policy",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"blocklist_size": h.policy.BlocklistSize(),
+	})
+}
+
+// checkDestinationURL applies the URL policy to one destination and returns its normalized form
+// On rejection the body of a 400 with the policy's error code and the offending field is returned
+func checkDestinationURL(ctx context.Context, policy *services.URLPolicy, field, rawURL string) (string, gin.H) {
+	normalized, err := policy.Check(ctx, rawURL)
+	if err != nil {
+		var policyErr *services.URLPolicyError
+		if errors.As(err, &policyErr) {
+			return "", gin.H{
+				"error": policyErr.Message,
+				"code":  policyErr.Code,
+				"field": field,
+			}
+		}
+		return "", gin.H{
+			"error":   "Invalid URL",
+			"details": err.Error(),
+			"field":   field,
+		}
+	}
+	return normalized, nil
+}
+
+// policyURL is a destination URL field checked by applyURLPolicy
+type policyURL struct {
+	field string  // JSON field reported when the URL is rejected
+	url   *string // Replaced with the normalized URL
+}
+
+// applyURLPolicy checks a link's destinations in place and writes a 400 if one is rejected
+func applyURLPolicy(c *gin.Context, policy *services.URLPolicy, destinations models.SplitDestinations, urls ...policyURL) bool {
+	if rejection := urlPolicyRejection(c.Request.Context(), policy, destinations, urls...); rejection != nil {
+		c.JSON(http.StatusBadRequest, rejection)
+		return false
+	}
+	return true
+}
+
+// urlPolicyRejection checks a link's destinations in place: the given URL fields, then the split variants
+// nil or empty URLs are skipped. Deep link app URLs are never passed in, so apps can use custom schemes
+// Without a policy nothing is checked. Returns the error body for the first rejected URL, or nil
+func urlPolicyRejection(ctx context.Context, policy *services.URLPolicy, destinations models.SplitDestinations, urls ...policyURL) gin.H {
+	if policy == nil {
+		return nil
+	}
+
+	for _, u := range urls {
+		if u.url == nil || *u.url == "" {
+			continue
+		}
+		normalized, rejection := checkDestinationURL(ctx, policy, u.field, *u.url)
+		if rejection != nil {
+			return rejection
+		}
+		*u.url = normalized
+	}
+
+	for i := range destinations {
+		normalized, rejection := checkDestinationURL(ctx, policy, fmt.Sprintf("destinations[%d].url", i), destinations[i].URL)
+		if rejection != nil {
+			return rejection
+		}
+		destinations[i].URL = normalized
+	}
+
+	return nil
+}
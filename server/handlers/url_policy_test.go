@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"openshortpath/server/config"
+	"openshortpath/server/services"
+)
+
+// newTestURLPolicy returns a default policy whose DNS lookups resolve to a public address
+func newTestURLPolicy(t *testing.T) *services.URLPolicy {
+	policy, err := services.NewURLPolicy(nil, false, []string{"example.com"}, "")
+	assert.NoError(t, err)
+	policy.SetLookupIP(func(ctx context.Context, host string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("93.184.216.34")}, nil
+	})
+	return policy
+}
+
+func TestShortenHandler_Shorten_URLPolicyRejected(t *testing.T) {
+	tests := []struct {
+		name          string
+		reqBody       string
+		expectedCode  string
+		expectedField string
+	}{
+		{
+			name:          "scheme not allowed",
+			reqBody:       `{"domain": "example.com", "url": "javascript:alert(1)"}`,
+			expectedCode:  services.URLPolicySchemeNotAllowed,
+			expectedField: "url",
+		},
+		{
+			name:          "private address",
+			reqBody:       `{"domain": "example.com", "url": "http://192.168.0.1/admin"}`,
+			expectedCode:  services.URLPolicyPrivateAddress,
+			expectedField: "url",
+		},
+		{
+			name:          "short link loop",
+			reqBody:       `{"domain": "example.com", "url": "https://example.com/other"}`,
+			expectedCode:  services.URLPolicyShortLinkLoop,
+			expectedField: "url",
+		},
+		{
+			name:          "split destination",
+			reqBody:       `{"domain": "example.com", "url": "https://target.test/", "destinations": [{"url": "https://a.test/", "weight": 50}, {"url": "http://127.0.0.1/", "weight": 50}]}`,
+			expectedCode:  services.URLPolicyPrivateAddress,
+			expectedField: "destinations[1].url",
+		},
+		{
+			name:          "deep link fallback",
+			reqBody:       `{"domain": "example.com", "url": "https://target.test/", "deep_link": {"ios_url": "myapp://item/1", "fallback_url": "http://10.0.0.5/app"}}`,
+			expectedCode:  services.URLPolicyPrivateAddress,
+			expectedField: "deep_link.fallback_url",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, sqlDB := setupTestDB(t)
+			defer sqlDB.Close()
+
+			cfg := &config.Config{
+				AvailableShortDomains: []string{"example.com"},
+			}
+			handler := NewShortenHandler(db, cfg)
+			handler.SetURLPolicy(newTestURLPolicy(t))
+
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/shorten", strings.NewReader(tt.reqBody))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			handler.Shorten(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var response map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedCode, response["code"])
+			assert.Equal(t, tt.expectedField, response["field"])
+			assert.NotEmpty(t, response["error"])
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestShortenHandler_Shorten_URLPolicyNormalizes(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	cfg := &config.Config{
+		AvailableShortDomains: []string{"example.com"},
+	}
+	handler := NewShortenHandler(db, cfg)
+	handler.SetURLPolicy(newTestURLPolicy(t))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM "monthly_link_limits"`).
+		WithArgs(sqlmock.AnyArg(), "ip", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+	mock.ExpectExec(`INSERT INTO "monthly_link_limits"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "ip", 1, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	// Generated slugs are checked for collisions after the quota and namespace checks
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", sqlmock.AnyArg()).
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "short_urls"`).
+		WithArgs(sqlmock.AnyArg(), "example.com", sqlmock.AnyArg(), "https://target.test/Docs", sqlmock.AnyArg(), "", nil, 0, nil, nil, 0, nil, nil, false, false, false, "", "", "", "", "", "", "", "", "", "", 0, 0, nil, "", 0, "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	reqBody := `{"domain": "example.com", "url": "HTTPS://Target.TEST:443/Docs"}`
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/shorten", strings.NewReader(reqBody))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	handler.Shorten(c)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "https://target.test/Docs", response["url"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
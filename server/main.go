@@ -1,14 +1,17 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
-	"net/http/httptest"
 	"os"
-	"strings"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/driver/postgres"
@@ -19,6 +22,7 @@ import (
 	"openshortpath/server/handlers"
 	"openshortpath/server/middleware"
 	"openshortpath/server/models"
+	"openshortpath/server/services"
 )
 
 //go:embed dashboard-dist
@@ -61,10 +65,28 @@ func main() {
 	}
 
 	// Auto-migrate database models
-	if err := db.AutoMigrate(&models.ShortURL{}, &models.User{}, &models.APIKey{}, &models.Namespace{}, &models.RateLimit{}, &models.MonthlyLinkLimit{}); err != nil {
+	if err := db.AutoMigrate(&models.ShortURL{}, &models.User{}, &models.APIKey{}, &models.Namespace{}, &models.RateLimit{}, &models.MonthlyLinkLimit{}, &models.ClickEvent{}, &models.GeoRule{}, &models.DeviceRule{}, &models.SlugCounter{}, &models.ImportJob{}, &models.IdempotencyKey{}, &models.Tag{}, &models.ShortURLTag{}); err != nil {
 		log.Fatalf("Failed to auto-migrate database: %v", err)
 	}
 
+	// Hash the destinations of links created before deduplication existed
+	backfilled, err := services.BackfillURLHashes(db)
+	if err != nil {
+		log.Fatalf("Failed to backfill url_hash: %v", err)
+	}
+	if backfilled > 0 {
+		log.Printf("Backfilled url_hash for %d short URLs", backfilled)
+	}
+
+	// Imports don't survive a restart, so jobs the last run left unfinished never will be
+	interrupted, err := handlers.FailInterruptedImportJobs(db)
+	if err != nil {
+		log.Fatalf("Failed to mark interrupted import jobs: %v", err)
+	}
+	if interrupted > 0 {
+		log.Printf("Marked %d interrupted import jobs as failed", interrupted)
+	}
+
 	// Set Gin mode
 	if os.Getenv("GIN_MODE") == "" {
 		gin.SetMode(gin.ReleaseMode)
@@ -89,17 +111,95 @@ func main() {
 	// Rate limiting middleware runs after OptionalAuth so user context is available
 	apiV1 := r.Group("/api/v1")
 
+	// Check the default slug generation settings before any link is created
+	if err := services.ValidateSlugGeneration(models.SlugGeneration{
+		Strategy: cfg.SlugGeneration.Strategy,
+		Length:   cfg.SlugGeneration.Length,
+		Alphabet: cfg.SlugGeneration.Alphabet,
+	}); err != nil {
+		log.Fatalf("Invalid slug_generation: %v", err)
+	}
+
 	// Initialize handlers with database
 	shortenHandler := handlers.NewShortenHandler(db, cfg)
 	redirectHandler := handlers.NewRedirectHandler(db, cfg)
+	if err := redirectHandler.LoadNotFoundTemplates(); err != nil {
+		log.Fatalf("Failed to load not-found templates: %v", err)
+	}
 	authProviderHandler := handlers.NewAuthProviderHandler(cfg)
 	domainsHandler := handlers.NewDomainsHandler(cfg)
 
+	// Record clicks asynchronously so redirects never wait on the database
+	clickRecorder := services.NewClickRecorder(db, 0, 0, 0)
+	redirectHandler.SetClickRecorder(clickRecorder)
+	log.Printf("Click analytics enabled")
+
+	// Cache redirect lookups in memory unless disabled
+	var redirectCache *services.RedirectCache
+	if cfg.RedirectCacheSize >= 0 {
+		redirectCache = services.NewRedirectCache(cfg.RedirectCacheSize, time.Duration(cfg.RedirectCacheTTL)*time.Second)
+		redirectHandler.SetRedirectCache(redirectCache)
+		shortenHandler.SetRedirectCache(redirectCache)
+		log.Printf("Redirect cache enabled (size: %d)", redirectCache.Stats().MaxSize)
+	}
+
+	// Open the GeoIP database if configured so per-link country rules can be evaluated
+	if cfg.GeoIPDatabasePath != "" {
+		geoIPDatabase, err := services.OpenGeoIPDatabase(cfg.GeoIPDatabasePath)
+		if err != nil {
+			log.Fatalf("Failed to load GeoIP database: %v", err)
+		}
+		defer geoIPDatabase.Close()
+		redirectHandler.SetCountryLookup(geoIPDatabase)
+		log.Printf("Geo-targeting enabled using %s", cfg.GeoIPDatabasePath)
+	}
+
+	// Validate destination URLs; the domain blocklist is re-read on SIGHUP
+	urlPolicy, err := services.NewURLPolicy(cfg.URLPolicy.AllowedSchemes, cfg.URLPolicy.AllowPrivateAddresses, cfg.AvailableShortDomains, cfg.URLPolicy.BlocklistPath)
+	if err != nil {
+		log.Fatalf("Failed to load URL policy: %v", err)
+	}
+	shortenHandler.SetURLPolicy(urlPolicy)
+	if cfg.URLPolicy.BlocklistPath != "" {
+		reloadSignals := make(chan os.Signal, 1)
+		signal.Notify(reloadSignals, syscall.SIGHUP)
+		go func() {
+			for range reloadSignals {
+				if err := urlPolicy.Reload(); err != nil {
+					log.Printf("Failed to reload URL blocklist: %v", err)
+					continue
+				}
+				log.Printf("URL blocklist reloaded (%d domains)", urlPolicy.BlocklistSize())
+			}
+		}()
+		log.Printf("URL blocklist loaded from %s (%d domains)", cfg.URLPolicy.BlocklistPath, urlPolicy.BlocklistSize())
+	}
+
+	// Check link destinations in the background if enabled
+	if cfg.LinkHealth.Enabled {
+		linkHealthChecker := services.NewLinkHealthChecker(db,
+			time.Duration(cfg.LinkHealth.Interval)*time.Second,
+			cfg.LinkHealth.Concurrency,
+			time.Duration(cfg.LinkHealth.HostDelayMS)*time.Millisecond,
+			time.Duration(cfg.LinkHealth.Timeout)*time.Second,
+			cfg.LinkHealth.FailureThreshold,
+			cfg.URLPolicy.AllowPrivateAddresses)
+		linkHealthChecker.SetRedirectCache(redirectCache)
+		linkHealthChecker.Start()
+		defer linkHealthChecker.Close()
+		log.Printf("Link health checks enabled")
+	}
+
 	// Register API routes first (highest priority)
 	// Shorten endpoint - authentication is optional (handled by OptionalAuth middleware)
 	// Rate limiting is applied only to the shorten endpoint per IP for anonymous users, per user for authenticated users
-	apiV1.POST("/shorten", middleware.RateLimitMiddleware(db), shortenHandler.Shorten)
-	log.Printf("Rate limiting enabled for /api/v1/shorten endpoint")
+	// Requests with an Idempotency-Key are rate limited before their body is read; retries with the same key
+	// count against the rate limit and then replay the original response
+	idempotency := middleware.IdempotencyMiddleware(db, time.Duration(cfg.IdempotencyKeyTTL)*time.Second)
+	apiV1.POST("/shorten", middleware.RateLimitMiddleware(db), idempotency, shortenHandler.Shorten)
+	// A bulk request counts once against the request rate limit; each created link counts against the monthly limit
+	apiV1.POST("/shorten/bulk", middleware.RateLimitMiddleware(db), idempotency, shortenHandler.ShortenBulk)
+	log.Printf("Rate limiting enabled for /api/v1/shorten endpoints")
 
 	// Public endpoints without rate limiting
 	apiV1.GET("/auth-provider", authProviderHandler.GetAuthProvider)
@@ -134,13 +234,19 @@ func main() {
 		adminRoutes.GET("/users", adminUsersHandler.ListUsers)
 		adminRoutes.PUT("/users/:user_id", adminUsersHandler.UpdateUser)
 		adminRoutes.DELETE("/users/:user_id", adminUsersHandler.DeleteUser)
+		adminRoutes.GET("/redirect-cache", redirectHandler.GetCacheStats)
+		adminRoutes.POST("/url-policy/reload", handlers.NewURLPolicyHandler(urlPolicy).Reload)
 
 		log.Printf("Admin endpoints enabled at /api/v1/__admin/*")
 	}
 
 	// Register short URL management endpoints if JWT config is provided
+	// The import handler is kept to stop its background jobs on shutdown
+	var importHandler *handlers.ImportHandler
 	if cfg.JWT != nil {
 		shortURLsHandler := handlers.NewShortURLsHandler(db, cfg)
+		shortURLsHandler.SetRedirectCache(redirectCache)
+		shortURLsHandler.SetURLPolicy(urlPolicy)
 
 		// Create route group with required authentication middleware
 		shortURLsRoutes := apiV1.Group("/short-urls")
@@ -148,14 +254,49 @@ func main() {
 
 		// Register short URL management routes with scope checks
 		shortURLsRoutes.GET("", middleware.RequireScope("read_urls"), shortURLsHandler.List)
+		shortURLsRoutes.GET("/export", middleware.RequireScope("read_urls"), shortURLsHandler.Export)
 		shortURLsRoutes.GET("/:id", middleware.RequireScope("read_urls"), shortURLsHandler.Get)
 		shortURLsRoutes.PUT("/:id", middleware.RequireScope("write_urls"), shortURLsHandler.Update)
 		shortURLsRoutes.DELETE("/:id", middleware.RequireScope("write_urls"), shortURLsHandler.Delete)
 
+		// Register click stats route (same ownership and scope rules as Get)
+		statsHandler := handlers.NewStatsHandler(db)
+		shortURLsRoutes.GET("/:id/stats", middleware.RequireScope("read_urls"), statsHandler.GetStats)
+
+		// Register QR code route (same ownership and scope rules as Get)
+		shortURLsRoutes.GET("/:id/qr", middleware.RequireScope("read_urls"), shortURLsHandler.GetQRCode)
+
+		// Register geo rule sub-resources
+		geoRulesHandler := handlers.NewGeoRulesHandler(db)
+		geoRulesHandler.SetURLPolicy(urlPolicy)
+		geoRulesHandler.SetRedirectCache(redirectCache)
+		shortURLsRoutes.GET("/:id/geo-rules", middleware.RequireScope("read_urls"), geoRulesHandler.ListGeoRules)
+		shortURLsRoutes.POST("/:id/geo-rules", middleware.RequireScope("write_urls"), geoRulesHandler.CreateGeoRule)
+		shortURLsRoutes.PUT("/:id/geo-rules/:rule_id", middleware.RequireScope("write_urls"), geoRulesHandler.UpdateGeoRule)
+		shortURLsRoutes.DELETE("/:id/geo-rules/:rule_id", middleware.RequireScope("write_urls"), geoRulesHandler.DeleteGeoRule)
+
+		// Register device rule sub-resources
+		deviceRulesHandler := handlers.NewDeviceRulesHandler(db)
+		deviceRulesHandler.SetURLPolicy(urlPolicy)
+		deviceRulesHandler.SetRedirectCache(redirectCache)
+		shortURLsRoutes.GET("/:id/device-rules", middleware.RequireScope("read_urls"), deviceRulesHandler.ListDeviceRules)
+		shortURLsRoutes.POST("/:id/device-rules", middleware.RequireScope("write_urls"), deviceRulesHandler.CreateDeviceRule)
+		shortURLsRoutes.PUT("/:id/device-rules/:rule_id", middleware.RequireScope("write_urls"), deviceRulesHandler.UpdateDeviceRule)
+		shortURLsRoutes.DELETE("/:id/device-rules/:rule_id", middleware.RequireScope("write_urls"), deviceRulesHandler.DeleteDeviceRule)
+
+		// Register import routes; large imports run in the background and are polled as jobs
+		importHandler = handlers.NewImportHandler(db, cfg)
+		importHandler.SetRedirectCache(redirectCache)
+		importHandler.SetURLPolicy(urlPolicy)
+		shortURLsRoutes.POST("/import", middleware.RequireScope("write_urls"), importHandler.Import)
+		apiV1.GET("/import-jobs/:id", jwtMiddleware.RequireAuth(), middleware.RequireScope("read_urls"), importHandler.GetImportJob)
+
 		log.Printf("Short URL management endpoints enabled at /api/v1/short-urls/*")
 
 		// Register namespace management endpoints with JWT authentication
 		namespacesHandler := handlers.NewNamespacesHandler(db, cfg)
+		namespacesHandler.SetRedirectCache(redirectCache)
+		namespacesHandler.SetURLPolicy(urlPolicy)
 		namespacesRoutes := apiV1.Group("/namespaces")
 		namespacesRoutes.Use(jwtMiddleware.RequireAuth())
 
@@ -168,9 +309,21 @@ func main() {
 
 		log.Printf("Namespace management endpoints enabled at /api/v1/namespaces/*")
 
+		// Register tag management endpoints with JWT authentication
+		tagsHandler := handlers.NewTagsHandler(db)
+		tagsRoutes := apiV1.Group("/tags")
+		tagsRoutes.Use(jwtMiddleware.RequireAuth())
+		tagsRoutes.GET("", middleware.RequireScope("read_urls"), tagsHandler.ListTags)
+		tagsRoutes.POST("", middleware.RequireScope("write_urls"), tagsHandler.CreateTag)
+		tagsRoutes.PUT("/:id", middleware.RequireScope("write_urls"), tagsHandler.RenameTag)
+		tagsRoutes.DELETE("/:id", middleware.RequireScope("write_urls"), tagsHandler.DeleteTag)
+
+		log.Printf("Tag management endpoints enabled at /api/v1/tags/*")
+
 		// Register user endpoints with required authentication middleware
 		meHandler := handlers.NewMeHandler(db)
 		apiV1.GET("/me", jwtMiddleware.RequireAuth(), meHandler.GetMe)
+		apiV1.PATCH("/me", jwtMiddleware.RequireAuth(), meHandler.UpdateMe)
 
 		log.Printf("User endpoints enabled at /api/v1/me")
 
@@ -196,86 +349,9 @@ func main() {
 	r.Any("/", landingHandler.ServeLanding)
 	log.Printf("Landing page enabled at /")
 
-	// NoRoute handler: try redirect first (for short URLs), then fall back to landing page
-	// This allows short URLs to work while also supporting landing page routes like /docs
-	r.NoRoute(func(c *gin.Context) {
-		// Skip if it's an API or dashboard route (shouldn't happen, but safety check)
-		path := c.Request.URL.Path
-		if strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/dashboard/") {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "Route not found",
-			})
-			return
-		}
-
-		// Reserved paths that should always be served by landing page (Next.js routes and static assets)
-		reservedPaths := []string{
-			"/_next",      // Next.js static assets and internal routes
-			"/docs",       // Landing page docs routes
-			"/favicon.ico", // Favicon
-			"/robots.txt",  // Robots.txt
-			"/sitemap.xml", // Sitemap
-		}
-
-		// Check if path is a reserved landing page path
-		isReservedPath := false
-		for _, reserved := range reservedPaths {
-			if path == reserved || strings.HasPrefix(path, reserved+"/") {
-				isReservedPath = true
-				break
-			}
-		}
-
-		// If it's a reserved path, serve landing page directly
-		if isReservedPath {
-			landingHandler.ServeLanding(c)
-			return
-		}
-
-		// Check if path looks like a short URL (1-2 path segments, not reserved)
-		// If it does, try redirect first; otherwise serve landing page directly
-		pathParts := strings.Split(strings.Trim(path, "/"), "/")
-		isPotentialShortURL := len(pathParts) <= 2 && len(pathParts) > 0
-
-		// Also check if first segment is a reserved namespace name
-		if isPotentialShortURL && len(pathParts) > 0 {
-			firstSegment := strings.ToLower(pathParts[0])
-			if handlers.IsReservedNamespaceName(firstSegment) {
-				// First segment is reserved, serve landing page
-				landingHandler.ServeLanding(c)
-				return
-			}
-		}
-
-		if isPotentialShortURL {
-			// Use response recorder to buffer redirect handler's response
-			// This allows us to check if it found a short URL before committing the response
-			w := httptest.NewRecorder()
-			newContext, _ := gin.CreateTestContext(w)
-			newContext.Request = c.Request
-			newContext.Params = c.Params
-			
-			// Try redirect handler with test context
-			redirectHandler.Redirect(newContext)
-			
-			// Check if redirect found a short URL (status 301)
-			if w.Code == http.StatusMovedPermanently {
-				// Copy the redirect response to actual response
-				for k, v := range w.Header() {
-					for _, val := range v {
-						c.Writer.Header().Add(k, val)
-					}
-				}
-				c.Writer.WriteHeader(w.Code)
-				c.Writer.Write(w.Body.Bytes())
-				return
-			}
-			// Redirect didn't find a match (returned 404), fall through to serve landing page
-		}
-
-		// Serve landing page (either because it's not a short URL pattern, or redirect didn't find a match)
-		landingHandler.ServeLanding(c)
-	})
+	// Everything else is resolved to a short link, the landing page or a 404
+	shortLinkRouter := handlers.NewShortLinkRouter(redirectHandler, landingHandler.ServeLanding, cfg.LandingReservedPaths)
+	r.NoRoute(shortLinkRouter.Handle)
 
 	// Start server
 	port := os.Getenv("PORT")
@@ -283,8 +359,54 @@ func main() {
 		port = fmt.Sprintf("%d", cfg.Port)
 	}
 
+	srv := &http.Server{
+		Addr:    ":" + port,
+		Handler: r,
+	}
 	log.Printf("Starting server on :%s", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	serverErr := runServer(srv)
+
+	// Stop background workers once no more requests can reach them
+	if importHandler != nil {
+		importHandler.Close()
+	}
+	clickRecorder.Close()
+
+	if serverErr != nil {
+		log.Fatalf("Failed to start server: %v", serverErr)
+	}
+	log.Printf("Server stopped")
+}
+
+// shutdownTimeout is how long in-flight requests get to finish after a stop signal
+const shutdownTimeout = 30 * time.Second
+
+// runServer serves until SIGINT or SIGTERM, then shuts the server down gracefully
+// Returns nil after a graceful shutdown, or the error that stopped the server
+func runServer(srv *http.Server) error {
+	stopSignals := make(chan os.Signal, 1)
+	signal.Notify(stopSignals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stopSignals)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case sig := <-stopSignals:
+		log.Printf("Received %s, shutting down", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Failed to shut down gracefully: %v", err)
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
 	}
+	return nil
 }
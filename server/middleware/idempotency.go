@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"openshortpath/server/constants"
+	"openshortpath/server/services"
+)
+
+// IdempotencyKeyHeader is the request header that makes a request safe to retry
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader marks a response that was replayed for a repeated Idempotency-Key
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// maxIdempotentRequestSize is the largest request body read to fingerprint a request with an Idempotency-Key
+// It leaves room for a full bulk shorten request
+const maxIdempotentRequestSize = 4 << 20
+
+// idempotencyResponseWriter keeps a copy of the response body so it can be replayed
+type idempotencyResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *idempotencyResponseWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *idempotencyResponseWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware creates a middleware that makes requests with an Idempotency-Key header safe to retry
+// A key is remembered for ttl per user (or per IP for anonymous users) and endpoint. Repeating it with the
+// same body returns the original response and status without running the handler again; repeating it with a
+// different body is rejected with 422. Only successful responses are remembered, so failed requests can be retried
+func IdempotencyMiddleware(db *gorm.DB, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader(IdempotencyKeyHeader))
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > services.MaxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("%s must not be longer than %d characters", IdempotencyKeyHeader, services.MaxIdempotencyKeyLength),
+			})
+			c.Abort()
+			return
+		}
+
+		// Keys are scoped to the user, or to the client IP for anonymous users
+		identifier := services.GetClientIP(c)
+		limitType := constants.RateLimitTypeIP
+		if userIDValue, exists := c.Get(constants.ContextKeyUserID); exists {
+			if userID, ok := userIDValue.(string); ok && userID != "" {
+				identifier = userID
+				limitType = constants.RateLimitTypeUser
+			}
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentRequestSize))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+					"error": fmt.Sprintf("Requests with an %s must not be larger than %d MB", IdempotencyKeyHeader, maxIdempotentRequestSize>>20),
+				})
+				c.Abort()
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Failed to read request body",
+				"details": err.Error(),
+			})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		record, claimed, err := services.BeginIdempotentRequest(db, identifier, limitType, c.FullPath(), key, services.IdempotencyRequestHash(body), ttl)
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrIdempotencyKeyMismatch):
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"error": fmt.Sprintf("%s was already used with a different request", IdempotencyKeyHeader),
+				})
+			case errors.Is(err, services.ErrIdempotencyKeyInProgress):
+				c.JSON(http.StatusConflict, gin.H{
+					"error": fmt.Sprintf("A request with this %s is still being processed", IdempotencyKeyHeader),
+				})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "Idempotency key check failed",
+					"details": err.Error(),
+				})
+			}
+			c.Abort()
+			return
+		}
+
+		if !claimed {
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(record.StatusCode, record.ContentType, []byte(record.ResponseBody))
+			c.Abort()
+			return
+		}
+
+		// The key is released unless a successful response is stored, including when the handler panics
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			if err := services.ReleaseIdempotentRequest(db, record); err != nil {
+				log.Printf("Failed to release idempotency key: %v", err)
+			}
+		}()
+
+		writer := &idempotencyResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		status := writer.Status()
+		if status < 200 || status >= 300 {
+			return
+		}
+		if err := services.CompleteIdempotentRequest(db, record, status, writer.Header().Get("Content-Type"), writer.body.Bytes()); err != nil {
+			log.Printf("Failed to store idempotent response: %v", err)
+			return
+		}
+		completed = true
+	}
+}
@@ -0,0 +1,221 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"openshortpath/server/constants"
+	"openshortpath/server/services"
+)
+
+const idempotencyTestBody = `{"url": "https://example.com", "domain": "example.com"}`
+
+// newIdempotencyTestRouter serves POST /shorten for user123 through the idempotency middleware
+// status is what the handler responds with; calls counts how often it ran
+func newIdempotencyTestRouter(db *gorm.DB, status int, calls *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/shorten", func(c *gin.Context) {
+		c.Set(constants.ContextKeyUserID, "user123")
+		c.Next()
+	}, IdempotencyMiddleware(db, time.Hour), func(c *gin.Context) {
+		*calls++
+		c.JSON(status, gin.H{"slug": "abc12"})
+	})
+	return router
+}
+
+func performIdempotentRequest(router *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/shorten", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// expectIdempotencyKeyTaken expects a claim of key-1 that finds the key already stored
+func expectIdempotencyKeyTaken(mock sqlmock.Sqlmock, rows *sqlmock.Rows) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "idempotency_keys" WHERE identifier = \$1 AND type = \$2 AND expires_at <= \$3`).
+		WithArgs("user123", constants.RateLimitTypeUser, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "idempotency_keys"`).
+		WillReturnError(errors.New("duplicate key value violates unique constraint"))
+	mock.ExpectRollback()
+	mock.ExpectQuery(`SELECT (.+) FROM "idempotency_keys" WHERE idempotency_key = \$1 AND identifier = \$2 AND type = \$3 AND endpoint = \$4`).
+		WithArgs("key-1", "user123", constants.RateLimitTypeUser, "/shorten").
+		WillReturnRows(rows)
+}
+
+func TestIdempotencyMiddleware_StoresSuccessfulResponse(t *testing.T) {
+	db, mock, sqlDB := setupTestDBForRateLimit(t)
+	defer sqlDB.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "idempotency_keys"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "idempotency_keys"`).
+		WithArgs(sqlmock.AnyArg(), "key-1", "user123", constants.RateLimitTypeUser, "/shorten", services.IdempotencyRequestHash([]byte(idempotencyTestBody)), 0, "", "", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "idempotency_keys" SET "content_type"=\$1,"response_body"=\$2,"status_code"=\$3`).
+		WithArgs("application/json; charset=utf-8", `{"slug":"abc12"}`, http.StatusCreated, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	calls := 0
+	w := performIdempotentRequest(newIdempotencyTestRouter(db, http.StatusCreated, &calls), "key-1", idempotencyTestBody)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 1, calls)
+	assert.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdempotencyMiddleware_ReplaysRepeatedKey(t *testing.T) {
+	db, mock, sqlDB := setupTestDBForRateLimit(t)
+	defer sqlDB.Close()
+
+	// The retry formats the same JSON differently
+	expectIdempotencyKeyTaken(mock, sqlmock.NewRows([]string{"id", "request_hash", "status_code", "content_type", "response_body"}).
+		AddRow("record-1", services.IdempotencyRequestHash([]byte(idempotencyTestBody)), http.StatusCreated, "application/json; charset=utf-8", `{"slug":"abc12"}`))
+
+	calls := 0
+	w := performIdempotentRequest(newIdempotencyTestRouter(db, http.StatusCreated, &calls), "key-1", `{"domain":"example.com","url":"https://example.com"}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, `{"slug":"abc12"}`, w.Body.String())
+	assert.Equal(t, "true", w.Header().Get(IdempotentReplayedHeader))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdempotencyMiddleware_RejectsDifferentPayload(t *testing.T) {
+	db, mock, sqlDB := setupTestDBForRateLimit(t)
+	defer sqlDB.Close()
+
+	expectIdempotencyKeyTaken(mock, sqlmock.NewRows([]string{"id", "request_hash", "status_code", "content_type", "response_body"}).
+		AddRow("record-1", services.IdempotencyRequestHash([]byte(idempotencyTestBody)), http.StatusCreated, "application/json", `{"slug":"abc12"}`))
+
+	calls := 0
+	w := performIdempotentRequest(newIdempotencyTestRouter(db, http.StatusCreated, &calls), "key-1", `{"url": "https://example.com/other", "domain": "example.com"}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, 0, calls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdempotencyMiddleware_RequestStillInProgress(t *testing.T) {
+	db, mock, sqlDB := setupTestDBForRateLimit(t)
+	defer sqlDB.Close()
+
+	expectIdempotencyKeyTaken(mock, sqlmock.NewRows([]string{"id", "request_hash", "status_code", "created_at"}).
+		AddRow("record-1", services.IdempotencyRequestHash([]byte(idempotencyTestBody)), 0, time.Now().UTC()))
+
+	calls := 0
+	w := performIdempotentRequest(newIdempotencyTestRouter(db, http.StatusCreated, &calls), "key-1", idempotencyTestBody)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, 0, calls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdempotencyMiddleware_TakesOverStalePendingKey(t *testing.T) {
+	db, mock, sqlDB := setupTestDBForRateLimit(t)
+	defer sqlDB.Close()
+
+	// The first request with the key never finished, and its lease has run out
+	expectIdempotencyKeyTaken(mock, sqlmock.NewRows([]string{"id", "request_hash", "status_code", "created_at"}).
+		AddRow("record-1", services.IdempotencyRequestHash([]byte(idempotencyTestBody)), 0, time.Now().UTC().Add(-2*services.IdempotencyPendingLease)))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "idempotency_keys" SET "created_at"=\$1,"expires_at"=\$2,"updated_at"=\$3 WHERE \(status_code = \$4 AND created_at <= \$5\) AND "id" = \$6`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 0, sqlmock.AnyArg(), "record-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "idempotency_keys" SET "content_type"=\$1,"response_body"=\$2,"status_code"=\$3`).
+		WithArgs("application/json; charset=utf-8", `{"slug":"abc12"}`, http.StatusCreated, sqlmock.AnyArg(), "record-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	calls := 0
+	w := performIdempotentRequest(newIdempotencyTestRouter(db, http.StatusCreated, &calls), "key-1", idempotencyTestBody)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 1, calls)
+	assert.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdempotencyMiddleware_ReleasesKeyOnFailure(t *testing.T) {
+	db, mock, sqlDB := setupTestDBForRateLimit(t)
+	defer sqlDB.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "idempotency_keys"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "idempotency_keys"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	// The failed request is forgotten, so a retry with the same key runs again
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "idempotency_keys" WHERE "idempotency_keys"."id" = \$1`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	calls := 0
+	w := performIdempotentRequest(newIdempotencyTestRouter(db, http.StatusBadRequest, &calls), "key-1", idempotencyTestBody)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, 1, calls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdempotencyMiddleware_WithoutKey(t *testing.T) {
+	db, mock, sqlDB := setupTestDBForRateLimit(t)
+	defer sqlDB.Close()
+
+	calls := 0
+	router := newIdempotencyTestRouter(db, http.StatusCreated, &calls)
+	performIdempotentRequest(router, "", idempotencyTestBody)
+	performIdempotentRequest(router, "", idempotencyTestBody)
+
+	assert.Equal(t, 2, calls)
+
+	w := performIdempotentRequest(router, strings.Repeat("k", services.MaxIdempotencyKeyLength+1), idempotencyTestBody)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, 2, calls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdempotencyMiddleware_RequestTooLarge(t *testing.T) {
+	db, mock, sqlDB := setupTestDBForRateLimit(t)
+	defer sqlDB.Close()
+
+	calls := 0
+	router := newIdempotencyTestRouter(db, http.StatusCreated, &calls)
+	w := performIdempotentRequest(router, "key-1", strings.Repeat("x", maxIdempotentRequestSize+1))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Equal(t, 0, calls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
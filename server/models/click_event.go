@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ClickEvent represents a single successful redirect of a short URL
+type ClickEvent struct {
+	ID          string    `gorm:"primaryKey;size:36" json:"id"`
+	ShortURLID  string    `gorm:"index:idx_click_short_url_time;size:36;not null" json:"short_url_id"`
+	ClickedAt   time.Time `gorm:"index:idx_click_short_url_time;not null" json:"clicked_at"`
+	Referrer    string    `gorm:"size:2048" json:"referrer"`
+	UserAgent   string    `gorm:"size:1024" json:"user_agent"`
+	IPAddress   string    `gorm:"size:64" json:"ip_address"`
+	Platform    string    `gorm:"size:16" json:"platform"`    // Parsed from the User-Agent, see utils.ParseUserAgent
+	DeviceType  string    `gorm:"size:16" json:"device_type"` // mobile, tablet, desktop, bot or unknown
+	Browser     string    `gorm:"size:32" json:"browser"`
+	Destination string    `gorm:"size:2048" json:"destination"` // URL the visitor was sent to, e.g. the chosen A/B variant
+	CreatedAt   time.Time `json:"created_at"`
+	// Counted is set when the short URL's click_count was already incremented on the redirect path
+	Counted bool `gorm:"-" json:"-"`
+}
+
+// TableName specifies the table name for GORM
+func (ClickEvent) TableName() string {
+	return "click_events"
+}
+
+// BeforeCreate hook to generate UUID
+func (e *ClickEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+package models
+
+// DeepLink holds the app URLs a short URL tries to open on mobile before falling back to the web
+// App URLs may use custom schemes (myapp://) or universal/app links (https://)
+type DeepLink struct {
+	IOSURL      string `gorm:"column:ios_url;size:2048" json:"ios_url,omitempty"`
+	AndroidURL  string `gorm:"column:android_url;size:2048" json:"android_url,omitempty"`
+	FallbackURL string `gorm:"column:fallback_url;size:2048" json:"fallback_url,omitempty"` // Web URL used when the app doesn't open; defaults to the link's destination
+}
+
+// IsEmpty reports whether no app URL is configured
+func (d DeepLink) IsEmpty() bool {
+	return d.IOSURL == "" && d.AndroidURL == ""
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DeviceRule sends visitors on one platform (parsed from the User-Agent) to an alternative destination of a short URL
+type DeviceRule struct {
+	ID         string    `gorm:"primaryKey;size:36" json:"id"`
+	ShortURLID string    `gorm:"uniqueIndex:idx_device_rule_short_url_platform;size:36;not null" json:"short_url_id"`
+	Platform   string    `gorm:"uniqueIndex:idx_device_rule_short_url_platform;size:16;not null" json:"platform"` // ios, android, windows, macos or linux
+	URL        string    `gorm:"not null;size:2048" json:"url"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (DeviceRule) TableName() string {
+	return "device_rules"
+}
+
+// BeforeCreate hook to generate UUID
+func (r *DeviceRule) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// GeoRule sends visitors from one country to an alternative destination of a short URL
+type GeoRule struct {
+	ID          string    `gorm:"primaryKey;size:36" json:"id"`
+	ShortURLID  string    `gorm:"uniqueIndex:idx_geo_rule_short_url_country;size:36;not null" json:"short_url_id"`
+	CountryCode string    `gorm:"uniqueIndex:idx_geo_rule_short_url_country;size:2;not null" json:"country_code"` // ISO 3166-1 alpha-2, uppercase
+	URL         string    `gorm:"not null;size:2048" json:"url"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (GeoRule) TableName() string {
+	return "geo_rules"
+}
+
+// BeforeCreate hook to generate UUID
+func (r *GeoRule) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// IdempotencyKey remembers a request made with an Idempotency-Key header, so a retry with the
+// same key gets the original response instead of being processed again
+// Keys are scoped to the user, or to the client IP for anonymous requests, and to the endpoint
+type IdempotencyKey struct {
+	ID           string    `gorm:"primaryKey;size:36" json:"id"`
+	Key          string    `gorm:"column:idempotency_key;uniqueIndex:idx_idempotency_key;size:255;not null" json:"key"`
+	Identifier   string    `gorm:"uniqueIndex:idx_idempotency_key;size:255;not null" json:"identifier"` // IP address or user_id
+	Type         string    `gorm:"uniqueIndex:idx_idempotency_key;size:20;not null" json:"type"`        // "ip" or "user"
+	Endpoint     string    `gorm:"uniqueIndex:idx_idempotency_key;size:255;not null" json:"endpoint"`   // Route the key was used on
+	RequestHash  string    `gorm:"size:64;not null" json:"request_hash"`                                // SHA-256 of the request body
+	StatusCode   int       `gorm:"default:0;not null" json:"status_code"`                               // 0 while the original request is still being processed
+	ContentType  string    `gorm:"size:255" json:"content_type"`
+	ResponseBody string    `gorm:"type:text" json:"response_body"`
+	ExpiresAt    time.Time `gorm:"index;not null" json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
+
+// BeforeCreate hook to generate UUID
+func (k *IdempotencyKey) BeforeCreate(tx *gorm.DB) error {
+	if k.ID == "" {
+		k.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Import job statuses
+const (
+	ImportJobPending   = "pending"
+	ImportJobRunning   = "running"
+	ImportJobCompleted = "completed"
+	ImportJobFailed    = "failed"
+)
+
+// What an import did, or would do in a dry run, with one record
+const (
+	ImportActionCreate    = "create"
+	ImportActionOverwrite = "overwrite"
+	ImportActionSkip      = "skip"
+	ImportActionRename    = "rename"
+	ImportActionFail      = "fail"
+)
+
+// ImportResult is the outcome of one imported record
+type ImportResult struct {
+	Record  int    `json:"record"` // Position in the file, counting from 1
+	Action  string `json:"action"`
+	Domain  string `json:"domain,omitempty"`
+	Slug    string `json:"slug,omitempty"`     // Slug the link was stored under
+	OldSlug string `json:"old_slug,omitempty"` // Slug in the file when the link was renamed
+	Error   string `json:"error,omitempty"`
+}
+
+// ImportResults is the list of record outcomes of an import, stored as a JSON column
+type ImportResults []ImportResult
+
+// Value implements driver.Valuer; an empty list is stored as NULL
+func (r ImportResults) Value() (driver.Value, error) {
+	if len(r) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner
+func (r *ImportResults) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*r = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported type for import results: %T", value)
+	}
+	if len(data) == 0 {
+		*r = nil
+		return nil
+	}
+	return json.Unmarshal(data, r)
+}
+
+// ImportReport counts what an import did with its records
+// Results lists record outcomes in file order, up to a limit; the counts always cover every record
+type ImportReport struct {
+	Total       int           `json:"total"`
+	Processed   int           `json:"processed"`
+	Created     int           `json:"created"`
+	Overwritten int           `json:"overwritten"`
+	Skipped     int           `json:"skipped"`
+	Renamed     int           `json:"renamed"`
+	Failed      int           `json:"failed"`
+	Results     ImportResults `gorm:"type:text" json:"results"`
+}
+
+// ImportJob tracks one import of a file of links
+// Large files are imported in the background, and the job's report is updated as they go
+type ImportJob struct {
+	ID         string       `gorm:"primaryKey;size:36" json:"id"`
+	UserID     string       `gorm:"index;size:255;not null" json:"user_id"`
+	Format     string       `gorm:"size:32" json:"format"`
+	Conflict   string       `gorm:"size:16" json:"conflict"`
+	DryRun     bool         `gorm:"not null;default:false" json:"dry_run"`
+	Status     string       `gorm:"size:16;not null" json:"status"`
+	Error      string       `gorm:"size:512" json:"error,omitempty"` // Why a failed job stopped
+	Report     ImportReport `gorm:"embedded" json:"report"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+}
+
+// TableName specifies the table name for GORM
+func (ImportJob) TableName() string {
+	return "import_jobs"
+}
+
+// BeforeCreate hook to generate UUID
+func (j *ImportJob) BeforeCreate(tx *gorm.DB) error {
+	if j.ID == "" {
+		j.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Link health statuses recorded by the link health checker
+const (
+	LinkHealthUnchecked = ""
+	LinkHealthHealthy   = "healthy"
+	LinkHealthBroken    = "broken"
+)
+
+// LinkHealth is the result of the last background check of a short URL's destination
+// It is written only by the link health checker, never through the API
+type LinkHealth struct {
+	Status     string     `gorm:"column:status;index;size:16" json:"status"`           // "", "healthy" or "broken"
+	StatusCode int        `gorm:"column:status_code" json:"status_code,omitempty"`     // HTTP status of the last check; 0 if the request failed
+	LatencyMS  int        `gorm:"column:latency_ms" json:"latency_ms,omitempty"`       // Time until the response headers arrived
+	CheckedAt  *time.Time `gorm:"column:checked_at;index" json:"checked_at,omitempty"` // When the destination was last checked
+	Error      string     `gorm:"column:error;size:512" json:"error,omitempty"`        // Why the last request failed, if it did
+	Failures   int        `gorm:"column:failures;not null;default:0" json:"failures"`  // Consecutive failed checks; the link is broken once they reach the threshold
+}
+
+// IsBroken reports whether enough consecutive checks found the destination down
+func (h LinkHealth) IsBroken() bool {
+	return h.Status == LinkHealthBroken
+}
@@ -7,16 +7,18 @@ import (
 // Namespace represents a namespace for organizing short URLs
 // Namespaces enable URL patterns like domain.com/namespace/slug
 type Namespace struct {
-	ID        string    `gorm:"primaryKey;size:36" json:"id"`
-	Name      string    `gorm:"uniqueIndex:idx_domain_name;size:255;not null" json:"name"`
-	Domain    string    `gorm:"uniqueIndex:idx_domain_name;size:255;not null" json:"domain"`
-	UserID    string    `gorm:"index;size:255;not null" json:"user_id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID     string `gorm:"primaryKey;size:36" json:"id"`
+	Name   string `gorm:"uniqueIndex:idx_domain_name;size:255;not null" json:"name"`
+	Domain string `gorm:"uniqueIndex:idx_domain_name;size:255;not null" json:"domain"`
+	UserID string `gorm:"index;size:255;not null" json:"user_id"`
+	UTMDefaults
+	NotFound       NotFoundFallback `gorm:"embedded;embeddedPrefix:not_found_" json:"not_found"`
+	SlugGeneration SlugGeneration   `gorm:"embedded;embeddedPrefix:slug_" json:"slug_generation"` // How slugs are generated for links in this namespace
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
 }
 
 // TableName specifies the table name for GORM
 func (Namespace) TableName() string {
 	return "namespaces"
 }
-
@@ -0,0 +1,17 @@
+package models
+
+// Not-found fallback actions
+const (
+	NotFoundActionLanding  = "landing"  // Serve the landing page
+	NotFoundActionRedirect = "redirect" // Redirect to URL
+	NotFoundActionHTML     = "html"     // Render HTML as a 404 page
+	NotFoundActionJSON     = "json"     // Return the JSON error
+)
+
+// NotFoundFallback decides what visitors see when a slug doesn't exist
+// An empty Action inherits the domain's fallback
+type NotFoundFallback struct {
+	Action string `gorm:"column:action;size:16" json:"action,omitempty"`
+	URL    string `gorm:"column:url;size:2048" json:"url,omitempty"`   // Target for the redirect action
+	HTML   string `gorm:"column:html;type:text" json:"html,omitempty"` // Static HTML for the html action; {{domain}}, {{namespace}} and {{slug}} are filled in
+}
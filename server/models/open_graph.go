@@ -0,0 +1,14 @@
+package models
+
+// OpenGraph holds the link preview shown when a short URL is shared
+// Link preview crawlers get these as og: meta tags instead of following the redirect
+type OpenGraph struct {
+	Title       string `gorm:"column:title;size:300" json:"title,omitempty"`
+	Description string `gorm:"column:description;size:1000" json:"description,omitempty"`
+	ImageURL    string `gorm:"column:image_url;size:2048" json:"image_url,omitempty"`
+}
+
+// IsEmpty reports whether no preview field is set
+func (o OpenGraph) IsEmpty() bool {
+	return o.Title == "" && o.Description == "" && o.ImageURL == ""
+}
@@ -2,21 +2,71 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
+
+	"openshortpath/server/utils"
 )
 
 // ShortURL represents a shortened URL entry in the database
 type ShortURL struct {
-	ID          string    `gorm:"primaryKey;size:36" json:"id"`
-	Domain      string    `gorm:"uniqueIndex:idx_domain_slug;size:255" json:"domain"`
-	Slug        string    `gorm:"uniqueIndex:idx_domain_slug;size:255" json:"slug"`
-	URL         string    `gorm:"not null;size:2048" json:"url"`
-	UserID      string    `gorm:"size:255" json:"user_id"`
-	NamespaceID *string   `gorm:"index;size:36" json:"namespace_id,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID                 string            `gorm:"primaryKey;size:36" json:"id"`
+	Domain             string            `gorm:"uniqueIndex:idx_domain_slug;size:255" json:"domain"`
+	Slug               string            `gorm:"uniqueIndex:idx_domain_slug;size:255" json:"slug"`
+	URL                string            `gorm:"not null;size:2048" json:"url"`
+	URLHash            string            `gorm:"index:idx_short_urls_dedup,priority:2;size:64" json:"-"` // Hash of the normalized URL, for finding links to the same destination
+	UserID             string            `gorm:"index:idx_short_urls_dedup,priority:1;size:255" json:"user_id"`
+	NamespaceID        *string           `gorm:"index;size:36" json:"namespace_id,omitempty"`
+	RedirectType       int               `json:"redirect_type"` // 301, 302, 307 or 308; 0 uses the configured default
+	ExpiresAt          *time.Time        `gorm:"index" json:"expires_at,omitempty"`
+	MaxClicks          *int              `json:"max_clicks,omitempty"`
+	ClickCount         int               `gorm:"not null;default:0" json:"click_count"`
+	PasswordHash       *string           `gorm:"size:255" json:"-"` // argon2id hash; never serialized
+	HasPassword        bool              `gorm:"-" json:"has_password"`
+	Destinations       SplitDestinations `gorm:"type:text" json:"destinations,omitempty"`           // Weighted A/B variants; when set they replace URL as the target
+	StickyDestinations bool              `gorm:"not null;default:false" json:"sticky_destinations"` // Remember the chosen variant in a cookie
+	ForwardQuery       bool              `gorm:"not null;default:false" json:"forward_query"`       // Merge the visitor's query string into the destination
+	ForwardPath        bool              `gorm:"not null;default:false" json:"forward_path"`        // Append the rest of the visited path (/slug/rest) to the destination
+	UTMDefaults
+	DeepLink          DeepLink   `gorm:"embedded;embeddedPrefix:deep_link_" json:"deep_link"`
+	OpenGraph         OpenGraph  `gorm:"embedded;embeddedPrefix:og_" json:"open_graph"`
+	Health            LinkHealth `gorm:"embedded;embeddedPrefix:health_" json:"health"`
+	HealthFallbackURL string     `gorm:"size:2048" json:"health_fallback_url,omitempty"` // Served instead of URL while the health checker finds it broken
+	Tags              []string   `gorm:"-" json:"tags,omitempty"`                        // Names of the link's tags, loaded from short_url_tags
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
 }
 
 // TableName specifies the table name for GORM
 func (ShortURL) TableName() string {
 	return "short_urls"
 }
+
+// BeforeCreate stores the hash of the destination URL
+func (s *ShortURL) BeforeCreate(tx *gorm.DB) error {
+	s.URLHash = utils.URLHash(s.URL)
+	return nil
+}
+
+// AfterFind sets HasPassword from the stored password hash
+func (s *ShortURL) AfterFind(tx *gorm.DB) error {
+	s.HasPassword = s.PasswordHash != nil && *s.PasswordHash != ""
+	return nil
+}
+
+// AfterSave sets HasPassword from the stored password hash
+func (s *ShortURL) AfterSave(tx *gorm.DB) error {
+	s.HasPassword = s.PasswordHash != nil && *s.PasswordHash != ""
+	return nil
+}
+
+// IsExpired reports whether the short URL has passed its expiry date or used up its click allowance
+func (s *ShortURL) IsExpired(now time.Time) bool {
+	if s.ExpiresAt != nil && !now.Before(*s.ExpiresAt) {
+		return true
+	}
+	if s.MaxClicks != nil && s.ClickCount >= *s.MaxClicks {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShortURL_IsExpired(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+	two := 2
+
+	tests := []struct {
+		name     string
+		shortURL ShortURL
+		expected bool
+	}{
+		{"no limits", ShortURL{}, false},
+		{"expiry in future", ShortURL{ExpiresAt: &future}, false},
+		{"expiry in past", ShortURL{ExpiresAt: &past}, true},
+		{"expiry exactly now", ShortURL{ExpiresAt: &now}, true},
+		{"clicks remaining", ShortURL{MaxClicks: &two, ClickCount: 1}, false},
+		{"clicks used up", ShortURL{MaxClicks: &two, ClickCount: 2}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.shortURL.IsExpired(now))
+		})
+	}
+}
+
+func TestShortURL_TableName(t *testing.T) {
+	var s ShortURL
+	assert.Equal(t, "short_urls", s.TableName())
+}
@@ -0,0 +1,27 @@
+package models
+
+// Slug generation strategies
+const (
+	SlugStrategyRandom        = "random"        // Random characters from an alphabet
+	SlugStrategySequential    = "sequential"    // Base62 encoding of a per-domain counter
+	SlugStrategyPronounceable = "pronounceable" // Alternating consonants and vowels, e.g. "bakoruti"
+)
+
+// SlugGeneration chooses how slugs are generated for links created without one
+// An empty strategy inherits the deployment's setting; zero values use the strategy's defaults
+type SlugGeneration struct {
+	Strategy string `gorm:"column:strategy;size:16" json:"strategy,omitempty"`
+	Length   int    `gorm:"column:length" json:"length,omitempty"`              // Slug length; the minimum length for sequential slugs
+	Alphabet string `gorm:"column:alphabet;size:128" json:"alphabet,omitempty"` // Characters used by random slugs
+}
+
+// SlugCounter is the counter behind sequential slugs
+type SlugCounter struct {
+	Name  string `gorm:"primaryKey;size:255"`
+	Value int64  `gorm:"not null;default:0"`
+}
+
+// TableName specifies the table name for GORM
+func (SlugCounter) TableName() string {
+	return "slug_counters"
+}
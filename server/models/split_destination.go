@@ -0,0 +1,69 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// SplitDestination is one weighted variant of an A/B split short URL
+type SplitDestination struct {
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// SplitDestinations is the list of weighted variants of a short URL, stored as a JSON column
+type SplitDestinations []SplitDestination
+
+// Value implements driver.Valuer; an empty list is stored as NULL
+func (d SplitDestinations) Value() (driver.Value, error) {
+	if len(d) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner
+func (d *SplitDestinations) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*d = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported type for split destinations: %T", value)
+	}
+	if len(data) == 0 {
+		*d = nil
+		return nil
+	}
+	return json.Unmarshal(data, d)
+}
+
+// TotalWeight returns the sum of all variant weights
+func (d SplitDestinations) TotalWeight() int {
+	total := 0
+	for _, destination := range d {
+		total += destination.Weight
+	}
+	return total
+}
+
+// Choose returns the index of the variant that owns position n, where 0 <= n < TotalWeight()
+func (d SplitDestinations) Choose(n int) int {
+	for i, destination := range d {
+		if n < destination.Weight {
+			return i
+		}
+		n -= destination.Weight
+	}
+	return len(d) - 1
+}
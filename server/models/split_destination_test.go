@@ -0,0 +1,42 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitDestinations_Choose(t *testing.T) {
+	destinations := SplitDestinations{
+		{URL: "https://example.com/a", Weight: 70},
+		{URL: "https://example.com/b", Weight: 30},
+	}
+
+	assert.Equal(t, 100, destinations.TotalWeight())
+	assert.Equal(t, 0, destinations.Choose(0))
+	assert.Equal(t, 0, destinations.Choose(69))
+	assert.Equal(t, 1, destinations.Choose(70))
+	assert.Equal(t, 1, destinations.Choose(99))
+}
+
+func TestSplitDestinations_ValueAndScan(t *testing.T) {
+	destinations := SplitDestinations{
+		{URL: "https://example.com/a", Weight: 2},
+		{URL: "https://example.com/b", Weight: 1},
+	}
+
+	value, err := destinations.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"url":"https://example.com/a","weight":2},{"url":"https://example.com/b","weight":1}]`, value)
+
+	var scanned SplitDestinations
+	assert.NoError(t, scanned.Scan([]byte(value.(string))))
+	assert.Equal(t, destinations, scanned)
+
+	empty, err := SplitDestinations(nil).Value()
+	assert.NoError(t, err)
+	assert.Nil(t, empty)
+
+	assert.NoError(t, scanned.Scan(nil))
+	assert.Nil(t, scanned)
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Tag is a label a user puts on their short URLs to group and filter them
+type Tag struct {
+	ID        string    `gorm:"primaryKey;size:36" json:"id"`
+	UserID    string    `gorm:"uniqueIndex:idx_tag_user_name;size:255;not null" json:"user_id"`
+	Name      string    `gorm:"uniqueIndex:idx_tag_user_name;size:64;not null" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (Tag) TableName() string {
+	return "tags"
+}
+
+// BeforeCreate hook to generate UUID
+func (t *Tag) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// ShortURLTag puts a tag on a short URL
+type ShortURLTag struct {
+	ShortURLID string    `gorm:"primaryKey;size:36" json:"short_url_id"`
+	TagID      string    `gorm:"primaryKey;index;size:36" json:"tag_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (ShortURLTag) TableName() string {
+	return "short_url_tags"
+}
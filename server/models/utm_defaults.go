@@ -0,0 +1,24 @@
+package models
+
+// UTMDefaults holds UTM parameters added to a destination that doesn't already carry them
+// It is embedded in ShortURL and Namespace; a link's own value wins over its namespace's
+type UTMDefaults struct {
+	UTMSource   string `gorm:"size:255" json:"utm_source,omitempty"`
+	UTMMedium   string `gorm:"size:255" json:"utm_medium,omitempty"`
+	UTMCampaign string `gorm:"size:255" json:"utm_campaign,omitempty"`
+}
+
+// Params returns the non-empty defaults keyed by query parameter name
+func (u UTMDefaults) Params() map[string]string {
+	params := make(map[string]string)
+	if u.UTMSource != "" {
+		params["utm_source"] = u.UTMSource
+	}
+	if u.UTMMedium != "" {
+		params["utm_medium"] = u.UTMMedium
+	}
+	if u.UTMCampaign != "" {
+		params["utm_campaign"] = u.UTMCampaign
+	}
+	return params
+}
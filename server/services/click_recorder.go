@@ -0,0 +1,143 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"openshortpath/server/models"
+)
+
+// Default settings for the click recorder
+const (
+	DefaultClickBufferSize    = 10000
+	DefaultClickBatchSize     = 100
+	DefaultClickFlushInterval = 2 * time.Second
+)
+
+// ClickRecorder buffers click events in memory and writes them to the database
+// in batches from a background goroutine, so the redirect path never waits on the database
+type ClickRecorder struct {
+	db            *gorm.DB
+	events        chan models.ClickEvent
+	batchSize     int
+	flushInterval time.Duration
+	done          chan struct{}
+	closeOnce     sync.Once
+
+	// mu guards closed, so no event is sent on the channel after Close has closed it
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewClickRecorder creates a click recorder and starts its background writer
+// Zero or negative values fall back to the defaults
+func NewClickRecorder(db *gorm.DB, bufferSize int, batchSize int, flushInterval time.Duration) *ClickRecorder {
+	if bufferSize <= 0 {
+		bufferSize = DefaultClickBufferSize
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultClickBatchSize
+	}
+	if flushInterval <= 0 {
+		flushInterval = DefaultClickFlushInterval
+	}
+
+	r := &ClickRecorder{
+		db:            db,
+		events:        make(chan models.ClickEvent, bufferSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		done:          make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Record queues a click event for writing
+// It never blocks: if the buffer is full or the recorder is closed the event is dropped and false is returned
+func (r *ClickRecorder) Record(event models.ClickEvent) bool {
+	if event.ClickedAt.IsZero() {
+		event.ClickedAt = time.Now().UTC()
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return false
+	}
+	select {
+	case r.events <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close stops accepting events, flushes everything still buffered and waits for the writer to exit
+func (r *ClickRecorder) Close() {
+	r.closeOnce.Do(func() {
+		r.mu.Lock()
+		r.closed = true
+		close(r.events)
+		r.mu.Unlock()
+		<-r.done
+	})
+}
+
+// run is the background writer loop
+func (r *ClickRecorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.ClickEvent, 0, r.batchSize)
+	for {
+		select {
+		case event, ok := <-r.events:
+			if !ok {
+				r.flush(batch)
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= r.batchSize {
+				r.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				r.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+// flush writes a batch of click events to the database
+func (r *ClickRecorder) flush(batch []models.ClickEvent) {
+	if len(batch) == 0 {
+		return
+	}
+	if err := r.db.CreateInBatches(batch, r.batchSize).Error; err != nil {
+		log.Printf("Failed to write %d click events: %v", len(batch), err)
+	}
+
+	// Roll the batch up into per-link click_count increments
+	// Events already counted on the redirect path (links with max_clicks) are skipped
+	counts := make(map[string]int)
+	for _, event := range batch {
+		if !event.Counted {
+			counts[event.ShortURLID]++
+		}
+	}
+	for shortURLID, count := range counts {
+		if err := r.db.Model(&models.ShortURL{}).
+			Where("id = ?", shortURLID).
+			UpdateColumn("click_count", gorm.Expr("click_count + ?", count)).Error; err != nil {
+			log.Printf("Failed to update click count for short URL %s: %v", shortURLID, err)
+		}
+	}
+}
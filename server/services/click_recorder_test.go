@@ -0,0 +1,155 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"openshortpath/server/models"
+)
+
+func TestClickRecorder_FlushesOnClose(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	// Both events should be written in a single batch insert
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "click_events"`).
+		WillReturnResult(sqlmock.NewResult(2, 2))
+	mock.ExpectCommit()
+
+	// followed by a single click_count increment for the link
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "short_urls" SET "click_count"=click_count \+ \$1 WHERE id = \$2`).
+		WithArgs(2, "url-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	recorder := NewClickRecorder(db, 10, 10, time.Hour)
+	assert.True(t, recorder.Record(models.ClickEvent{ShortURLID: "url-1", IPAddress: "10.0.0.1"}))
+	assert.True(t, recorder.Record(models.ClickEvent{ShortURLID: "url-1", IPAddress: "10.0.0.2"}))
+	recorder.Close()
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClickRecorder_FlushesWhenBatchFull(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	// Already-counted events are inserted but don't bump click_count again
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "click_events"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	recorder := NewClickRecorder(db, 10, 1, time.Hour)
+	recorder.Record(models.ClickEvent{ShortURLID: "url-1", Counted: true})
+
+	assert.Eventually(t, func() bool {
+		return mock.ExpectationsWereMet() == nil
+	}, time.Second, 10*time.Millisecond)
+	recorder.Close()
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClickRecorder_DropsWhenBufferFull(t *testing.T) {
+	db, _, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	// Build the recorder by hand without starting the writer so the buffer stays full
+	recorder := &ClickRecorder{
+		db:     db,
+		events: make(chan models.ClickEvent, 1),
+	}
+
+	assert.True(t, recorder.Record(models.ClickEvent{ShortURLID: "url-1"}))
+	assert.False(t, recorder.Record(models.ClickEvent{ShortURLID: "url-1"}))
+}
+
+func TestClickRecorder_CloseWithNoEvents(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	recorder := NewClickRecorder(db, 0, 0, 0)
+	recorder.Close()
+	// Closing twice must be safe
+	recorder.Close()
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClickRecorder_RecordAfterClose(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	recorder := NewClickRecorder(db, 10, 10, time.Hour)
+	recorder.Close()
+
+	// Late clicks from requests still in flight are dropped instead of panicking
+	assert.False(t, recorder.Record(models.ClickEvent{ShortURLID: "url-1"}))
+	recorder.Close()
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetClickStats_DailyBuckets(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "click_events"`).
+		WithArgs("url-1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+
+	mock.ExpectQuery(`SELECT to_char\(date_trunc\('day', clicked_at AT TIME ZONE 'UTC'\), 'YYYY-MM-DD HH24:MI:SS'\) AS bucket, COUNT\(\*\) AS count FROM "click_events" WHERE (.+) GROUP BY "bucket"`).
+		WithArgs("url-1", from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"bucket", "count"}).
+			AddRow("2024-03-01 00:00:00", 2).
+			AddRow("2024-03-03 00:00:00", 1))
+
+	stats, err := GetClickStats(db, "url-1", ClickIntervalDay, from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), stats.TotalClicks)
+	assert.Len(t, stats.Buckets, 3)
+	assert.Equal(t, int64(2), stats.Buckets[0].Count)
+	assert.Equal(t, int64(0), stats.Buckets[1].Count)
+	assert.Equal(t, int64(1), stats.Buckets[2].Count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetClickStats_HourlyBuckets(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	from := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "click_events"`).
+		WithArgs("url-1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	mock.ExpectQuery(`SELECT to_char\(date_trunc\('hour', (.+) GROUP BY "bucket"`).
+		WithArgs("url-1", from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"bucket", "count"}).
+			AddRow("2024-03-01 11:00:00", 1))
+
+	stats, err := GetClickStats(db, "url-1", ClickIntervalHour, from, to)
+	assert.NoError(t, err)
+	assert.Len(t, stats.Buckets, 3)
+	assert.Equal(t, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), stats.Buckets[0].Start)
+	assert.Equal(t, int64(1), stats.Buckets[1].Count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetClickStats_InvalidInterval(t *testing.T) {
+	db, _, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	_, err := GetClickStats(db, "url-1", "week", time.Now().Add(-time.Hour), time.Now())
+	assert.Error(t, err)
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"openshortpath/server/models"
+)
+
+// Click stats bucket intervals
+const (
+	ClickIntervalHour = "hour"
+	ClickIntervalDay  = "day"
+)
+
+// ClickBucket holds the number of clicks within one time bucket
+type ClickBucket struct {
+	Start time.Time `json:"start"`
+	Count int64     `json:"count"`
+}
+
+// ClickStats contains aggregated click statistics for a short URL
+type ClickStats struct {
+	ShortURLID  string        `json:"short_url_id"`
+	TotalClicks int64         `json:"total_clicks"`
+	Interval    string        `json:"interval"`
+	From        time.Time     `json:"from"`
+	To          time.Time     `json:"to"`
+	Buckets     []ClickBucket `json:"buckets"`
+}
+
+// truncateToInterval truncates a time to the start of its bucket (UTC)
+func truncateToInterval(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	if interval == ClickIntervalHour {
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// nextInterval returns the start of the bucket following the given bucket start
+func nextInterval(t time.Time, interval string) time.Time {
+	if interval == ClickIntervalHour {
+		return t.Add(time.Hour)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// bucketLayout is the format of the bucket starts returned by clickBucketExpression
+const bucketLayout = "2006-01-02 15:04:05"
+
+// clickBucketExpression returns the SQL expression that truncates clicked_at to the start of
+// its bucket (UTC), formatted as bucketLayout
+func clickBucketExpression(db *gorm.DB, interval string) string {
+	if db.Dialector.Name() == "postgres" {
+		return fmt.Sprintf("to_char(date_trunc('%s', clicked_at AT TIME ZONE 'UTC'), 'YYYY-MM-DD HH24:MI:SS')", interval)
+	}
+	if interval == ClickIntervalHour {
+		return "strftime('%Y-%m-%d %H:00:00', clicked_at)"
+	}
+	return "strftime('%Y-%m-%d 00:00:00', clicked_at)"
+}
+
+// GetClickStats returns the all-time click total for a short URL together with
+// time-bucketed counts for clicks in the [from, to) range
+// Clicks are grouped in the database, with date_trunc on Postgres and strftime on SQLite
+func GetClickStats(db *gorm.DB, shortURLID string, interval string, from time.Time, to time.Time) (*ClickStats, error) {
+	if interval != ClickIntervalHour && interval != ClickIntervalDay {
+		return nil, fmt.Errorf("invalid interval: %s (must be 'hour' or 'day')", interval)
+	}
+
+	var total int64
+	if err := db.Model(&models.ClickEvent{}).Where("short_url_id = ?", shortURLID).Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count clicks: %w", err)
+	}
+
+	var rows []struct {
+		Bucket string
+		Count  int64
+	}
+	if err := db.Model(&models.ClickEvent{}).
+		Select(clickBucketExpression(db, interval)+" AS bucket, COUNT(*) AS count").
+		Where("short_url_id = ? AND clicked_at >= ? AND clicked_at < ?", shortURLID, from, to).
+		Group("bucket").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to query clicks: %w", err)
+	}
+
+	counts := make(map[time.Time]int64, len(rows))
+	for _, row := range rows {
+		start, err := time.ParseInLocation(bucketLayout, row.Bucket, time.UTC)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse click bucket %q: %w", row.Bucket, err)
+		}
+		counts[start] += row.Count
+	}
+
+	// Emit every bucket in the range, including empty ones, so clients can chart directly
+	buckets := []ClickBucket{}
+	for start := truncateToInterval(from, interval); start.Before(to); start = nextInterval(start, interval) {
+		buckets = append(buckets, ClickBucket{
+			Start: start,
+			Count: counts[start],
+		})
+	}
+
+	return &ClickStats{
+		ShortURLID:  shortURLID,
+		TotalClicks: total,
+		Interval:    interval,
+		From:        from,
+		To:          to,
+		Buckets:     buckets,
+	}, nil
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// CountryLookup resolves an IP address to an ISO 3166-1 alpha-2 country code
+type CountryLookup interface {
+	// LookupCountry returns the uppercase country code for the IP, or "" if unknown
+	LookupCountry(ip string) string
+}
+
+// GeoIPDatabase resolves countries from a local MaxMind-format (.mmdb) database,
+// such as GeoLite2-Country or GeoIP2-Country
+type GeoIPDatabase struct {
+	reader *maxminddb.Reader
+}
+
+// geoIPRecord is the subset of the MaxMind country record we read
+type geoIPRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+// OpenGeoIPDatabase opens a MaxMind-format database file
+func OpenGeoIPDatabase(path string) (*GeoIPDatabase, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database: %w", err)
+	}
+	return &GeoIPDatabase{reader: reader}, nil
+}
+
+// LookupCountry returns the country code for the IP, falling back to the registered country
+// Returns "" for unparseable, private or unknown addresses
+func (g *GeoIPDatabase) LookupCountry(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+
+	var record geoIPRecord
+	if err := g.reader.Lookup(parsed, &record); err != nil {
+		return ""
+	}
+
+	if record.Country.ISOCode != "" {
+		return strings.ToUpper(record.Country.ISOCode)
+	}
+	return strings.ToUpper(record.RegisteredCountry.ISOCode)
+}
+
+// Close releases the database file
+func (g *GeoIPDatabase) Close() error {
+	return g.reader.Close()
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"openshortpath/server/models"
+)
+
+// DefaultIdempotencyKeyTTL is how long an Idempotency-Key is remembered unless configured otherwise
+const DefaultIdempotencyKeyTTL = 24 * time.Hour
+
+// MaxIdempotencyKeyLength is the longest Idempotency-Key accepted
+const MaxIdempotencyKeyLength = 255
+
+// IdempotencyPendingLease is how long a key stays locked to a request that hasn't finished
+// A key still pending after that, e.g. because the server crashed mid-request, is taken over by the next retry
+const IdempotencyPendingLease = time.Minute
+
+var (
+	// ErrIdempotencyKeyMismatch is returned when a key is reused with a different request
+	ErrIdempotencyKeyMismatch = errors.New("idempotency key was already used with a different request")
+	// ErrIdempotencyKeyInProgress is returned when the first request with a key hasn't finished yet
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still being processed")
+)
+
+// IdempotencyRequestHash hashes a request body to recognize a repeated request
+// JSON bodies are hashed by content, so retries that format or order their fields differently still match
+func IdempotencyRequestHash(body []byte) string {
+	var content interface{}
+	if err := json.Unmarshal(body, &content); err == nil {
+		if canonical, err := json.Marshal(content); err == nil {
+			body = canonical
+		}
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// BeginIdempotentRequest claims key for a request from identifier on endpoint
+// If the key is new (or its earlier use has expired), a pending record is stored and returned with claimed set;
+// the caller must then complete or release it. If the key was already used for the same request and that
+// request finished, its record is returned so the stored response can be replayed. A pending record older
+// than IdempotencyPendingLease is claimed again, so a request that never finished doesn't block retries
+func BeginIdempotentRequest(db *gorm.DB, identifier, limitType, endpoint, key, requestHash string, ttl time.Duration) (*models.IdempotencyKey, bool, error) {
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyKeyTTL
+	}
+	now := time.Now().UTC()
+
+	// Expired keys of this user or IP are removed so they can be used again
+	if err := db.Where("identifier = ? AND type = ? AND expires_at <= ?", identifier, limitType, now).
+		Delete(&models.IdempotencyKey{}).Error; err != nil {
+		return nil, false, err
+	}
+
+	record := &models.IdempotencyKey{
+		Key:         key,
+		Identifier:  identifier,
+		Type:        limitType,
+		Endpoint:    endpoint,
+		RequestHash: requestHash,
+		ExpiresAt:   now.Add(ttl),
+	}
+	createErr := db.Create(record).Error
+	if createErr == nil {
+		return record, true, nil
+	}
+
+	// The key is taken, so look at the request that took it
+	var existing models.IdempotencyKey
+	err := db.Where("idempotency_key = ? AND identifier = ? AND type = ? AND endpoint = ?", key, identifier, limitType, endpoint).
+		First(&existing).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, false, createErr
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if existing.RequestHash != requestHash {
+		return &existing, false, ErrIdempotencyKeyMismatch
+	}
+	if existing.StatusCode == 0 {
+		if existing.CreatedAt.After(now.Add(-IdempotencyPendingLease)) {
+			return &existing, false, ErrIdempotencyKeyInProgress
+		}
+
+		// Only one retry can take over the stale record; it restarts the lease and the TTL
+		result := db.Model(&existing).
+			Where("status_code = ? AND created_at <= ?", 0, now.Add(-IdempotencyPendingLease)).
+			Updates(map[string]interface{}{"created_at": now, "expires_at": now.Add(ttl)})
+		if result.Error != nil {
+			return nil, false, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return &existing, false, ErrIdempotencyKeyInProgress
+		}
+		return &existing, true, nil
+	}
+	return &existing, false, nil
+}
+
+// CompleteIdempotentRequest stores the response to replay for a claimed key
+func CompleteIdempotentRequest(db *gorm.DB, record *models.IdempotencyKey, statusCode int, contentType string, body []byte) error {
+	return db.Model(record).Updates(map[string]interface{}{
+		"status_code":   statusCode,
+		"content_type":  contentType,
+		"response_body": string(body),
+	}).Error
+}
+
+// ReleaseIdempotentRequest forgets a claimed key, so a retry with it is processed again
+func ReleaseIdempotentRequest(db *gorm.DB, record *models.IdempotencyKey) error {
+	return db.Delete(record).Error
+}
@@ -0,0 +1,20 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyRequestHash(t *testing.T) {
+	hash := IdempotencyRequestHash([]byte(`{"url": "https://example.com", "tags": ["a", "b"]}`))
+
+	// JSON bodies match by content
+	assert.Equal(t, hash, IdempotencyRequestHash([]byte(`{"tags":["a","b"],"url":"https://example.com"}`)))
+	assert.NotEqual(t, hash, IdempotencyRequestHash([]byte(`{"url": "https://example.com", "tags": ["b", "a"]}`)))
+
+	// Other bodies match byte for byte
+	assert.Equal(t, IdempotencyRequestHash([]byte("url=a")), IdempotencyRequestHash([]byte("url=a")))
+	assert.NotEqual(t, IdempotencyRequestHash([]byte("url=a")), IdempotencyRequestHash([]byte("url=a ")))
+	assert.Len(t, hash, 64)
+}
@@ -0,0 +1,344 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+
+	"gorm.io/gorm"
+
+	"openshortpath/server/models"
+)
+
+// Default settings for the link health checker
+const (
+	DefaultLinkHealthInterval    = 24 * time.Hour
+	DefaultLinkHealthConcurrency = 4
+	DefaultLinkHealthHostDelay   = time.Second
+	DefaultLinkHealthTimeout     = 10 * time.Second
+	// DefaultLinkHealthFailureThreshold is how many checks in a row must fail before a link is broken
+	DefaultLinkHealthFailureThreshold = 3
+)
+
+const (
+	// linkHealthPollInterval is how often the checker looks for links that are due when it has caught up
+	linkHealthPollInterval = time.Minute
+	// linkHealthBatchSize is the number of due links loaded from the database at a time
+	linkHealthBatchSize = 100
+	// linkHealthMaxErrorLength matches the size of the health_error column
+	linkHealthMaxErrorLength = 512
+	// linkHealthUserAgent identifies the checker's requests to destination servers
+	linkHealthUserAgent = "OpenShortPath-LinkChecker/1.0"
+)
+
+// LinkHealthChecker periodically requests the destination of every short URL and records
+// whether it is reachable. Requests to the same host are spaced out by the host delay
+type LinkHealthChecker struct {
+	db            *gorm.DB
+	client        *http.Client
+	interval      time.Duration
+	concurrency   int
+	hostDelay     time.Duration
+	redirectCache *RedirectCache
+	// failureThreshold is how many checks in a row must fail before a link is marked broken
+	failureThreshold int
+
+	hostMu      sync.Mutex
+	nextRequest map[string]time.Time // Earliest time the next request to each host may start
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	done      chan struct{}
+	startOnce sync.Once
+	closeOnce sync.Once
+}
+
+// NewLinkHealthChecker creates a link health checker; call Start to begin checking in the background
+// Zero or negative values fall back to the defaults. Unless allowPrivate is set, destinations on
+// private, loopback or reserved addresses are never contacted
+func NewLinkHealthChecker(db *gorm.DB, interval time.Duration, concurrency int, hostDelay time.Duration, timeout time.Duration, failureThreshold int, allowPrivate bool) *LinkHealthChecker {
+	if interval <= 0 {
+		interval = DefaultLinkHealthInterval
+	}
+	if concurrency <= 0 {
+		concurrency = DefaultLinkHealthConcurrency
+	}
+	if hostDelay <= 0 {
+		hostDelay = DefaultLinkHealthHostDelay
+	}
+	if timeout <= 0 {
+		timeout = DefaultLinkHealthTimeout
+	}
+	if failureThreshold <= 0 {
+		failureThreshold = DefaultLinkHealthFailureThreshold
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		// Checked after DNS resolution, so redirects and rebinding can't reach internal hosts either
+		dialer.Control = func(network, address string, conn syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip != nil && !isPublicIP(ip) {
+				return fmt.Errorf("destination address %s is not public", ip)
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &LinkHealthChecker{
+		db:               db,
+		client:           &http.Client{Transport: transport, Timeout: timeout},
+		interval:         interval,
+		concurrency:      concurrency,
+		hostDelay:        hostDelay,
+		failureThreshold: failureThreshold,
+		nextRequest:      make(map[string]time.Time),
+		ctx:              ctx,
+		cancel:           cancel,
+		done:             make(chan struct{}),
+	}
+}
+
+// SetRedirectCache sets the redirect cache to invalidate when a link's health status changes
+func (c *LinkHealthChecker) SetRedirectCache(cache *RedirectCache) {
+	c.redirectCache = cache
+}
+
+// Start begins checking due links in a background goroutine
+func (c *LinkHealthChecker) Start() {
+	c.startOnce.Do(func() {
+		go c.run()
+	})
+}
+
+// Close stops the checker and waits for in-flight checks to finish
+func (c *LinkHealthChecker) Close() {
+	c.closeOnce.Do(func() {
+		c.cancel()
+		started := true
+		c.startOnce.Do(func() { started = false })
+		if started {
+			<-c.done
+		}
+	})
+}
+
+// run is the background loop: check everything that is due, then wait for more to become due
+func (c *LinkHealthChecker) run() {
+	defer close(c.done)
+
+	for {
+		checked, err := c.CheckDue()
+		if err != nil {
+			log.Printf("Link health check failed: %v", err)
+		}
+		// Keep going while full batches are due; otherwise wait before looking again
+		if err == nil && checked == linkHealthBatchSize {
+			if c.ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(linkHealthPollInterval):
+		}
+	}
+}
+
+// CheckDue checks one batch of links that were never checked or were last checked
+// more than the interval ago, and returns how many were checked
+func (c *LinkHealthChecker) CheckDue() (int, error) {
+	c.pruneHosts()
+
+	var shortURLs []models.ShortURL
+	if err := c.db.
+		Select("id", "domain", "slug", "url", "health_status", "health_failures").
+		Where("health_checked_at IS NULL OR health_checked_at < ?", time.Now().UTC().Add(-c.interval)).
+		Order("health_checked_at").
+		Limit(linkHealthBatchSize).
+		Find(&shortURLs).Error; err != nil {
+		return 0, err
+	}
+
+	jobs := make(chan *models.ShortURL)
+	errs := make(chan error, len(shortURLs))
+	var wg sync.WaitGroup
+	for i := 0; i < c.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for shortURL := range jobs {
+				if err := c.checkAndRecord(shortURL); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	checked := 0
+	for i := range shortURLs {
+		if c.ctx.Err() != nil {
+			break
+		}
+		jobs <- &shortURLs[i]
+		checked++
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+
+	if err := <-errs; err != nil {
+		return checked, err
+	}
+	return checked, nil
+}
+
+// checkAndRecord checks a link's destination and stores the result
+// A failed check only marks the link broken once failureThreshold checks in a row have failed,
+// so a single timeout or a server restart doesn't divert its visitors to the fallback URL
+func (c *LinkHealthChecker) checkAndRecord(shortURL *models.ShortURL) error {
+	health := c.Check(c.ctx, shortURL.URL)
+	if c.ctx.Err() != nil {
+		// Interrupted by Close; the link stays due
+		return nil
+	}
+
+	if health.Status == models.LinkHealthBroken {
+		health.Failures = shortURL.Health.Failures + 1
+		if health.Failures < c.failureThreshold {
+			health.Status = shortURL.Health.Status
+		}
+	}
+
+	if err := c.db.Model(&models.ShortURL{}).
+		Where("id = ?", shortURL.ID).
+		UpdateColumns(map[string]interface{}{
+			"health_status":      health.Status,
+			"health_status_code": health.StatusCode,
+			"health_latency_ms":  health.LatencyMS,
+			"health_checked_at":  health.CheckedAt,
+			"health_error":       health.Error,
+			"health_failures":    health.Failures,
+		}).Error; err != nil {
+		return fmt.Errorf("failed to save health of short URL %s: %w", shortURL.ID, err)
+	}
+
+	// Redirects read the status to decide on the fallback URL
+	if health.Status != shortURL.Health.Status {
+		c.redirectCache.InvalidateShortURL(shortURL)
+	}
+	return nil
+}
+
+// Check requests destination and returns the health this one request found
+// A HEAD request is tried first; servers that don't support HEAD get a GET instead
+// Failed requests, 404, 410 and 5xx responses count as broken. Other statuses, such as
+// 401, 403 or 429 from servers that turn away bots, show the destination still exists
+func (c *LinkHealthChecker) Check(ctx context.Context, destination string) models.LinkHealth {
+	checkedAt := time.Now().UTC()
+	health := models.LinkHealth{
+		Status:    models.LinkHealthBroken,
+		CheckedAt: &checkedAt,
+	}
+
+	parsed, err := url.Parse(destination)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		health.Error = "destination is not an http or https URL"
+		return health
+	}
+
+	start := time.Now()
+	statusCode, err := c.request(ctx, http.MethodHead, destination, parsed.Hostname())
+	if err == nil && (statusCode == http.StatusMethodNotAllowed || statusCode == http.StatusNotImplemented) {
+		start = time.Now()
+		statusCode, err = c.request(ctx, http.MethodGet, destination, parsed.Hostname())
+	}
+	health.LatencyMS = int(time.Since(start).Milliseconds())
+
+	if err != nil {
+		health.Error = err.Error()
+		if len(health.Error) > linkHealthMaxErrorLength {
+			health.Error = health.Error[:linkHealthMaxErrorLength]
+		}
+		return health
+	}
+
+	health.StatusCode = statusCode
+	if statusCode != http.StatusNotFound && statusCode != http.StatusGone && statusCode < 500 {
+		health.Status = models.LinkHealthHealthy
+	}
+	return health
+}
+
+// request sends one request after waiting for the host's turn and returns the response status
+func (c *LinkHealthChecker) request(ctx context.Context, method, destination, host string) (int, error) {
+	if err := c.waitForHost(ctx, host); err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, destination, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", linkHealthUserAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// waitForHost reserves the next request slot for host and sleeps until it starts
+func (c *LinkHealthChecker) waitForHost(ctx context.Context, host string) error {
+	c.hostMu.Lock()
+	now := time.Now()
+	start := c.nextRequest[host]
+	if start.Before(now) {
+		start = now
+	}
+	c.nextRequest[host] = start.Add(c.hostDelay)
+	c.hostMu.Unlock()
+
+	wait := time.Until(start)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// pruneHosts forgets hosts whose delay has passed so the map doesn't grow without bound
+func (c *LinkHealthChecker) pruneHosts() {
+	c.hostMu.Lock()
+	defer c.hostMu.Unlock()
+
+	now := time.Now()
+	for host, next := range c.nextRequest {
+		if next.Before(now) {
+			delete(c.nextRequest, host)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"openshortpath/server/models"
+)
+
+func TestLinkHealthChecker_Check(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			w.WriteHeader(http.StatusOK)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+		case "/error":
+			w.WriteHeader(http.StatusBadGateway)
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		case "/moved":
+			http.Redirect(w, r, "/missing", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	checker := NewLinkHealthChecker(nil, 0, 0, time.Millisecond, time.Second, 0, true)
+
+	tests := []struct {
+		name           string
+		destination    string
+		expectedStatus string
+		expectedCode   int
+	}{
+		{"reachable", server.URL + "/ok", models.LinkHealthHealthy, http.StatusOK},
+		{"not found", server.URL + "/missing", models.LinkHealthBroken, http.StatusNotFound},
+		{"turns away bots", server.URL + "/forbidden", models.LinkHealthHealthy, http.StatusForbidden},
+		{"server error", server.URL + "/error", models.LinkHealthBroken, http.StatusBadGateway},
+		{"HEAD not allowed", server.URL + "/no-head", models.LinkHealthHealthy, http.StatusOK},
+		{"redirect to missing page", server.URL + "/moved", models.LinkHealthBroken, http.StatusNotFound},
+		{"not a web URL", "mailto:someone@example.com", models.LinkHealthBroken, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			health := checker.Check(context.Background(), tt.destination)
+			assert.Equal(t, tt.expectedStatus, health.Status)
+			assert.Equal(t, tt.expectedCode, health.StatusCode)
+			assert.NotNil(t, health.CheckedAt)
+		})
+	}
+}
+
+func TestLinkHealthChecker_Check_Unreachable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	destination := server.URL + "/gone"
+	server.Close()
+
+	checker := NewLinkHealthChecker(nil, 0, 0, time.Millisecond, time.Second, 0, true)
+	health := checker.Check(context.Background(), destination)
+
+	assert.Equal(t, models.LinkHealthBroken, health.Status)
+	assert.Equal(t, 0, health.StatusCode)
+	assert.NotEmpty(t, health.Error)
+}
+
+func TestLinkHealthChecker_Check_RefusesPrivateAddresses(t *testing.T) {
+	requested := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+	}))
+	defer server.Close()
+
+	// The test server listens on loopback, which the default checker must not contact
+	checker := NewLinkHealthChecker(nil, 0, 0, time.Millisecond, time.Second, 0, false)
+	health := checker.Check(context.Background(), server.URL)
+
+	assert.Equal(t, models.LinkHealthBroken, health.Status)
+	assert.Contains(t, health.Error, "not public")
+	assert.False(t, requested)
+}
+
+func TestLinkHealthChecker_SpacesRequestsToSameHost(t *testing.T) {
+	var mu sync.Mutex
+	var times []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	hostDelay := 50 * time.Millisecond
+	checker := NewLinkHealthChecker(nil, 0, 0, hostDelay, time.Second, 0, true)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checker.Check(context.Background(), server.URL)
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, times, 3)
+	first, last := times[0], times[0]
+	for _, requestedAt := range times {
+		if requestedAt.Before(first) {
+			first = requestedAt
+		}
+		if requestedAt.After(last) {
+			last = requestedAt
+		}
+	}
+	// Three requests need at least two delays between them
+	assert.GreaterOrEqual(t, last.Sub(first), 2*hostDelay-5*time.Millisecond)
+}
+
+func TestLinkHealthChecker_CheckDue(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	mock.ExpectQuery(`SELECT "id","domain","slug","url","health_status","health_failures" FROM "short_urls" WHERE health_checked_at IS NULL OR health_checked_at < \$1 ORDER BY health_checked_at LIMIT 100`).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug", "url", "health_status", "health_failures"}).
+			AddRow("url-1", "example.com", "abc123", server.URL+"/old", models.LinkHealthHealthy, 0))
+
+	// Columns are written without touching updated_at
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "short_urls" SET "health_checked_at"=\$1,"health_error"=\$2,"health_failures"=\$3,"health_latency_ms"=\$4,"health_status"=\$5,"health_status_code"=\$6 WHERE id = \$7`).
+		WithArgs(sqlmock.AnyArg(), "", 1, sqlmock.AnyArg(), models.LinkHealthBroken, http.StatusNotFound, "url-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	cache := NewRedirectCache(10, time.Minute)
+	key := RedirectCacheKey{Host: "example.com", Slug: "abc123"}
+	cache.Set(key, RedirectCacheEntry{ShortURL: &models.ShortURL{ID: "url-1", Domain: "example.com", Slug: "abc123"}})
+
+	checker := NewLinkHealthChecker(db, time.Hour, 2, time.Millisecond, time.Second, 1, true)
+	checker.SetRedirectCache(cache)
+
+	checked, err := checker.CheckDue()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, checked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// The status changed, so redirects must stop using the cached link
+	_, ok := cache.Get(key)
+	assert.False(t, ok)
+}
+
+func TestLinkHealthChecker_CheckAndRecord_Flapping(t *testing.T) {
+	// The destination fails twice, recovers, then fails three times in a row
+	responses := []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusOK, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.WriteHeader(responses[0])
+		responses = responses[1:]
+	}))
+	defer server.Close()
+
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	checker := NewLinkHealthChecker(db, time.Hour, 1, time.Millisecond, time.Second, 3, true)
+	shortURL := &models.ShortURL{ID: "url-1", Domain: "example.com", Slug: "abc123", URL: server.URL,
+		Health: models.LinkHealth{Status: models.LinkHealthHealthy}}
+
+	// Only the third failure in a row marks the link broken; a successful check starts the count over
+	expected := []struct {
+		status   string
+		failures int
+	}{
+		{models.LinkHealthHealthy, 1},
+		{models.LinkHealthHealthy, 2},
+		{models.LinkHealthHealthy, 0},
+		{models.LinkHealthHealthy, 1},
+		{models.LinkHealthHealthy, 2},
+		{models.LinkHealthBroken, 3},
+	}
+	for _, want := range expected {
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "short_urls" SET`).
+			WithArgs(sqlmock.AnyArg(), "", want.failures, sqlmock.AnyArg(), want.status, sqlmock.AnyArg(), "url-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, checker.checkAndRecord(shortURL))
+		shortURL.Health = models.LinkHealth{Status: want.status, Failures: want.failures}
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLinkHealthChecker_CloseWithoutStart(t *testing.T) {
+	checker := NewLinkHealthChecker(nil, 0, 0, 0, 0, 0, false)
+	checker.Close()
+	checker.Start()
+	checker.Close()
+}
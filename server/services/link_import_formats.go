@@ -0,0 +1,662 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Import formats of other URL shorteners
+// Their records carry no domain of ours, so they are imported onto a chosen domain
+const (
+	LinkFormatYOURLSSQL = "yourls-sql" // SQL dump of the YOURLS url table, as written by mysqldump or phpMyAdmin
+	LinkFormatYOURLSCSV = "yourls-csv" // CSV export of YOURLS links (keyword, url, title, timestamp, ip, clicks)
+	LinkFormatBitlyCSV  = "bitly-csv"  // CSV export of links from Bitly
+	LinkFormatKuttJSON  = "kutt-json"  // JSON dump of Kutt links, either an array or a {"data": [...]} page from its API
+)
+
+// LinkImportFormats are the formats NewLinkImportReader accepts
+var LinkImportFormats = []string{
+	LinkFormatCSV, LinkFormatNDJSON, LinkFormatYOURLSSQL, LinkFormatYOURLSCSV, LinkFormatBitlyCSV, LinkFormatKuttJSON,
+}
+
+// IsForeignLinkFormat checks if format is the export format of another URL shortener
+func IsForeignLinkFormat(format string) bool {
+	switch format {
+	case LinkFormatYOURLSSQL, LinkFormatYOURLSCSV, LinkFormatBitlyCSV, LinkFormatKuttJSON:
+		return true
+	}
+	return false
+}
+
+// yourlsColumns are the columns of the YOURLS url table, in order, for INSERT statements without a column list
+var yourlsColumns = []string{"keyword", "url", "title", "timestamp", "ip", "clicks"}
+
+// yourlsTimestampLayout is how YOURLS stores creation times; they are read as UTC
+const yourlsTimestampLayout = "2006-01-02 15:04:05"
+
+// yourlsRecord maps a row of the YOURLS url table to a short URL
+func yourlsRecord(number int, field func(name string) string) *ImportRecord {
+	record := &ImportRecord{Number: number}
+	record.ShortURL.Slug = field("keyword")
+	record.ShortURL.URL = field("url")
+
+	if value := field("timestamp"); value != "" {
+		createdAt, err := time.ParseInLocation(yourlsTimestampLayout, value, time.UTC)
+		if err != nil {
+			record.Err = fmt.Errorf("invalid timestamp '%s'", value)
+			return record
+		}
+		record.ShortURL.CreatedAt = createdAt
+	}
+	if value := field("clicks"); value != "" {
+		clicks, err := strconv.Atoi(value)
+		if err != nil {
+			record.Err = fmt.Errorf("invalid clicks '%s'", value)
+			return record
+		}
+		record.ShortURL.ClickCount = clicks
+	}
+	return record
+}
+
+// bitlyTimeLayouts are the creation time formats found in Bitly exports
+var bitlyTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"1/2/2006 15:04",
+	"1/2/2006",
+}
+
+// bitlyRecord maps a row of a Bitly export to a short URL
+// The slug is the back-half of the custom link if the link has one, otherwise of the bitlink itself
+func bitlyRecord(number int, field func(name string) string) *ImportRecord {
+	record := &ImportRecord{Number: number}
+	record.ShortURL.URL = firstField(field, "long_url", "destination_url", "url")
+
+	link := firstField(field, "custom_link", "custom_bitlink", "custom_bitlinks", "custom_backhalf")
+	if link == "" {
+		link = firstField(field, "link", "bitlink", "short_link", "short_url")
+	}
+	// Several custom links are separated by spaces or commas; the first one is kept
+	if fields := strings.FieldsFunc(link, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }); len(fields) > 0 {
+		link = fields[0]
+	}
+	record.ShortURL.Slug = slugFromShortLink(link)
+
+	if value := firstField(field, "created", "created_at", "date_created", "creation_date"); value != "" {
+		createdAt, ok := parseTimeLayouts(value, bitlyTimeLayouts)
+		if !ok {
+			record.Err = fmt.Errorf("invalid created time '%s'", value)
+			return record
+		}
+		record.ShortURL.CreatedAt = createdAt
+	}
+	if value := firstField(field, "clicks", "total_clicks", "engagements"); value != "" {
+		clicks, err := strconv.Atoi(strings.ReplaceAll(value, ",", ""))
+		if err != nil {
+			record.Err = fmt.Errorf("invalid clicks '%s'", value)
+			return record
+		}
+		record.ShortURL.ClickCount = clicks
+	}
+	return record
+}
+
+// slugFromShortLink returns the path of a short link such as bit.ly/abc or https://bit.ly/abc,
+// or the value itself if it is a bare back-half
+func slugFromShortLink(link string) string {
+	if link == "" {
+		return ""
+	}
+	if !strings.Contains(link, "://") {
+		if !strings.Contains(link, "/") {
+			return link
+		}
+		link = "https://" + link
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	return strings.Trim(parsed.Path, "/")
+}
+
+// firstField returns the first non-empty value of the named columns
+func firstField(field func(name string) string, names ...string) string {
+	for _, name := range names {
+		if value := field(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func parseTimeLayouts(value string, layouts []string) (time.Time, bool) {
+	for _, layout := range layouts {
+		if parsed, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// foreignCSVReader reads a CSV export of another shortener, mapping each row with build
+type foreignCSVReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+	number  int
+	build   func(number int, field func(name string) string) *ImportRecord
+}
+
+// newForeignCSVReader reads the header row and checks that one of each group of columns is present
+func newForeignCSVReader(r io.Reader, build func(int, func(string) string) *ImportRecord, required ...[]string) (*foreignCSVReader, error) {
+	reader, columns, err := readCSVHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, names := range required {
+		found := false
+		for _, name := range names {
+			if _, ok := columns[name]; ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("the header row has no %s column", strings.Join(names, " or "))
+		}
+	}
+	return &foreignCSVReader{reader: reader, columns: columns, build: build}, nil
+}
+
+func (r *foreignCSVReader) Next() (*ImportRecord, error) {
+	row, err := r.reader.Read()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	r.number++
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return &ImportRecord{Number: r.number, Err: err}, nil
+		}
+		return nil, err
+	}
+
+	return r.build(r.number, func(name string) string {
+		i, ok := r.columns[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}), nil
+}
+
+// kuttLink is a link as the Kutt API returns it
+type kuttLink struct {
+	Address    string     `json:"address"`
+	Target     string     `json:"target"`
+	Password   bool       `json:"password"`
+	VisitCount int        `json:"visit_count"`
+	ExpireIn   *time.Time `json:"expire_in"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// kuttLinkReader reads the links of a Kutt JSON dump one at a time
+type kuttLinkReader struct {
+	decoder *json.Decoder
+	number  int
+}
+
+// newKuttLinkReader reads up to the start of the array of links
+func newKuttLinkReader(r io.Reader) (*kuttLinkReader, error) {
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	token, err := decoder.Token()
+	if err == io.EOF {
+		return nil, fmt.Errorf("the file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	// A page from the Kutt API wraps the links in its data field
+	if token == json.Delim('{') {
+		for {
+			if !decoder.More() {
+				return nil, fmt.Errorf("the JSON object has no data array")
+			}
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, fmt.Errorf("invalid JSON: %w", err)
+			}
+			if key == "data" {
+				break
+			}
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return nil, fmt.Errorf("invalid JSON: %w", err)
+			}
+		}
+		if token, err = decoder.Token(); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+	}
+	if token != json.Delim('[') {
+		return nil, fmt.Errorf("expected a JSON array of links")
+	}
+	return &kuttLinkReader{decoder: decoder}, nil
+}
+
+func (r *kuttLinkReader) Next() (*ImportRecord, error) {
+	if !r.decoder.More() {
+		return nil, io.EOF
+	}
+	r.number++
+	record := &ImportRecord{Number: r.number}
+
+	var link kuttLink
+	if err := r.decoder.Decode(&link); err != nil {
+		// A value of the wrong type only affects its own link; broken JSON ends the file
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			record.Err = fmt.Errorf("invalid %s: %v", typeErr.Field, err)
+			return record, nil
+		}
+		return nil, err
+	}
+
+	record.ShortURL.Slug = link.Address
+	record.ShortURL.URL = link.Target
+	record.ShortURL.HasPassword = link.Password
+	record.ShortURL.ClickCount = link.VisitCount
+	record.ShortURL.ExpiresAt = link.ExpireIn
+	record.ShortURL.CreatedAt = link.CreatedAt
+	return record, nil
+}
+
+// yourlsSQLReader reads the rows of INSERT statements into the YOURLS url table from an SQL dump
+// Other statements and the rows of other tables are passed over
+type yourlsSQLReader struct {
+	lexer   *sqlLexer
+	number  int
+	inRows  bool     // Whether the next token starts a row of an INSERT statement
+	columns []string // Columns of the current INSERT statement
+	matched bool     // Whether the current INSERT statement is into the url table
+}
+
+func newYOURLSSQLReader(r io.Reader) *yourlsSQLReader {
+	return &yourlsSQLReader{lexer: &sqlLexer{reader: bufio.NewReader(r)}}
+}
+
+// isYOURLSURLTable checks for the url table, yourls_url, with or without an extra prefix (e.g. wp_yourls_url)
+// Other tables ending in _url are left alone, since their rows would be mapped to YOURLS columns by position
+func isYOURLSURLTable(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), "yourls_url")
+}
+
+func (r *yourlsSQLReader) Next() (*ImportRecord, error) {
+	for {
+		if r.inRows {
+			values, err := r.readRow()
+			if err != nil {
+				return nil, err
+			}
+			if !r.matched {
+				continue
+			}
+			r.number++
+			return yourlsRecord(r.number, func(name string) string {
+				for i, column := range r.columns {
+					if column == name && i < len(values) {
+						return strings.TrimSpace(values[i])
+					}
+				}
+				return ""
+			}), nil
+		}
+
+		token, err := r.lexer.next()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case token.kind == sqlTokenEOF:
+			return nil, io.EOF
+		case token.isKeyword("INSERT") || token.isKeyword("REPLACE"):
+			if err := r.readInsert(); err != nil {
+				return nil, err
+			}
+		default:
+			if err := r.skipStatement(token); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// readInsert reads an INSERT statement up to its first row
+func (r *yourlsSQLReader) readInsert() error {
+	token, err := r.lexer.next()
+	for err == nil && (token.isKeyword("IGNORE") || token.isKeyword("INTO") || token.isKeyword("LOW_PRIORITY") || token.isKeyword("DELAYED") || token.isKeyword("HIGH_PRIORITY")) {
+		token, err = r.lexer.next()
+	}
+	if err != nil {
+		return err
+	}
+	if token.kind != sqlTokenIdentifier {
+		return fmt.Errorf("line %d: expected a table name after INSERT", r.lexer.line)
+	}
+	table := token.text
+	// A table may be qualified with its database
+	if token, err = r.lexer.next(); err != nil {
+		return err
+	}
+	for token.isPunct('.') {
+		if token, err = r.lexer.next(); err != nil {
+			return err
+		}
+		table = token.text
+		if token, err = r.lexer.next(); err != nil {
+			return err
+		}
+	}
+
+	r.columns = yourlsColumns
+	if token.isPunct('(') {
+		r.columns = nil
+		for {
+			if token, err = r.lexer.next(); err != nil {
+				return err
+			}
+			if token.kind != sqlTokenIdentifier {
+				return fmt.Errorf("line %d: expected a column name", r.lexer.line)
+			}
+			r.columns = append(r.columns, strings.ToLower(token.text))
+			if token, err = r.lexer.next(); err != nil {
+				return err
+			}
+			if token.isPunct(')') {
+				break
+			}
+			if !token.isPunct(',') {
+				return fmt.Errorf("line %d: expected ',' or ')' in the column list", r.lexer.line)
+			}
+		}
+		if token, err = r.lexer.next(); err != nil {
+			return err
+		}
+	}
+	if !token.isKeyword("VALUES") && !token.isKeyword("VALUE") {
+		// INSERT ... SELECT and INSERT ... SET have no rows to read
+		return r.skipStatement(token)
+	}
+
+	r.matched = isYOURLSURLTable(table)
+	r.inRows = true
+	return nil
+}
+
+// readRow reads one parenthesized row of values and the separator after it
+func (r *yourlsSQLReader) readRow() ([]string, error) {
+	token, err := r.lexer.next()
+	if err != nil {
+		return nil, err
+	}
+	if !token.isPunct('(') {
+		return nil, fmt.Errorf("line %d: expected '(' to start a row", r.lexer.line)
+	}
+
+	var values []string
+	for {
+		if token, err = r.lexer.next(); err != nil {
+			return nil, err
+		}
+		switch {
+		case token.kind == sqlTokenString || token.kind == sqlTokenNumber:
+			values = append(values, token.text)
+		case token.isKeyword("NULL"):
+			values = append(values, "")
+		case token.kind == sqlTokenIdentifier:
+			// Other bare words, such as CURRENT_TIMESTAMP, are kept as they are
+			values = append(values, token.text)
+		default:
+			return nil, fmt.Errorf("line %d: unexpected '%s' in a row", r.lexer.line, token.text)
+		}
+
+		if token, err = r.lexer.next(); err != nil {
+			return nil, err
+		}
+		if token.isPunct(')') {
+			break
+		}
+		if !token.isPunct(',') {
+			return nil, fmt.Errorf("line %d: expected ',' or ')' in a row", r.lexer.line)
+		}
+	}
+
+	// Rows are separated by commas; the statement ends with a semicolon or the end of the file
+	if token, err = r.lexer.next(); err != nil {
+		return nil, err
+	}
+	switch {
+	case token.isPunct(','):
+	case token.isPunct(';') || token.kind == sqlTokenEOF:
+		r.inRows = false
+	default:
+		// ON DUPLICATE KEY UPDATE and the like end the rows
+		r.inRows = false
+		if err := r.skipStatement(token); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// skipStatement reads past the end of the statement that token is part of
+func (r *yourlsSQLReader) skipStatement(token sqlToken) error {
+	var err error
+	for !token.isPunct(';') && token.kind != sqlTokenEOF {
+		if token, err = r.lexer.next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type sqlTokenKind int
+
+const (
+	sqlTokenEOF sqlTokenKind = iota
+	sqlTokenIdentifier
+	sqlTokenString
+	sqlTokenNumber
+	sqlTokenPunct
+)
+
+type sqlToken struct {
+	kind sqlTokenKind
+	text string
+}
+
+func (t sqlToken) isKeyword(keyword string) bool {
+	return t.kind == sqlTokenIdentifier && strings.EqualFold(t.text, keyword)
+}
+
+func (t sqlToken) isPunct(ch rune) bool {
+	return t.kind == sqlTokenPunct && t.text == string(ch)
+}
+
+// sqlLexer splits a MySQL dump into tokens, leaving out whitespace and comments
+// Backquoted and double-quoted names are identifiers; single-quoted strings are unescaped
+type sqlLexer struct {
+	reader *bufio.Reader
+	line   int
+}
+
+func (l *sqlLexer) read() (rune, error) {
+	ch, _, err := l.reader.ReadRune()
+	if ch == '\n' {
+		l.line++
+	}
+	return ch, err
+}
+
+func (l *sqlLexer) peek() rune {
+	ch, _, err := l.reader.ReadRune()
+	if err != nil {
+		return 0
+	}
+	l.reader.UnreadRune()
+	return ch
+}
+
+func (l *sqlLexer) next() (sqlToken, error) {
+	if l.line == 0 {
+		l.line = 1
+	}
+	for {
+		ch, err := l.read()
+		if err == io.EOF {
+			return sqlToken{kind: sqlTokenEOF}, nil
+		}
+		if err != nil {
+			return sqlToken{}, err
+		}
+
+		switch {
+		case unicode.IsSpace(ch):
+			continue
+		case ch == '#' || (ch == '-' && l.peek() == '-'):
+			if err := l.skipLine(); err != nil {
+				return sqlToken{}, err
+			}
+		case ch == '/' && l.peek() == '*':
+			if err := l.skipBlockComment(); err != nil {
+				return sqlToken{}, err
+			}
+		case ch == '`' || ch == '"':
+			text, err := l.readQuoted(ch)
+			return sqlToken{kind: sqlTokenIdentifier, text: text}, err
+		case ch == '\'':
+			text, err := l.readQuoted(ch)
+			return sqlToken{kind: sqlTokenString, text: text}, err
+		case ch == '-' || ch == '+' || (ch >= '0' && ch <= '9'):
+			return sqlToken{kind: sqlTokenNumber, text: l.readWhile(ch, isSQLNumberChar)}, nil
+		case ch == '_' || unicode.IsLetter(ch):
+			return sqlToken{kind: sqlTokenIdentifier, text: l.readWhile(ch, isSQLWordChar)}, nil
+		default:
+			return sqlToken{kind: sqlTokenPunct, text: string(ch)}, nil
+		}
+	}
+}
+
+func (l *sqlLexer) skipLine() error {
+	for {
+		ch, err := l.read()
+		if err == io.EOF || ch == '\n' {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// skipBlockComment skips a /* */ comment, including MySQL's /*!40101 ... */ version comments
+func (l *sqlLexer) skipBlockComment() error {
+	previous := rune(0)
+	for {
+		ch, err := l.read()
+		if err == io.EOF {
+			return fmt.Errorf("line %d: unterminated comment", l.line)
+		}
+		if err != nil {
+			return err
+		}
+		if previous == '*' && ch == '/' {
+			return nil
+		}
+		previous = ch
+	}
+}
+
+// readQuoted reads up to the closing quote; a doubled quote or a backslash escapes the next character
+func (l *sqlLexer) readQuoted(quote rune) (string, error) {
+	start := l.line
+	var text strings.Builder
+	for {
+		ch, err := l.read()
+		if err == io.EOF {
+			return "", fmt.Errorf("line %d: unterminated quoted value", start)
+		}
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case ch == quote && l.peek() == quote:
+			l.read()
+			text.WriteRune(quote)
+		case ch == quote:
+			return text.String(), nil
+		case ch == '\\' && quote != '`':
+			escaped, err := l.read()
+			if err != nil {
+				return "", fmt.Errorf("line %d: unterminated quoted value", start)
+			}
+			switch escaped {
+			case '0':
+				text.WriteRune(0)
+			case 'n':
+				text.WriteRune('\n')
+			case 'r':
+				text.WriteRune('\r')
+			case 't':
+				text.WriteRune('\t')
+			case 'b':
+				text.WriteRune('\b')
+			case 'Z':
+				text.WriteRune(0x1a)
+			case '%', '_':
+				// LIKE wildcards keep their backslash
+				text.WriteRune('\\')
+				text.WriteRune(escaped)
+			default:
+				text.WriteRune(escaped)
+			}
+		default:
+			text.WriteRune(ch)
+		}
+	}
+}
+
+func (l *sqlLexer) readWhile(first rune, accept func(rune) bool) string {
+	var text strings.Builder
+	text.WriteRune(first)
+	for {
+		ch := l.peek()
+		if ch == 0 || !accept(ch) {
+			return text.String()
+		}
+		l.read()
+		text.WriteRune(ch)
+	}
+}
+
+func isSQLNumberChar(ch rune) bool {
+	return (ch >= '0' && ch <= '9') || ch == '.' || ch == 'e' || ch == 'E'
+}
+
+func isSQLWordChar(ch rune) bool {
+	return ch == '_' || ch == '$' || unicode.IsLetter(ch) || unicode.IsDigit(ch)
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readAllImportRecords reads every record of a file, failing the test on an error that ends it
+func readAllImportRecords(t *testing.T, format, data string) []*ImportRecord {
+	reader, err := NewLinkImportReader(format, strings.NewReader(data))
+	assert.NoError(t, err)
+	if err != nil {
+		return nil
+	}
+
+	var records []*ImportRecord
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return records
+		}
+		if !assert.NoError(t, err) {
+			return records
+		}
+		records = append(records, record)
+	}
+}
+
+func TestLinkImportReader_YOURLSSQL(t *testing.T) {
+	dump := "-- MySQL dump 10.13\n" +
+		"/*!40101 SET NAMES utf8mb4 */;\n" +
+		"DROP TABLE IF EXISTS `yourls_url`;\n" +
+		"CREATE TABLE `yourls_url` (\n  `keyword` varchar(100) NOT NULL DEFAULT '',\n  PRIMARY KEY (`keyword`)\n) ENGINE=InnoDB;\n" +
+		"INSERT INTO `yourls_options` VALUES (1,'version','1.9');\n" +
+		"LOCK TABLES `yourls_url` WRITE;\n" +
+		"INSERT INTO `yourls_url` VALUES ('ozh','http://ozh.org/','Ozh; \\'s site','2020-01-02 03:04:05','127.0.0.1',42)," +
+		"('yourls','http://yourls.org/',NULL,'2020-01-03 00:00:00','127.0.0.1',0);\n" +
+		"INSERT IGNORE INTO `db`.`yourls_url` (`url`, `keyword`, `clicks`) VALUES\n" +
+		"('https://example.com/it''s', 'quote', 7);\n" +
+		"UNLOCK TABLES;\n"
+
+	records := readAllImportRecords(t, LinkFormatYOURLSSQL, dump)
+	assert.Len(t, records, 3)
+	if len(records) != 3 {
+		return
+	}
+
+	assert.Equal(t, 1, records[0].Number)
+	assert.Equal(t, "ozh", records[0].ShortURL.Slug)
+	assert.Equal(t, "http://ozh.org/", records[0].ShortURL.URL)
+	assert.Equal(t, 42, records[0].ShortURL.ClickCount)
+	assert.True(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC).Equal(records[0].ShortURL.CreatedAt))
+	assert.Equal(t, "yourls", records[1].ShortURL.Slug)
+
+	// Column lists may reorder the columns
+	assert.Equal(t, "quote", records[2].ShortURL.Slug)
+	assert.Equal(t, "https://example.com/it's", records[2].ShortURL.URL)
+	assert.Equal(t, 7, records[2].ShortURL.ClickCount)
+	assert.True(t, records[2].ShortURL.CreatedAt.IsZero())
+}
+
+func TestLinkImportReader_YOURLSSQLTablePrefix(t *testing.T) {
+	// Other tables ending in _url are skipped; the url table may carry an extra prefix
+	dump := "INSERT INTO `wp_short_url` VALUES ('wp','http://wp.example/','','2020-01-02 03:04:05','',0);\n" +
+		"INSERT INTO `app_redirect_url` VALUES ('app','http://app.example/','','2020-01-02 03:04:05','',0);\n" +
+		"INSERT INTO `wp_yourls_url` VALUES ('ozh','http://ozh.org/','','2020-01-02 03:04:05','',0);\n"
+
+	records := readAllImportRecords(t, LinkFormatYOURLSSQL, dump)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "ozh", records[0].ShortURL.Slug)
+	}
+}
+
+func TestLinkImportReader_YOURLSSQLErrors(t *testing.T) {
+	reader, err := NewLinkImportReader(LinkFormatYOURLSSQL, strings.NewReader("INSERT INTO yourls_url VALUES ('a','http://a.example','','yesterday','',0);"))
+	assert.NoError(t, err)
+	record, err := reader.Next()
+	assert.NoError(t, err)
+	assert.ErrorContains(t, record.Err, "timestamp")
+
+	reader, err = NewLinkImportReader(LinkFormatYOURLSSQL, strings.NewReader("INSERT INTO yourls_url VALUES ('a','http://a.example"))
+	assert.NoError(t, err)
+	_, err = reader.Next()
+	assert.ErrorContains(t, err, "unterminated")
+}
+
+func TestLinkImportReader_YOURLSCSV(t *testing.T) {
+	data := "Keyword,URL,Title,Timestamp,IP,Clicks\n" +
+		"abc,https://example.com/a,Example,2021-06-07 08:09:10,10.0.0.1,3\n" +
+		"def,https://example.com/b,,,,\n"
+
+	records := readAllImportRecords(t, LinkFormatYOURLSCSV, data)
+	assert.Len(t, records, 2)
+	assert.Equal(t, "abc", records[0].ShortURL.Slug)
+	assert.Equal(t, 3, records[0].ShortURL.ClickCount)
+	assert.True(t, time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC).Equal(records[0].ShortURL.CreatedAt))
+	assert.Equal(t, "https://example.com/b", records[1].ShortURL.URL)
+
+	_, err := NewLinkImportReader(LinkFormatYOURLSCSV, strings.NewReader("url\nhttps://example.com\n"))
+	assert.ErrorContains(t, err, "keyword column")
+}
+
+func TestLinkImportReader_BitlyCSV(t *testing.T) {
+	data := "Title,Link,Custom Link,Long URL,Created,Clicks\n" +
+		"Docs,https://bit.ly/3xYz,bit.ly/our-docs,https://example.com/docs,2022-03-04T05:06:07+0000,\"1,204\"\n" +
+		"Home,bit.ly/4aBc,,https://example.com/,3/4/2022,0\n" +
+		"Broken,bit.ly/5dEf,,https://example.com/x,last week,0\n"
+
+	records := readAllImportRecords(t, LinkFormatBitlyCSV, data)
+	assert.Len(t, records, 3)
+	if len(records) != 3 {
+		return
+	}
+
+	// Custom back-halves are preferred over the generated bitlink
+	assert.Equal(t, "our-docs", records[0].ShortURL.Slug)
+	assert.Equal(t, "https://example.com/docs", records[0].ShortURL.URL)
+	assert.Equal(t, 1204, records[0].ShortURL.ClickCount)
+	assert.True(t, time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC).Equal(records[0].ShortURL.CreatedAt))
+	assert.Equal(t, "4aBc", records[1].ShortURL.Slug)
+	assert.True(t, time.Date(2022, 3, 4, 0, 0, 0, 0, time.UTC).Equal(records[1].ShortURL.CreatedAt))
+	assert.ErrorContains(t, records[2].Err, "created")
+	assert.Empty(t, records[0].ShortURL.Domain)
+}
+
+func TestLinkImportReader_KuttJSON(t *testing.T) {
+	page := `{"limit": 10, "skip": 0, "total": 3, "data": [
+		{"id": "1", "address": "kutt1", "target": "https://example.com/1", "visit_count": 9,
+		 "created_at": "2019-05-06T07:08:09.000Z", "expire_in": "2030-01-01T00:00:00Z", "domain": null, "tags": ["a"]},
+		{"address": "kutt2", "target": "https://example.com/2", "password": true, "visit_count": "many"},
+		{"address": "kutt3", "target": "https://example.com/3"}
+	]}`
+
+	records := readAllImportRecords(t, LinkFormatKuttJSON, page)
+	assert.Len(t, records, 3)
+	if len(records) != 3 {
+		return
+	}
+	assert.Equal(t, "kutt1", records[0].ShortURL.Slug)
+	assert.Equal(t, "https://example.com/1", records[0].ShortURL.URL)
+	assert.Equal(t, 9, records[0].ShortURL.ClickCount)
+	assert.True(t, time.Date(2019, 5, 6, 7, 8, 9, 0, time.UTC).Equal(records[0].ShortURL.CreatedAt))
+	assert.True(t, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC).Equal(*records[0].ShortURL.ExpiresAt))
+	assert.ErrorContains(t, records[1].Err, "visit_count")
+	assert.Equal(t, "kutt3", records[2].ShortURL.Slug)
+
+	// A plain array of links is read the same way
+	records = readAllImportRecords(t, LinkFormatKuttJSON, `[{"address": "x", "target": "https://example.com/x", "password": true}]`)
+	assert.Len(t, records, 1)
+	assert.True(t, records[0].ShortURL.HasPassword)
+
+	_, err := NewLinkImportReader(LinkFormatKuttJSON, strings.NewReader(`{"total": 0}`))
+	assert.ErrorContains(t, err, "data array")
+	_, err = NewLinkImportReader(LinkFormatKuttJSON, strings.NewReader(`"links"`))
+	assert.Error(t, err)
+}
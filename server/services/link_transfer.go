@@ -0,0 +1,399 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"openshortpath/server/models"
+)
+
+// Link export and import formats
+const (
+	LinkFormatCSV    = "csv"
+	LinkFormatNDJSON = "ndjson"
+)
+
+// ErrUnknownLinkFormat is returned for an export or import format that isn't supported
+var ErrUnknownLinkFormat = errors.New("unknown link format")
+
+// maxNDJSONLineLength bounds a single NDJSON record
+const maxNDJSONLineLength = 1 << 20
+
+// LinkCSVColumns are the columns of a CSV export, in order
+// Imports match columns by name, so they may be reordered or left out; url is the only required one.
+// Imports also accept a password column, since exports never contain passwords
+var LinkCSVColumns = []string{
+	"id", "domain", "slug", "url", "namespace_id", "redirect_type", "expires_at", "max_clicks", "click_count",
+	"has_password", "destinations", "sticky_destinations", "forward_query", "forward_path",
+	"utm_source", "utm_medium", "utm_campaign",
+	"deep_link_ios_url", "deep_link_android_url", "deep_link_fallback_url",
+	"og_title", "og_description", "og_image_url",
+	"health_fallback_url", "created_at", "updated_at",
+}
+
+// LinkExportWriter writes short URLs in an export format
+type LinkExportWriter interface {
+	Write(shortURL *models.ShortURL) error
+	// Flush writes any buffered records to the underlying writer
+	Flush() error
+}
+
+// NewLinkExportWriter creates a writer for format; CSV exports start with a header row
+func NewLinkExportWriter(format string, w io.Writer) (LinkExportWriter, error) {
+	switch format {
+	case LinkFormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(LinkCSVColumns); err != nil {
+			return nil, err
+		}
+		return &csvLinkWriter{writer: writer}, nil
+	case LinkFormatNDJSON:
+		return &ndjsonLinkWriter{writer: bufio.NewWriter(w)}, nil
+	default:
+		return nil, ErrUnknownLinkFormat
+	}
+}
+
+// csvLinkWriter writes one CSV row per short URL
+type csvLinkWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvLinkWriter) Write(shortURL *models.ShortURL) error {
+	destinations := ""
+	if len(shortURL.Destinations) > 0 {
+		data, err := json.Marshal(shortURL.Destinations)
+		if err != nil {
+			return err
+		}
+		destinations = string(data)
+	}
+
+	return w.writer.Write([]string{
+		shortURL.ID,
+		shortURL.Domain,
+		shortURL.Slug,
+		shortURL.URL,
+		stringOrEmpty(shortURL.NamespaceID),
+		formatOptionalInt(shortURL.RedirectType),
+		formatOptionalTime(shortURL.ExpiresAt),
+		formatIntPointer(shortURL.MaxClicks),
+		strconv.Itoa(shortURL.ClickCount),
+		strconv.FormatBool(shortURL.HasPassword),
+		destinations,
+		strconv.FormatBool(shortURL.StickyDestinations),
+		strconv.FormatBool(shortURL.ForwardQuery),
+		strconv.FormatBool(shortURL.ForwardPath),
+		shortURL.UTMSource,
+		shortURL.UTMMedium,
+		shortURL.UTMCampaign,
+		shortURL.DeepLink.IOSURL,
+		shortURL.DeepLink.AndroidURL,
+		shortURL.DeepLink.FallbackURL,
+		shortURL.OpenGraph.Title,
+		shortURL.OpenGraph.Description,
+		shortURL.OpenGraph.ImageURL,
+		shortURL.HealthFallbackURL,
+		shortURL.CreatedAt.UTC().Format(time.RFC3339),
+		shortURL.UpdatedAt.UTC().Format(time.RFC3339),
+	})
+}
+
+func (w *csvLinkWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// ndjsonLinkWriter writes one JSON object per line, in the same form as the API returns short URLs
+type ndjsonLinkWriter struct {
+	writer *bufio.Writer
+}
+
+func (w *ndjsonLinkWriter) Write(shortURL *models.ShortURL) error {
+	data, err := json.Marshal(shortURL)
+	if err != nil {
+		return err
+	}
+	if _, err := w.writer.Write(data); err != nil {
+		return err
+	}
+	return w.writer.WriteByte('\n')
+}
+
+func (w *ndjsonLinkWriter) Flush() error {
+	return w.writer.Flush()
+}
+
+// ImportRecord is one link read from an import file
+// Number counts records from 1. Err is set when the record itself can't be parsed;
+// the rest of the file can still be read
+type ImportRecord struct {
+	Number   int
+	ShortURL models.ShortURL
+	Password string // Plain text password to protect the link with
+	Err      error
+}
+
+// LinkImportReader reads the records of an import file
+type LinkImportReader interface {
+	// Next returns the next record, or io.EOF after the last one
+	// Other errors mean the rest of the file can't be read
+	Next() (*ImportRecord, error)
+}
+
+// NewLinkImportReader creates a reader for format
+func NewLinkImportReader(format string, r io.Reader) (LinkImportReader, error) {
+	switch format {
+	case LinkFormatCSV:
+		return newCSVLinkReader(r)
+	case LinkFormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxNDJSONLineLength)
+		return &ndjsonLinkReader{scanner: scanner}, nil
+	case LinkFormatYOURLSSQL:
+		return newYOURLSSQLReader(r), nil
+	case LinkFormatYOURLSCSV:
+		return newForeignCSVReader(r, yourlsRecord, []string{"keyword"}, []string{"url"})
+	case LinkFormatBitlyCSV:
+		return newForeignCSVReader(r, bitlyRecord,
+			[]string{"link", "bitlink", "short_link", "short_url", "custom_link", "custom_bitlink", "custom_bitlinks", "custom_backhalf"},
+			[]string{"long_url", "destination_url", "url"})
+	case LinkFormatKuttJSON:
+		return newKuttLinkReader(r)
+	default:
+		return nil, ErrUnknownLinkFormat
+	}
+}
+
+// CountImportRecords reads through an import file and returns its number of records
+func CountImportRecords(reader LinkImportReader) (int, error) {
+	count := 0
+	for {
+		_, err := reader.Next()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+// csvLinkReader reads rows of a CSV file with a header row
+type csvLinkReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+	number  int
+}
+
+func newCSVLinkReader(r io.Reader) (*csvLinkReader, error) {
+	reader, columns, err := readCSVHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := columns["url"]; !ok {
+		return nil, fmt.Errorf("the header row has no url column")
+	}
+	return &csvLinkReader{reader: reader, columns: columns}, nil
+}
+
+// readCSVHeader reads the header row of a CSV file and returns the position of each column
+// Column names are matched in lower case, with spaces and hyphens read as underscores
+func readCSVHeader(r io.Reader) (*csv.Reader, map[string]int, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, fmt.Errorf("the file is empty")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read header row: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		name = strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+		if _, ok := columns[name]; !ok {
+			columns[name] = i
+		}
+	}
+	return reader, columns, nil
+}
+
+func (r *csvLinkReader) Next() (*ImportRecord, error) {
+	row, err := r.reader.Read()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	r.number++
+	record := &ImportRecord{Number: r.number}
+	if err != nil {
+		// A malformed row only affects its own record
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			record.Err = err
+			return record, nil
+		}
+		return nil, err
+	}
+
+	field := func(name string) string {
+		i, ok := r.columns[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	shortURL := &record.ShortURL
+	shortURL.Domain = field("domain")
+	shortURL.Slug = field("slug")
+	shortURL.URL = field("url")
+	if namespaceID := field("namespace_id"); namespaceID != "" {
+		shortURL.NamespaceID = &namespaceID
+	}
+	shortURL.StickyDestinations = parseCSVBool(field("sticky_destinations"))
+	shortURL.ForwardQuery = parseCSVBool(field("forward_query"))
+	shortURL.ForwardPath = parseCSVBool(field("forward_path"))
+	shortURL.HasPassword = parseCSVBool(field("has_password"))
+	shortURL.UTMSource = field("utm_source")
+	shortURL.UTMMedium = field("utm_medium")
+	shortURL.UTMCampaign = field("utm_campaign")
+	shortURL.DeepLink = models.DeepLink{
+		IOSURL:      field("deep_link_ios_url"),
+		AndroidURL:  field("deep_link_android_url"),
+		FallbackURL: field("deep_link_fallback_url"),
+	}
+	shortURL.OpenGraph = models.OpenGraph{
+		Title:       field("og_title"),
+		Description: field("og_description"),
+		ImageURL:    field("og_image_url"),
+	}
+	shortURL.HealthFallbackURL = field("health_fallback_url")
+	record.Password = field("password")
+
+	// Typed columns report the first value that doesn't parse
+	if value := field("redirect_type"); value != "" {
+		if shortURL.RedirectType, err = strconv.Atoi(value); err != nil {
+			record.Err = fmt.Errorf("invalid redirect_type '%s'", value)
+			return record, nil
+		}
+	}
+	if value := field("max_clicks"); value != "" {
+		maxClicks, err := strconv.Atoi(value)
+		if err != nil {
+			record.Err = fmt.Errorf("invalid max_clicks '%s'", value)
+			return record, nil
+		}
+		shortURL.MaxClicks = &maxClicks
+	}
+	if value := field("click_count"); value != "" {
+		if shortURL.ClickCount, err = strconv.Atoi(value); err != nil {
+			record.Err = fmt.Errorf("invalid click_count '%s'", value)
+			return record, nil
+		}
+	}
+	if value := field("expires_at"); value != "" {
+		expiresAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			record.Err = fmt.Errorf("invalid expires_at '%s' (must be an RFC3339 time)", value)
+			return record, nil
+		}
+		shortURL.ExpiresAt = &expiresAt
+	}
+	if value := field("created_at"); value != "" {
+		if shortURL.CreatedAt, err = time.Parse(time.RFC3339, value); err != nil {
+			record.Err = fmt.Errorf("invalid created_at '%s' (must be an RFC3339 time)", value)
+			return record, nil
+		}
+	}
+	if value := field("destinations"); value != "" {
+		if err := json.Unmarshal([]byte(value), &shortURL.Destinations); err != nil {
+			record.Err = fmt.Errorf("invalid destinations: %v", err)
+			return record, nil
+		}
+	}
+
+	return record, nil
+}
+
+// ndjsonLinkReader reads one short URL object per line; blank lines are ignored
+type ndjsonLinkReader struct {
+	scanner *bufio.Scanner
+	number  int
+}
+
+func (r *ndjsonLinkReader) Next() (*ImportRecord, error) {
+	for r.scanner.Scan() {
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		r.number++
+		record := &ImportRecord{Number: r.number}
+
+		var data struct {
+			models.ShortURL
+			Password string `json:"password"`
+		}
+		if err := json.Unmarshal(line, &data); err != nil {
+			record.Err = fmt.Errorf("invalid JSON: %v", err)
+			return record, nil
+		}
+		record.ShortURL = data.ShortURL
+		record.Password = data.Password
+		return record, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// parseCSVBool reads true, 1 or yes as true and anything else as false
+func parseCSVBool(value string) bool {
+	switch strings.ToLower(value) {
+	case "true", "1", "yes":
+		return true
+	}
+	return false
+}
+
+func stringOrEmpty(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// formatOptionalInt leaves zero values empty
+func formatOptionalInt(value int) string {
+	if value == 0 {
+		return ""
+	}
+	return strconv.Itoa(value)
+}
+
+func formatIntPointer(value *int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.Itoa(*value)
+}
+
+func formatOptionalTime(value *time.Time) string {
+	if value == nil {
+		return ""
+	}
+	return value.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"openshortpath/server/models"
+)
+
+func exampleExportLink() *models.ShortURL {
+	namespaceID := "ns-1"
+	maxClicks := 50
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	return &models.ShortURL{
+		ID:           "url-1",
+		Domain:       "example.com",
+		Slug:         "launch",
+		URL:          "https://example.com/launch?ref=a,b",
+		NamespaceID:  &namespaceID,
+		RedirectType: 301,
+		ExpiresAt:    &expiresAt,
+		MaxClicks:    &maxClicks,
+		ClickCount:   7,
+		HasPassword:  true,
+		Destinations: models.SplitDestinations{
+			{URL: "https://example.com/a", Weight: 1},
+			{URL: "https://example.com/b", Weight: 3},
+		},
+		ForwardQuery: true,
+		UTMDefaults:  models.UTMDefaults{UTMSource: "newsletter"},
+		DeepLink:     models.DeepLink{IOSURL: "app://launch"},
+		OpenGraph:    models.OpenGraph{Title: `Our "big" launch`},
+		CreatedAt:    time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC),
+		UpdatedAt:    time.Date(2024, 6, 6, 7, 8, 9, 0, time.UTC),
+	}
+}
+
+func TestLinkTransfer_RoundTrip(t *testing.T) {
+	for _, format := range []string{LinkFormatCSV, LinkFormatNDJSON} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			writer, err := NewLinkExportWriter(format, &buf)
+			assert.NoError(t, err)
+			assert.NoError(t, writer.Write(exampleExportLink()))
+			assert.NoError(t, writer.Flush())
+
+			reader, err := NewLinkImportReader(format, &buf)
+			assert.NoError(t, err)
+			record, err := reader.Next()
+			assert.NoError(t, err)
+			assert.NoError(t, record.Err)
+			assert.Equal(t, 1, record.Number)
+
+			expected := exampleExportLink()
+			imported := record.ShortURL
+			assert.Equal(t, expected.Domain, imported.Domain)
+			assert.Equal(t, expected.Slug, imported.Slug)
+			assert.Equal(t, expected.URL, imported.URL)
+			assert.Equal(t, *expected.NamespaceID, *imported.NamespaceID)
+			assert.Equal(t, expected.RedirectType, imported.RedirectType)
+			assert.True(t, expected.ExpiresAt.Equal(*imported.ExpiresAt))
+			assert.Equal(t, *expected.MaxClicks, *imported.MaxClicks)
+			assert.Equal(t, expected.ClickCount, imported.ClickCount)
+			assert.True(t, imported.HasPassword)
+			assert.Equal(t, expected.Destinations, imported.Destinations)
+			assert.True(t, imported.ForwardQuery)
+			assert.Equal(t, expected.UTMDefaults, imported.UTMDefaults)
+			assert.Equal(t, expected.DeepLink, imported.DeepLink)
+			assert.Equal(t, expected.OpenGraph, imported.OpenGraph)
+			assert.True(t, expected.CreatedAt.Equal(imported.CreatedAt))
+
+			_, err = reader.Next()
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestLinkImportReader_CSVColumnsByName(t *testing.T) {
+	data := "URL,Slug,password\nhttps://example.com/a,a,secret\nhttps://example.com/b,b,\n"
+	reader, err := NewLinkImportReader(LinkFormatCSV, strings.NewReader(data))
+	assert.NoError(t, err)
+
+	count, err := CountImportRecords(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	reader, err = NewLinkImportReader(LinkFormatCSV, strings.NewReader(data))
+	assert.NoError(t, err)
+	record, err := reader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/a", record.ShortURL.URL)
+	assert.Equal(t, "a", record.ShortURL.Slug)
+	assert.Equal(t, "secret", record.Password)
+	assert.Empty(t, record.ShortURL.Domain)
+}
+
+func TestLinkImportReader_BadRecordsDontStopTheFile(t *testing.T) {
+	csvData := "url,max_clicks\nhttps://example.com/a,lots\nhttps://example.com/b,5\n"
+	reader, err := NewLinkImportReader(LinkFormatCSV, strings.NewReader(csvData))
+	assert.NoError(t, err)
+	record, err := reader.Next()
+	assert.NoError(t, err)
+	assert.ErrorContains(t, record.Err, "max_clicks")
+	record, err = reader.Next()
+	assert.NoError(t, err)
+	assert.NoError(t, record.Err)
+	assert.Equal(t, 2, record.Number)
+
+	ndjsonData := "{\"url\": \"https://example.com/a\"}\n\nnot json\n{\"url\": \"https://example.com/c\"}\n"
+	reader, err = NewLinkImportReader(LinkFormatNDJSON, strings.NewReader(ndjsonData))
+	assert.NoError(t, err)
+	count := 0
+	failed := 0
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		count++
+		if record.Err != nil {
+			failed++
+		}
+	}
+	assert.Equal(t, 3, count)
+	assert.Equal(t, 1, failed)
+}
+
+func TestLinkImportReader_InvalidFiles(t *testing.T) {
+	_, err := NewLinkImportReader(LinkFormatCSV, strings.NewReader(""))
+	assert.Error(t, err)
+
+	_, err = NewLinkImportReader(LinkFormatCSV, strings.NewReader("domain,slug\nexample.com,a\n"))
+	assert.ErrorContains(t, err, "url column")
+
+	_, err = NewLinkImportReader("xml", strings.NewReader(""))
+	assert.ErrorIs(t, err, ErrUnknownLinkFormat)
+
+	_, err = NewLinkExportWriter("xml", io.Discard)
+	assert.ErrorIs(t, err, ErrUnknownLinkFormat)
+}
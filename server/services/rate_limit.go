@@ -231,6 +231,20 @@ type MonthlyLinkLimitInfo struct {
 	Exceeded  bool      // Whether the limit has been exceeded
 }
 
+// monthlyLinkLimitWindow returns the start of the month containing now and the start of the next month
+func monthlyLinkLimitWindow(now time.Time) (time.Time, time.Time) {
+	// Get current month start (first day of current month at 00:00:00 UTC)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	// Reset time is the start of the next month
+	var resetTime time.Time
+	if now.Month() == 12 {
+		resetTime = time.Date(now.Year()+1, 1, 1, 0, 0, 0, 0, time.UTC)
+	} else {
+		resetTime = time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return monthStart, resetTime
+}
+
 // CheckMonthlyLinkLimit checks if the monthly link limit has been exceeded and increments the counter
 // Returns monthly link limit information including limit, remaining, and reset time
 // limitPerMonth of 0 means unlimited (always returns exceeded=false)
@@ -245,16 +259,7 @@ func CheckMonthlyLinkLimit(db *gorm.DB, identifier string, limitType string, lim
 		}, nil
 	}
 
-	// Get current month start (first day of current month at 00:00:00 UTC)
-	now := time.Now().UTC()
-	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
-	// Reset time is the start of the next month
-	var resetTime time.Time
-	if now.Month() == 12 {
-		resetTime = time.Date(now.Year()+1, 1, 1, 0, 0, 0, 0, time.UTC)
-	} else {
-		resetTime = time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
-	}
+	monthStart, resetTime := monthlyLinkLimitWindow(time.Now().UTC())
 
 	// Use a transaction to atomically check and increment
 	var monthlyLimit models.MonthlyLinkLimit
@@ -300,3 +305,93 @@ func CheckMonthlyLinkLimit(db *gorm.DB, identifier string, limitType string, lim
 		Exceeded:  exceeded,
 	}, nil
 }
+
+// ReserveMonthlyLinks charges up to count links against the monthly link limit at once and
+// returns how many were granted. Unlike CheckMonthlyLinkLimit, links over the limit are never charged
+// With partial set, as many links as remain are granted; otherwise either all count links or none are
+// Links that end up not being created should be handed back with ReleaseMonthlyLinks
+func ReserveMonthlyLinks(db *gorm.DB, identifier string, limitType string, limitPerMonth int, count int, partial bool) (int, *MonthlyLinkLimitInfo, error) {
+	// Unlimited plans don't need monthly limiting
+	if limitPerMonth == 0 {
+		return count, &MonthlyLinkLimitInfo{
+			Limit:     0,
+			Remaining: -1, // -1 indicates unlimited
+			Reset:     time.Time{},
+			Exceeded:  false,
+		}, nil
+	}
+
+	monthStart, resetTime := monthlyLinkLimitWindow(time.Now().UTC())
+
+	var monthlyLimit models.MonthlyLinkLimit
+	granted := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("identifier = ? AND type = ? AND month_start = ?", identifier, limitType, monthStart).First(&monthlyLimit)
+		exists := true
+		if result.Error == gorm.ErrRecordNotFound {
+			exists = false
+			monthlyLimit = models.MonthlyLinkLimit{
+				Identifier: identifier,
+				Type:       limitType,
+				MonthStart: monthStart,
+			}
+		} else if result.Error != nil {
+			return fmt.Errorf("failed to query monthly link limit: %w", result.Error)
+		}
+
+		available := limitPerMonth - monthlyLimit.LinkCount
+		if available < 0 {
+			available = 0
+		}
+		granted = count
+		if granted > available {
+			if partial {
+				granted = available
+			} else {
+				granted = 0
+			}
+		}
+		if granted == 0 {
+			return nil
+		}
+
+		monthlyLimit.LinkCount += granted
+		if !exists {
+			return tx.Create(&monthlyLimit).Error
+		}
+		return tx.Save(&monthlyLimit).Error
+	})
+
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to reserve monthly links: %w", err)
+	}
+
+	remaining := limitPerMonth - monthlyLimit.LinkCount
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return granted, &MonthlyLinkLimitInfo{
+		Limit:     limitPerMonth,
+		Remaining: remaining,
+		Reset:     resetTime,
+		Exceeded:  granted < count,
+	}, nil
+}
+
+// ReleaseMonthlyLinks hands back count links reserved with ReserveMonthlyLinks that were not created
+func ReleaseMonthlyLinks(db *gorm.DB, identifier string, limitType string, count int) error {
+	if count <= 0 {
+		return nil
+	}
+
+	monthStart, _ := monthlyLinkLimitWindow(time.Now().UTC())
+	// The count check keeps a release that crosses into a new month from going negative
+	result := db.Model(&models.MonthlyLinkLimit{}).
+		Where("identifier = ? AND type = ? AND month_start = ? AND link_count >= ?", identifier, limitType, monthStart, count).
+		UpdateColumn("link_count", gorm.Expr("link_count - ?", count))
+	if result.Error != nil {
+		return fmt.Errorf("failed to release monthly links: %w", result.Error)
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"openshortpath/server/models"
+)
+
+// Redirect cache defaults
+const (
+	DefaultRedirectCacheSize = 10000
+	DefaultRedirectCacheTTL  = 60 * time.Second
+)
+
+// RedirectCacheKey identifies a redirect lookup
+// Namespace is the namespace name from the path, or "" for links without a namespace
+type RedirectCacheKey struct {
+	Host      string
+	Namespace string
+	Slug      string
+}
+
+// RedirectCacheEntry is the result of a redirect lookup
+// A nil ShortURL is a cached 404; for namespaced keys a nil Namespace means the namespace itself was not found
+// DeviceRules and GeoRules are the short URL's targeting rules, loaded with it
+type RedirectCacheEntry struct {
+	Namespace   *models.Namespace
+	ShortURL    *models.ShortURL
+	DeviceRules []models.DeviceRule
+	GeoRules    []models.GeoRule
+}
+
+// RedirectCacheStats reports the cache's size and effectiveness
+type RedirectCacheStats struct {
+	Size    int     `json:"size"`
+	MaxSize int     `json:"max_size"`
+	Hits    uint64  `json:"hits"`
+	Misses  uint64  `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+type redirectCacheItem struct {
+	key       RedirectCacheKey
+	entry     RedirectCacheEntry
+	expiresAt time.Time
+}
+
+// RedirectCache is an in-process LRU cache of redirect lookups with a TTL
+// It only sees writes made through this process; other instances rely on the TTL
+// All methods are safe to call on a nil *RedirectCache, which caches nothing
+type RedirectCache struct {
+	mu      sync.Mutex
+	maxSize int
+	ttl     time.Duration
+	order   *list.List // front is most recently used
+	items   map[RedirectCacheKey]*list.Element
+	hits    atomic.Uint64
+	misses  atomic.Uint64
+	now     func() time.Time
+}
+
+// NewRedirectCache creates a cache holding up to maxSize entries for ttl each
+// Zero values use DefaultRedirectCacheSize and DefaultRedirectCacheTTL
+func NewRedirectCache(maxSize int, ttl time.Duration) *RedirectCache {
+	if maxSize <= 0 {
+		maxSize = DefaultRedirectCacheSize
+	}
+	if ttl <= 0 {
+		ttl = DefaultRedirectCacheTTL
+	}
+	return &RedirectCache{
+		maxSize: maxSize,
+		ttl:     ttl,
+		order:   list.New(),
+		items:   make(map[RedirectCacheKey]*list.Element),
+		now:     time.Now,
+	}
+}
+
+// Get returns the cached entry for key and whether it was found and fresh
+func (rc *RedirectCache) Get(key RedirectCacheKey) (RedirectCacheEntry, bool) {
+	if rc == nil {
+		return RedirectCacheEntry{}, false
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	element, ok := rc.items[key]
+	if !ok {
+		rc.misses.Add(1)
+		return RedirectCacheEntry{}, false
+	}
+
+	item := element.Value.(*redirectCacheItem)
+	if !rc.now().Before(item.expiresAt) {
+		rc.removeElement(element)
+		rc.misses.Add(1)
+		return RedirectCacheEntry{}, false
+	}
+
+	rc.order.MoveToFront(element)
+	rc.hits.Add(1)
+	return item.entry, true
+}
+
+// Set stores the entry for key, evicting the least recently used entry if the cache is full
+func (rc *RedirectCache) Set(key RedirectCacheKey, entry RedirectCacheEntry) {
+	if rc == nil {
+		return
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	expiresAt := rc.now().Add(rc.ttl)
+	if element, ok := rc.items[key]; ok {
+		item := element.Value.(*redirectCacheItem)
+		item.entry = entry
+		item.expiresAt = expiresAt
+		rc.order.MoveToFront(element)
+		return
+	}
+
+	rc.items[key] = rc.order.PushFront(&redirectCacheItem{key: key, entry: entry, expiresAt: expiresAt})
+	for rc.order.Len() > rc.maxSize {
+		rc.removeElement(rc.order.Back())
+	}
+}
+
+// InvalidateShortURL removes the cached lookups of a short URL and any cached 404 for its domain and slug
+func (rc *RedirectCache) InvalidateShortURL(shortURL *models.ShortURL) {
+	if rc == nil {
+		return
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	// Slugs are unique per domain, so a 404 for this domain and slug under any namespace is stale
+	rc.removeWhere(func(item *redirectCacheItem) bool {
+		if item.entry.ShortURL != nil {
+			return item.entry.ShortURL.ID == shortURL.ID
+		}
+		return item.key.Host == shortURL.Domain && item.key.Slug == shortURL.Slug
+	})
+}
+
+// InvalidateNamespace removes every cached lookup through a namespace, including cached 404s for its name
+func (rc *RedirectCache) InvalidateNamespace(namespace *models.Namespace) {
+	if rc == nil {
+		return
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.removeWhere(func(item *redirectCacheItem) bool {
+		if item.entry.Namespace != nil && item.entry.Namespace.ID == namespace.ID {
+			return true
+		}
+		return item.key.Host == namespace.Domain && item.key.Namespace == namespace.Name
+	})
+}
+
+// Purge removes every entry
+func (rc *RedirectCache) Purge() {
+	if rc == nil {
+		return
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.order.Init()
+	rc.items = make(map[RedirectCacheKey]*list.Element)
+}
+
+// Stats returns the current size and hit/miss counters
+func (rc *RedirectCache) Stats() RedirectCacheStats {
+	if rc == nil {
+		return RedirectCacheStats{}
+	}
+
+	rc.mu.Lock()
+	size := rc.order.Len()
+	rc.mu.Unlock()
+
+	stats := RedirectCacheStats{
+		Size:    size,
+		MaxSize: rc.maxSize,
+		Hits:    rc.hits.Load(),
+		Misses:  rc.misses.Load(),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+// removeWhere removes the entries matching match; the caller must hold mu
+func (rc *RedirectCache) removeWhere(match func(item *redirectCacheItem) bool) {
+	for element := rc.order.Front(); element != nil; {
+		next := element.Next()
+		if match(element.Value.(*redirectCacheItem)) {
+			rc.removeElement(element)
+		}
+		element = next
+	}
+}
+
+// removeElement removes one entry; the caller must hold mu
+func (rc *RedirectCache) removeElement(element *list.Element) {
+	item := rc.order.Remove(element).(*redirectCacheItem)
+	delete(rc.items, item.key)
+}
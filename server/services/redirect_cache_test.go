@@ -0,0 +1,123 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"openshortpath/server/models"
+)
+
+func TestRedirectCache_GetSet(t *testing.T) {
+	cache := NewRedirectCache(10, time.Minute)
+	key := RedirectCacheKey{Host: "example.com", Slug: "abc123"}
+
+	_, ok := cache.Get(key)
+	assert.False(t, ok)
+
+	shortURL := &models.ShortURL{ID: "1", Domain: "example.com", Slug: "abc123"}
+	cache.Set(key, RedirectCacheEntry{ShortURL: shortURL})
+
+	entry, ok := cache.Get(key)
+	assert.True(t, ok)
+	assert.Equal(t, shortURL, entry.ShortURL)
+
+	stats := cache.Stats()
+	assert.Equal(t, 1, stats.Size)
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, 0.5, stats.HitRate)
+}
+
+func TestRedirectCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewRedirectCache(2, time.Minute)
+	keyA := RedirectCacheKey{Host: "example.com", Slug: "a"}
+	keyB := RedirectCacheKey{Host: "example.com", Slug: "b"}
+	keyC := RedirectCacheKey{Host: "example.com", Slug: "c"}
+
+	cache.Set(keyA, RedirectCacheEntry{})
+	cache.Set(keyB, RedirectCacheEntry{})
+	cache.Get(keyA) // a is now more recently used than b
+	cache.Set(keyC, RedirectCacheEntry{})
+
+	_, ok := cache.Get(keyA)
+	assert.True(t, ok)
+	_, ok = cache.Get(keyB)
+	assert.False(t, ok)
+	_, ok = cache.Get(keyC)
+	assert.True(t, ok)
+}
+
+func TestRedirectCache_Expires(t *testing.T) {
+	cache := NewRedirectCache(10, time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	key := RedirectCacheKey{Host: "example.com", Slug: "abc123"}
+	cache.Set(key, RedirectCacheEntry{})
+
+	now = now.Add(59 * time.Second)
+	_, ok := cache.Get(key)
+	assert.True(t, ok)
+
+	now = now.Add(time.Second)
+	_, ok = cache.Get(key)
+	assert.False(t, ok)
+	assert.Equal(t, 0, cache.Stats().Size)
+}
+
+func TestRedirectCache_InvalidateShortURL(t *testing.T) {
+	cache := NewRedirectCache(10, time.Minute)
+	shortURL := &models.ShortURL{ID: "1", Domain: "example.com", Slug: "abc123"}
+
+	positive := RedirectCacheKey{Host: "example.com", Slug: "old-slug"}
+	negative := RedirectCacheKey{Host: "example.com", Namespace: "docs", Slug: "abc123"}
+	other := RedirectCacheKey{Host: "example.com", Slug: "other"}
+	cache.Set(positive, RedirectCacheEntry{ShortURL: &models.ShortURL{ID: "1"}})
+	cache.Set(negative, RedirectCacheEntry{})
+	cache.Set(other, RedirectCacheEntry{ShortURL: &models.ShortURL{ID: "2"}})
+
+	cache.InvalidateShortURL(shortURL)
+
+	_, ok := cache.Get(positive)
+	assert.False(t, ok)
+	_, ok = cache.Get(negative)
+	assert.False(t, ok)
+	_, ok = cache.Get(other)
+	assert.True(t, ok)
+}
+
+func TestRedirectCache_InvalidateNamespace(t *testing.T) {
+	cache := NewRedirectCache(10, time.Minute)
+	namespace := &models.Namespace{ID: "ns1", Name: "new-name", Domain: "example.com"}
+
+	oldName := RedirectCacheKey{Host: "example.com", Namespace: "old-name", Slug: "abc123"}
+	newName := RedirectCacheKey{Host: "example.com", Namespace: "new-name", Slug: "abc123"}
+	other := RedirectCacheKey{Host: "example.com", Namespace: "other", Slug: "abc123"}
+	cache.Set(oldName, RedirectCacheEntry{Namespace: &models.Namespace{ID: "ns1"}, ShortURL: &models.ShortURL{ID: "1"}})
+	cache.Set(newName, RedirectCacheEntry{})
+	cache.Set(other, RedirectCacheEntry{Namespace: &models.Namespace{ID: "ns2"}})
+
+	cache.InvalidateNamespace(namespace)
+
+	_, ok := cache.Get(oldName)
+	assert.False(t, ok)
+	_, ok = cache.Get(newName)
+	assert.False(t, ok)
+	_, ok = cache.Get(other)
+	assert.True(t, ok)
+}
+
+func TestRedirectCache_Nil(t *testing.T) {
+	var cache *RedirectCache
+	key := RedirectCacheKey{Host: "example.com", Slug: "abc123"}
+
+	cache.Set(key, RedirectCacheEntry{})
+	_, ok := cache.Get(key)
+	assert.False(t, ok)
+	cache.InvalidateShortURL(&models.ShortURL{})
+	cache.InvalidateNamespace(&models.Namespace{})
+	cache.Purge()
+	assert.Equal(t, RedirectCacheStats{}, cache.Stats())
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"openshortpath/server/models"
+)
+
+// Slug generation defaults and limits
+const (
+	DefaultSlugLength              = 5
+	DefaultPronounceableSlugLength = 8
+	MaxSlugLength                  = 64
+	MinSlugAlphabetLength          = 2
+	MaxSlugAlphabetLength          = 128
+
+	// DefaultSlugAlphabet is used by random slugs without an alphabet of their own
+	DefaultSlugAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+const (
+	// base62Digits encodes sequential slugs
+	base62Digits = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	// slugConsonants and slugVowels make up pronounceable slugs
+	slugConsonants = "bdfghjklmnprstvz"
+	slugVowels     = "aeiou"
+	// maxSlugAttempts is how many candidates are tried before giving up
+	maxSlugAttempts = 10
+	// slugAttemptsPerLength is how many collisions are allowed at one length before slugs grow
+	slugAttemptsPerLength = 3
+)
+
+// ErrSlugSpaceExhausted is returned when no free slug was found within maxSlugAttempts
+var ErrSlugSpaceExhausted = errors.New("could not find an unused slug")
+
+// SlugGenerator produces candidate slugs for a new link
+type SlugGenerator interface {
+	// NextSlug returns a candidate; attempt is the number of candidates that already collided
+	NextSlug(attempt int) (string, error)
+}
+
+// ValidateSlugGeneration checks that slug generation settings are usable
+func ValidateSlugGeneration(settings models.SlugGeneration) error {
+	switch settings.Strategy {
+	case "", models.SlugStrategyRandom, models.SlugStrategySequential, models.SlugStrategyPronounceable:
+	default:
+		return fmt.Errorf("strategy must be '%s', '%s' or '%s'", models.SlugStrategyRandom, models.SlugStrategySequential, models.SlugStrategyPronounceable)
+	}
+
+	if settings.Length < 0 || settings.Length > MaxSlugLength {
+		return fmt.Errorf("length must be 0 (default) or between 1 and %d", MaxSlugLength)
+	}
+
+	if settings.Alphabet != "" {
+		if len(settings.Alphabet) < MinSlugAlphabetLength || len(settings.Alphabet) > MaxSlugAlphabetLength {
+			return fmt.Errorf("alphabet must have between %d and %d characters", MinSlugAlphabetLength, MaxSlugAlphabetLength)
+		}
+		for i, ch := range settings.Alphabet {
+			if !isSlugAlphabetChar(ch) {
+				return fmt.Errorf("alphabet may only contain letters, digits, '-' and '_'")
+			}
+			if strings.ContainsRune(settings.Alphabet[:i], ch) {
+				return fmt.Errorf("alphabet must not repeat '%c'", ch)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isSlugAlphabetChar reports whether ch can be used in a generated slug without escaping
+func isSlugAlphabetChar(ch rune) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9') || ch == '-' || ch == '_'
+}
+
+// NewSlugGenerator creates the generator for a strategy; an empty strategy means random
+// Sequential slugs count per domain, since slugs are unique per domain
+func NewSlugGenerator(db *gorm.DB, settings models.SlugGeneration, domain string) (SlugGenerator, error) {
+	if err := ValidateSlugGeneration(settings); err != nil {
+		return nil, err
+	}
+
+	switch settings.Strategy {
+	case models.SlugStrategySequential:
+		return &sequentialSlugGenerator{db: db, counter: "domain:" + domain, minLength: settings.Length}, nil
+	case models.SlugStrategyPronounceable:
+		length := settings.Length
+		if length == 0 {
+			length = DefaultPronounceableSlugLength
+		}
+		return &pronounceableSlugGenerator{length: length}, nil
+	default:
+		length := settings.Length
+		if length == 0 {
+			length = DefaultSlugLength
+		}
+		alphabet := settings.Alphabet
+		if alphabet == "" {
+			alphabet = DefaultSlugAlphabet
+		}
+		return &randomSlugGenerator{length: length, alphabet: alphabet}, nil
+	}
+}
+
+// GenerateUniqueSlug asks generator for candidates until one is unused on domain
+// Random and pronounceable slugs grow by one character every few collisions, so a crowded
+// keyspace gets bigger instead of failing
+func GenerateUniqueSlug(db *gorm.DB, generator SlugGenerator, domain string) (string, error) {
+	for attempt := 0; attempt < maxSlugAttempts; attempt++ {
+		slug, err := generator.NextSlug(attempt)
+		if err != nil {
+			return "", err
+		}
+
+		var existing models.ShortURL
+		result := db.Where("domain = ? AND slug = ?", domain, slug).First(&existing)
+		if result.Error == gorm.ErrRecordNotFound {
+			return slug, nil
+		}
+		if result.Error != nil {
+			return "", result.Error
+		}
+	}
+	return "", ErrSlugSpaceExhausted
+}
+
+// grownSlugLength adds one character for every slugAttemptsPerLength collisions
+func grownSlugLength(length, attempt int) int {
+	length += attempt / slugAttemptsPerLength
+	if length > MaxSlugLength {
+		length = MaxSlugLength
+	}
+	return length
+}
+
+// randomSlugGenerator picks characters uniformly from an alphabet
+type randomSlugGenerator struct {
+	length   int
+	alphabet string
+}
+
+func (g *randomSlugGenerator) NextSlug(attempt int) (string, error) {
+	return randomString(g.alphabet, grownSlugLength(g.length, attempt))
+}
+
+// pronounceableSlugGenerator alternates consonants and vowels
+type pronounceableSlugGenerator struct {
+	length int
+}
+
+func (g *pronounceableSlugGenerator) NextSlug(attempt int) (string, error) {
+	length := grownSlugLength(g.length, attempt)
+	slug := make([]byte, 0, length)
+	for len(slug) < length {
+		letters := slugConsonants
+		if len(slug)%2 == 1 {
+			letters = slugVowels
+		}
+		letter, err := randomString(letters, 1)
+		if err != nil {
+			return "", err
+		}
+		slug = append(slug, letter[0])
+	}
+	return string(slug), nil
+}
+
+// sequentialSlugGenerator encodes the next value of a counter in base62
+// Collisions with custom slugs simply move on to the next value
+type sequentialSlugGenerator struct {
+	db        *gorm.DB
+	counter   string
+	minLength int
+}
+
+func (g *sequentialSlugGenerator) NextSlug(attempt int) (string, error) {
+	value, err := nextSlugCounterValue(g.db, g.counter)
+	if err != nil {
+		return "", err
+	}
+
+	slug := encodeBase62(value)
+	if len(slug) < g.minLength {
+		slug = strings.Repeat(base62Digits[:1], g.minLength-len(slug)) + slug
+	}
+	return slug, nil
+}
+
+// nextSlugCounterValue increments a slug counter and returns the new value
+// A missing counter is created at 1 by the same statement, so concurrent first uses can't both insert it
+func nextSlugCounterValue(db *gorm.DB, name string) (int64, error) {
+	var counter models.SlugCounter
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"value": gorm.Expr("slug_counters.value + ?", 1)}),
+		}).Create(&models.SlugCounter{Name: name, Value: 1}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("name = ?", name).First(&counter).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to advance slug counter: %w", err)
+	}
+	return counter.Value, nil
+}
+
+// encodeBase62 encodes a non-negative number with the base62 digits
+func encodeBase62(value int64) string {
+	if value == 0 {
+		return base62Digits[:1]
+	}
+	var digits []byte
+	for value > 0 {
+		digits = append(digits, base62Digits[value%62])
+		value /= 62
+	}
+	for i, j := 0, len(digits)-1; i < j; i, j = i+1, j-1 {
+		digits[i], digits[j] = digits[j], digits[i]
+	}
+	return string(digits)
+}
+
+// randomString returns length characters chosen uniformly from alphabet
+// Random bytes that would favor the first characters of the alphabet are discarded
+func randomString(alphabet string, length int) (string, error) {
+	limit := 256 - 256%len(alphabet)
+	result := make([]byte, 0, length)
+	buf := make([]byte, length)
+	for len(result) < length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) >= limit {
+				continue
+			}
+			result = append(result, alphabet[int(b)%len(alphabet)])
+			if len(result) == length {
+				break
+			}
+		}
+	}
+	return string(result), nil
+}
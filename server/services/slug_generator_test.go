@@ -0,0 +1,162 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"openshortpath/server/models"
+)
+
+func TestValidateSlugGeneration(t *testing.T) {
+	valid := []models.SlugGeneration{
+		{},
+		{Strategy: models.SlugStrategyRandom, Length: 8, Alphabet: "abc123"},
+		{Strategy: models.SlugStrategySequential, Length: 4},
+		{Strategy: models.SlugStrategyPronounceable},
+	}
+	for _, settings := range valid {
+		assert.NoError(t, ValidateSlugGeneration(settings), "%+v should be valid", settings)
+	}
+
+	invalid := []models.SlugGeneration{
+		{Strategy: "emoji"},
+		{Length: -1},
+		{Length: MaxSlugLength + 1},
+		{Alphabet: "a"},
+		{Alphabet: "ab/"},
+		{Alphabet: "aba"},
+		{Alphabet: strings.Repeat("ab", MaxSlugAlphabetLength)},
+	}
+	for _, settings := range invalid {
+		assert.Error(t, ValidateSlugGeneration(settings), "%+v should be invalid", settings)
+	}
+}
+
+func TestRandomSlugGenerator(t *testing.T) {
+	generator, err := NewSlugGenerator(nil, models.SlugGeneration{Length: 6, Alphabet: "xyz"}, "example.com")
+	assert.NoError(t, err)
+
+	slug, err := generator.NextSlug(0)
+	assert.NoError(t, err)
+	assert.Regexp(t, `^[xyz]{6}$`, slug)
+
+	// Slugs grow by one character every few collisions
+	slug, err = generator.NextSlug(slugAttemptsPerLength)
+	assert.NoError(t, err)
+	assert.Len(t, slug, 7)
+}
+
+func TestRandomSlugGenerator_Defaults(t *testing.T) {
+	generator, err := NewSlugGenerator(nil, models.SlugGeneration{}, "example.com")
+	assert.NoError(t, err)
+
+	slug, err := generator.NextSlug(0)
+	assert.NoError(t, err)
+	assert.Regexp(t, `^[a-zA-Z0-9]{5}$`, slug)
+}
+
+func TestRandomString_Uniform(t *testing.T) {
+	// With three characters, 256 % 3 != 0, so naive modulo would favor the first one
+	counts := make(map[byte]int)
+	s, err := randomString("abc", 30000)
+	assert.NoError(t, err)
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+	for _, ch := range []byte("abc") {
+		assert.InDelta(t, 10000, counts[ch], 600, "character %c", ch)
+	}
+}
+
+func TestPronounceableSlugGenerator(t *testing.T) {
+	generator, err := NewSlugGenerator(nil, models.SlugGeneration{Strategy: models.SlugStrategyPronounceable}, "example.com")
+	assert.NoError(t, err)
+
+	slug, err := generator.NextSlug(0)
+	assert.NoError(t, err)
+	assert.Len(t, slug, DefaultPronounceableSlugLength)
+	assert.Regexp(t, `^([bdfghjklmnprstvz][aeiou])+$`, slug)
+}
+
+func TestEncodeBase62(t *testing.T) {
+	assert.Equal(t, "0", encodeBase62(0))
+	assert.Equal(t, "1", encodeBase62(1))
+	assert.Equal(t, "Z", encodeBase62(61))
+	assert.Equal(t, "10", encodeBase62(62))
+	assert.Equal(t, "ZZ", encodeBase62(62*62-1))
+}
+
+func TestSequentialSlugGenerator(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	// The counter is created at 1 or incremented by one upsert, then read back
+	for _, value := range []int64{1, 62} {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "slug_counters" ("name","value") VALUES ($1,$2) ON CONFLICT ("name") DO UPDATE SET "value"=slug_counters.value + $3`)).
+			WithArgs("domain:example.com", 1, 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery(`SELECT (.+) FROM "slug_counters"`).
+			WithArgs("domain:example.com").
+			WillReturnRows(sqlmock.NewRows([]string{"name", "value"}).AddRow("domain:example.com", value))
+		mock.ExpectCommit()
+	}
+
+	generator, err := NewSlugGenerator(db, models.SlugGeneration{Strategy: models.SlugStrategySequential, Length: 3}, "example.com")
+	assert.NoError(t, err)
+
+	slug, err := generator.NextSlug(0)
+	assert.NoError(t, err)
+	assert.Equal(t, "001", slug)
+
+	slug, err = generator.NextSlug(0)
+	assert.NoError(t, err)
+	assert.Equal(t, "010", slug)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// fixedSlugGenerator returns its slugs in order
+type fixedSlugGenerator struct {
+	slugs []string
+}
+
+func (g *fixedSlugGenerator) NextSlug(attempt int) (string, error) {
+	return g.slugs[attempt%len(g.slugs)], nil
+}
+
+func TestGenerateUniqueSlug_RetriesOnCollision(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "taken").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug"}).AddRow("1", "example.com", "taken"))
+	mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+		WithArgs("example.com", "free").
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	slug, err := GenerateUniqueSlug(db, &fixedSlugGenerator{slugs: []string{"taken", "free"}}, "example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "free", slug)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGenerateUniqueSlug_GivesUp(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	for i := 0; i < maxSlugAttempts; i++ {
+		mock.ExpectQuery(`SELECT (.+) FROM "short_urls"`).
+			WithArgs("example.com", "taken").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "domain", "slug"}).AddRow("1", "example.com", "taken"))
+	}
+
+	_, err := GenerateUniqueSlug(db, &fixedSlugGenerator{slugs: []string{"taken"}}, "example.com")
+	assert.ErrorIs(t, err, ErrSlugSpaceExhausted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
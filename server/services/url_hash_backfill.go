@@ -0,0 +1,41 @@
+package services
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"openshortpath/server/models"
+	"openshortpath/server/utils"
+)
+
+// urlHashBackfillBatchSize is how many short URLs BackfillURLHashes hashes per query
+const urlHashBackfillBatchSize = 500
+
+// BackfillURLHashes sets url_hash on short URLs created before destinations were hashed,
+// so deduplication also finds them. Returns the number of short URLs updated
+func BackfillURLHashes(db *gorm.DB) (int, error) {
+	updated := 0
+	for {
+		var batch []models.ShortURL
+		if err := db.Select("id", "url").
+			Where("url_hash = ? OR url_hash IS NULL", "").
+			Limit(urlHashBackfillBatchSize).
+			Find(&batch).Error; err != nil {
+			return updated, fmt.Errorf("failed to find short URLs without url_hash: %w", err)
+		}
+
+		for _, shortURL := range batch {
+			if err := db.Model(&models.ShortURL{}).
+				Where("id = ?", shortURL.ID).
+				UpdateColumn("url_hash", utils.URLHash(shortURL.URL)).Error; err != nil {
+				return updated, fmt.Errorf("failed to set url_hash of short URL %s: %w", shortURL.ID, err)
+			}
+			updated++
+		}
+
+		if len(batch) < urlHashBackfillBatchSize {
+			return updated, nil
+		}
+	}
+}
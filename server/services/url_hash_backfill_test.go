@@ -0,0 +1,34 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"openshortpath/server/utils"
+)
+
+func TestBackfillURLHashes(t *testing.T) {
+	db, mock, sqlDB := setupTestDB(t)
+	defer sqlDB.Close()
+
+	mock.ExpectQuery(`SELECT "id","url" FROM "short_urls" WHERE url_hash = \$1 OR url_hash IS NULL LIMIT 500`).
+		WithArgs("").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url"}).
+			AddRow("url-1", "https://Example.com/a").
+			AddRow("url-2", "https://example.com/b"))
+
+	for _, row := range []struct{ id, url string }{{"url-1", "https://Example.com/a"}, {"url-2", "https://example.com/b"}} {
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "short_urls" SET "url_hash"=\$1 WHERE id = \$2`).
+			WithArgs(utils.URLHash(row.url), row.id).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	updated, err := BackfillURLHashes(db)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, updated)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
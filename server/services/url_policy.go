@@ -0,0 +1,255 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// URL policy error codes returned to API clients
+const (
+	URLPolicyInvalidURL       = "invalid_url"
+	URLPolicySchemeNotAllowed = "scheme_not_allowed"
+	URLPolicyPrivateAddress   = "private_address"
+	URLPolicyBlockedDomain    = "blocked_domain"
+	URLPolicyShortLinkLoop    = "short_link_loop"
+)
+
+// DefaultAllowedURLSchemes are the destination schemes accepted when none are configured
+var DefaultAllowedURLSchemes = []string{"http", "https"}
+
+// urlPolicyLookupTimeout bounds the DNS lookup made for each checked destination
+const urlPolicyLookupTimeout = 3 * time.Second
+
+// URLPolicyError is a destination rejected by a URLPolicy
+type URLPolicyError struct {
+	Code    string // One of the URLPolicy* codes
+	Message string
+}
+
+func (e *URLPolicyError) Error() string {
+	return e.Message
+}
+
+// nonPublicNetworks are ranges not covered by net.IP's IsPrivate/IsLoopback/IsLinkLocal helpers
+var nonPublicNetworks = mustParseCIDRs(
+	"0.0.0.0/8",     // "This" network
+	"100.64.0.0/10", // Carrier-grade NAT
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // Benchmarking
+	"240.0.0.0/4",   // Reserved
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+	return networks
+}
+
+// isPublicIP reports whether ip is a globally routable unicast address
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// URLPolicy validates and normalizes destination URLs before they are stored
+// The domain blocklist can be reloaded while the server is running
+type URLPolicy struct {
+	allowedSchemes map[string]bool
+	allowPrivate   bool
+	shortDomains   map[string]bool
+	blocklistPath  string
+	lookupIP       func(ctx context.Context, host string) ([]net.IP, error)
+
+	mu        sync.RWMutex
+	blocklist map[string]bool
+}
+
+// NewURLPolicy creates a policy allowing allowedSchemes (DefaultAllowedURLSchemes if empty)
+// Destinations on shortDomains are rejected so short links can't point at each other and loop
+// If blocklistPath is set, the blocklist is loaded from it immediately
+func NewURLPolicy(allowedSchemes []string, allowPrivate bool, shortDomains []string, blocklistPath string) (*URLPolicy, error) {
+	if len(allowedSchemes) == 0 {
+		allowedSchemes = DefaultAllowedURLSchemes
+	}
+	policy := &URLPolicy{
+		allowedSchemes: make(map[string]bool),
+		allowPrivate:   allowPrivate,
+		shortDomains:   make(map[string]bool),
+		blocklistPath:  blocklistPath,
+		lookupIP:       lookupIP,
+		blocklist:      make(map[string]bool),
+	}
+	for _, scheme := range allowedSchemes {
+		policy.allowedSchemes[strings.ToLower(scheme)] = true
+	}
+	for _, domain := range shortDomains {
+		policy.shortDomains[normalizeHost(hostWithoutPort(domain))] = true
+	}
+
+	if err := policy.Reload(); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// SetLookupIP replaces the DNS lookup used to find a destination's addresses
+func (p *URLPolicy) SetLookupIP(lookupIP func(ctx context.Context, host string) ([]net.IP, error)) {
+	p.lookupIP = lookupIP
+}
+
+// Reload re-reads the blocklist file; the previous blocklist is kept if reading fails
+// The file has one domain per line; blank lines and lines starting with # are ignored
+func (p *URLPolicy) Reload() error {
+	if p.blocklistPath == "" {
+		return nil
+	}
+
+	file, err := os.Open(p.blocklistPath)
+	if err != nil {
+		return fmt.Errorf("failed to open URL blocklist: %w", err)
+	}
+	defer file.Close()
+
+	blocklist := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		blocklist[normalizeHost(strings.TrimPrefix(line, "*."))] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read URL blocklist: %w", err)
+	}
+
+	p.mu.Lock()
+	p.blocklist = blocklist
+	p.mu.Unlock()
+	return nil
+}
+
+// BlocklistSize returns the number of blocked domains
+func (p *URLPolicy) BlocklistSize() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.blocklist)
+}
+
+// isBlocked reports whether host or any parent domain of it is on the blocklist
+func (p *URLPolicy) isBlocked(host string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for {
+		if p.blocklist[host] {
+			return true
+		}
+		dot := strings.IndexByte(host, '.')
+		if dot < 0 {
+			return false
+		}
+		host = host[dot+1:]
+	}
+}
+
+// Check validates a destination URL and returns its normalized form
+// Rejections are returned as *URLPolicyError
+func (p *URLPolicy) Check(ctx context.Context, rawURL string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || parsed.Scheme == "" {
+		return "", &URLPolicyError{Code: URLPolicyInvalidURL, Message: "URL must be absolute, including a scheme such as https://"}
+	}
+
+	scheme := strings.ToLower(parsed.Scheme)
+	if !p.allowedSchemes[scheme] {
+		return "", &URLPolicyError{Code: URLPolicySchemeNotAllowed, Message: fmt.Sprintf("URL scheme '%s' is not allowed", scheme)}
+	}
+	if parsed.Host == "" || parsed.User != nil {
+		return "", &URLPolicyError{Code: URLPolicyInvalidURL, Message: "URL must have a host and no user info"}
+	}
+
+	host := normalizeHost(parsed.Hostname())
+	if host == "" {
+		return "", &URLPolicyError{Code: URLPolicyInvalidURL, Message: "URL must have a host"}
+	}
+
+	// Normalize: lowercase scheme and host, no trailing dot, no default port
+	parsed.Scheme = scheme
+	port := parsed.Port()
+	if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		port = ""
+	}
+	parsed.Host = host
+	if strings.Contains(host, ":") {
+		parsed.Host = "[" + host + "]"
+	}
+	if port != "" {
+		parsed.Host += ":" + port
+	}
+
+	if p.shortDomains[host] {
+		return "", &URLPolicyError{Code: URLPolicyShortLinkLoop, Message: "URL must not point to another short link"}
+	}
+	if p.isBlocked(host) {
+		return "", &URLPolicyError{Code: URLPolicyBlockedDomain, Message: fmt.Sprintf("Domain '%s' is blocked", host)}
+	}
+
+	if !p.allowPrivate {
+		var ips []net.IP
+		if ip := net.ParseIP(host); ip != nil {
+			ips = []net.IP{ip}
+		} else {
+			lookupCtx, cancel := context.WithTimeout(ctx, urlPolicyLookupTimeout)
+			// Hosts that don't resolve yet are allowed; there is no address to check
+			ips, _ = p.lookupIP(lookupCtx, host)
+			cancel()
+		}
+		for _, ip := range ips {
+			if !isPublicIP(ip) {
+				return "", &URLPolicyError{Code: URLPolicyPrivateAddress, Message: "URL must not point to a private, loopback or reserved address"}
+			}
+		}
+	}
+
+	return parsed.String(), nil
+}
+
+// lookupIP resolves host to its IPv4 and IPv6 addresses
+func lookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+}
+
+// normalizeHost lowercases a host name and removes a trailing dot
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// hostWithoutPort strips the port from a host:port pair
+func hostWithoutPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// QR code option limits and defaults
+const (
+	QRCodeDefaultSize   = 256
+	QRCodeMinSize       = 64
+	QRCodeMaxSize       = 2048
+	QRCodeDefaultMargin = 4 // Quiet zone in modules, as recommended by the QR code spec
+	QRCodeMaxMargin     = 16
+	QRCodeDefaultLevel  = "M"
+)
+
+// qrCodeLevels maps error-correction level names to their recovery levels
+var qrCodeLevels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,     // ~7% of the code can be restored
+	"M": qrcode.Medium,  // ~15%
+	"Q": qrcode.High,    // ~25%
+	"H": qrcode.Highest, // ~30%
+}
+
+// QRCodeOptions controls how a QR code is rendered
+type QRCodeOptions struct {
+	Size       int    // Width and height in pixels
+	Level      string // Error-correction level: L, M, Q or H
+	Margin     int    // Quiet zone around the code in modules
+	Foreground color.RGBA
+	Background color.RGBA
+}
+
+// DefaultQRCodeOptions returns black-on-white options with the default size, level and margin
+func DefaultQRCodeOptions() QRCodeOptions {
+	return QRCodeOptions{
+		Size:       QRCodeDefaultSize,
+		Level:      QRCodeDefaultLevel,
+		Margin:     QRCodeDefaultMargin,
+		Foreground: color.RGBA{0, 0, 0, 255},
+		Background: color.RGBA{255, 255, 255, 255},
+	}
+}
+
+// IsValidQRCodeLevel checks if level is one of L, M, Q or H
+func IsValidQRCodeLevel(level string) bool {
+	_, ok := qrCodeLevels[level]
+	return ok
+}
+
+// ParseHexColor parses a #rgb or #rrggbb color; the leading # is optional
+func ParseHexColor(value string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(value, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q (must be #rgb or #rrggbb)", value)
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q (must be #rgb or #rrggbb)", value)
+	}
+	return color.RGBA{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), 255}, nil
+}
+
+// qrCodeModules encodes content and returns its modules without a quiet zone
+func qrCodeModules(content string, level string) ([][]bool, error) {
+	recoveryLevel, ok := qrCodeLevels[level]
+	if !ok {
+		return nil, fmt.Errorf("invalid error-correction level %q (must be L, M, Q or H)", level)
+	}
+	code, err := qrcode.New(content, recoveryLevel)
+	if err != nil {
+		return nil, err
+	}
+	code.DisableBorder = true
+	return code.Bitmap(), nil
+}
+
+// RenderQRCodePNG renders content as a PNG QR code of exactly opts.Size pixels
+// Modules are whole pixels, so any leftover space is added to the margin
+func RenderQRCodePNG(content string, opts QRCodeOptions) ([]byte, error) {
+	modules, err := qrCodeModules(content, opts.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	total := len(modules) + 2*opts.Margin
+	scale := opts.Size / total
+	if scale < 1 {
+		return nil, fmt.Errorf("size %d is too small for this QR code (needs at least %d)", opts.Size, total)
+	}
+	offset := (opts.Size-scale*total)/2 + opts.Margin*scale
+
+	img := image.NewPaletted(image.Rect(0, 0, opts.Size, opts.Size), color.Palette{opts.Background, opts.Foreground})
+	for y, row := range modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for py := 0; py < scale; py++ {
+				for px := 0; px < scale; px++ {
+					img.SetColorIndex(offset+x*scale+px, offset+y*scale+py, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderQRCodeSVG renders content as an SVG QR code opts.Size pixels wide
+// The code is drawn in module units, so it scales without blurring
+func RenderQRCodeSVG(content string, opts QRCodeOptions) ([]byte, error) {
+	modules, err := qrCodeModules(content, opts.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	total := len(modules) + 2*opts.Margin
+	var path strings.Builder
+	for y, row := range modules {
+		// Draw each horizontal run of dark modules as one rectangle
+		for x := 0; x < len(row); {
+			if !row[x] {
+				x++
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&path, "M%d %dh%dv1h-%dz", start+opts.Margin, y+opts.Margin, x-start, x-start)
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, opts.Size, opts.Size, total, total)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="%s"/>`, total, total, hexColor(opts.Background))
+	fmt.Fprintf(&buf, `<path fill="%s" d="%s"/>`, hexColor(opts.Foreground), path.String())
+	buf.WriteString("</svg>\n")
+	return buf.Bytes(), nil
+}
+
+// hexColor formats a color as #rrggbb
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHexColor(t *testing.T) {
+	tests := []struct {
+		value       string
+		expected    color.RGBA
+		expectError bool
+	}{
+		{"#000000", color.RGBA{0, 0, 0, 255}, false},
+		{"1a56db", color.RGBA{0x1a, 0x56, 0xdb, 255}, false},
+		{"#fff", color.RGBA{255, 255, 255, 255}, false},
+		{"#ffff", color.RGBA{}, true},
+		{"#gggggg", color.RGBA{}, true},
+		{"", color.RGBA{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			c, err := ParseHexColor(tt.value)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, c)
+		})
+	}
+}
+
+func TestRenderQRCodePNG(t *testing.T) {
+	opts := DefaultQRCodeOptions()
+	opts.Size = 300
+	opts.Foreground = color.RGBA{0x1a, 0x56, 0xdb, 255}
+
+	data, err := RenderQRCodePNG("https://example.com/abc123", opts)
+	assert.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, 300, img.Bounds().Dx())
+	assert.Equal(t, 300, img.Bounds().Dy())
+
+	// The corner is quiet zone, and the top-left finder pattern starts right after the margin
+	r, g, b, _ := img.At(0, 0).RGBA()
+	assert.Equal(t, [3]uint32{0xffff, 0xffff, 0xffff}, [3]uint32{r, g, b})
+	modules := 25 // this URL needs a version 2 code at level M, which is 25 modules wide
+	scale := 300 / (modules + 2*opts.Margin)
+	offset := (300-scale*(modules+2*opts.Margin))/2 + opts.Margin*scale
+	r, g, b, _ = img.At(offset, offset).RGBA()
+	assert.Equal(t, [3]uint32{0x1a1a, 0x5656, 0xdbdb}, [3]uint32{r, g, b})
+}
+
+func TestRenderQRCodePNG_SizeTooSmall(t *testing.T) {
+	opts := DefaultQRCodeOptions()
+	opts.Size = 20
+
+	_, err := RenderQRCodePNG("https://example.com/abc123", opts)
+	assert.Error(t, err)
+}
+
+func TestRenderQRCodeSVG(t *testing.T) {
+	opts := DefaultQRCodeOptions()
+	opts.Margin = 2
+	opts.Background = color.RGBA{0xff, 0xee, 0xdd, 255}
+
+	data, err := RenderQRCodeSVG("https://example.com/abc123", opts)
+	assert.NoError(t, err)
+
+	svg := string(data)
+	assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="256" height="256" viewBox="0 0 29 29"`))
+	assert.Contains(t, svg, `fill="#ffeedd"`)
+	assert.Contains(t, svg, `<path fill="#000000" d="M2 2h7v1h-7z`)
+}
+
+func TestRenderQRCode_InvalidLevel(t *testing.T) {
+	opts := DefaultQRCodeOptions()
+	opts.Level = "X"
+
+	_, err := RenderQRCodeSVG("https://example.com/abc123", opts)
+	assert.Error(t, err)
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strings"
+)
+
+// NormalizeURL returns the form of a destination URL used to recognize the same destination
+// The scheme and host are lowercased, default ports and a trailing dot on the host are removed,
+// an empty path becomes "/" and query parameters are sorted. Unparseable URLs are only trimmed
+func NormalizeURL(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return rawURL
+	}
+
+	scheme := strings.ToLower(parsed.Scheme)
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	port := parsed.Port()
+	if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		port = ""
+	}
+	parsed.Scheme = scheme
+	parsed.Host = host
+	if strings.Contains(host, ":") {
+		parsed.Host = "[" + host + "]"
+	}
+	if port != "" {
+		parsed.Host += ":" + port
+	}
+	if parsed.Path == "" {
+		parsed.Path = "/"
+	}
+	if parsed.RawQuery != "" {
+		if query, err := url.ParseQuery(parsed.RawQuery); err == nil {
+			parsed.RawQuery = query.Encode()
+		}
+	}
+	return parsed.String()
+}
+
+// URLHash returns the hex SHA-256 of the normalized form of rawURL, for indexed lookups of a destination
+func URLHash(rawURL string) string {
+	sum := sha256.Sum256([]byte(NormalizeURL(rawURL)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"lowercases scheme and host", "HTTPS://Example.COM/Path", "https://example.com/Path"},
+		{"removes default port", "https://example.com:443/a", "https://example.com/a"},
+		{"keeps other ports", "http://example.com:8080/a", "http://example.com:8080/a"},
+		{"adds root path", "https://example.com", "https://example.com/"},
+		{"removes trailing dot", "https://example.com./a", "https://example.com/a"},
+		{"sorts query parameters", "https://example.com/?b=2&a=1", "https://example.com/?a=1&b=2"},
+		{"keeps fragment", "https://example.com/a#top", "https://example.com/a#top"},
+		{"trims relative URLs", "  not a url  ", "not a url"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, NormalizeURL(tt.input))
+		})
+	}
+}
+
+func TestURLHash(t *testing.T) {
+	assert.Equal(t, URLHash("https://Example.com:443?b=2&a=1"), URLHash("https://example.com/?a=1&b=2"))
+	assert.NotEqual(t, URLHash("https://example.com/a"), URLHash("https://example.com/b"))
+	assert.Len(t, URLHash("https://example.com"), 64)
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"strings"
+)
+
+// Platforms recognised in user agents
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	PlatformWindows = "windows"
+	PlatformMacOS   = "macos"
+	PlatformLinux   = "linux"
+	PlatformOther   = "other"
+)
+
+// Device types recognised in user agents
+const (
+	DeviceTypeMobile  = "mobile"
+	DeviceTypeTablet  = "tablet"
+	DeviceTypeDesktop = "desktop"
+	DeviceTypeBot     = "bot"
+	DeviceTypeUnknown = "unknown"
+)
+
+// TargetablePlatforms contains the platforms that device rules can match on
+var TargetablePlatforms = map[string]bool{
+	PlatformIOS:     true,
+	PlatformAndroid: true,
+	PlatformWindows: true,
+	PlatformMacOS:   true,
+	PlatformLinux:   true,
+}
+
+// UserAgentInfo is a coarse summary of a User-Agent header
+type UserAgentInfo struct {
+	Platform   string `json:"platform"`
+	DeviceType string `json:"device_type"`
+	Browser    string `json:"browser"`
+}
+
+// ParseUserAgent summarises a User-Agent header into platform, device type and browser
+// It only looks for well-known tokens and is not meant to identify exact versions
+func ParseUserAgent(userAgent string) UserAgentInfo {
+	ua := strings.ToLower(userAgent)
+	info := UserAgentInfo{
+		Platform:   PlatformOther,
+		DeviceType: DeviceTypeUnknown,
+		Browser:    "other",
+	}
+	if ua == "" {
+		return info
+	}
+
+	// Platform - order matters: Android UAs mention Linux, iOS UAs mention "like Mac OS X"
+	switch {
+	case strings.Contains(ua, "android"):
+		info.Platform = PlatformAndroid
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ipod"):
+		info.Platform = PlatformIOS
+	case strings.Contains(ua, "windows"):
+		info.Platform = PlatformWindows
+	case strings.Contains(ua, "macintosh"), strings.Contains(ua, "mac os x"):
+		info.Platform = PlatformMacOS
+	case strings.Contains(ua, "linux"), strings.Contains(ua, "x11"), strings.Contains(ua, "cros"):
+		info.Platform = PlatformLinux
+	}
+
+	// Device type
+	switch {
+	case strings.Contains(ua, "bot"), strings.Contains(ua, "crawler"), strings.Contains(ua, "spider"), strings.Contains(ua, "curl/"), strings.Contains(ua, "wget/"):
+		info.DeviceType = DeviceTypeBot
+	case strings.Contains(ua, "ipad"), strings.Contains(ua, "tablet"),
+		info.Platform == PlatformAndroid && !strings.Contains(ua, "mobile"):
+		info.DeviceType = DeviceTypeTablet
+	case strings.Contains(ua, "mobile"), strings.Contains(ua, "iphone"), strings.Contains(ua, "ipod"):
+		info.DeviceType = DeviceTypeMobile
+	case info.Platform != PlatformOther:
+		info.DeviceType = DeviceTypeDesktop
+	}
+
+	// Browser - order matters: Edge and Opera UAs also mention Chrome, Chrome UAs also mention Safari
+	switch {
+	case strings.Contains(ua, "edg/"), strings.Contains(ua, "edga/"), strings.Contains(ua, "edgios/"):
+		info.Browser = "edge"
+	case strings.Contains(ua, "opr/"), strings.Contains(ua, "opera"):
+		info.Browser = "opera"
+	case strings.Contains(ua, "samsungbrowser/"):
+		info.Browser = "samsung"
+	case strings.Contains(ua, "firefox/"), strings.Contains(ua, "fxios/"):
+		info.Browser = "firefox"
+	case strings.Contains(ua, "chrome/"), strings.Contains(ua, "crios/"):
+		info.Browser = "chrome"
+	case strings.Contains(ua, "safari/"):
+		info.Browser = "safari"
+	}
+
+	return info
+}
+
+// linkPreviewCrawlers contains lowercase user agent tokens of crawlers that fetch link previews
+var linkPreviewCrawlers = []string{
+	"facebookexternalhit",
+	"facebot",
+	"twitterbot",
+	"linkedinbot",
+	"slackbot",
+	"slack-imgproxy",
+	"discordbot",
+	"telegrambot",
+	"whatsapp",
+	"skypeuripreview",
+	"microsoftpreview",
+	"pinterestbot",
+	"redditbot",
+	"mastodon",
+	"embedly",
+	"iframely",
+	"vkshare",
+	"google-pagerenderer",
+}
+
+// IsLinkPreviewCrawler reports whether a User-Agent belongs to a crawler that unfurls shared links
+func IsLinkPreviewCrawler(userAgent string) bool {
+	ua := strings.ToLower(userAgent)
+	for _, token := range linkPreviewCrawlers {
+		if strings.Contains(ua, token) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		expected  UserAgentInfo
+	}{
+		{
+			name:      "iPhone Safari",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+			expected:  UserAgentInfo{Platform: PlatformIOS, DeviceType: DeviceTypeMobile, Browser: "safari"},
+		},
+		{
+			name:      "iPad Chrome",
+			userAgent: "Mozilla/5.0 (iPad; CPU OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/123.0.6312.52 Mobile/15E148 Safari/604.1",
+			expected:  UserAgentInfo{Platform: PlatformIOS, DeviceType: DeviceTypeTablet, Browser: "chrome"},
+		},
+		{
+			name:      "Android phone Chrome",
+			userAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/123.0.0.0 Mobile Safari/537.36",
+			expected:  UserAgentInfo{Platform: PlatformAndroid, DeviceType: DeviceTypeMobile, Browser: "chrome"},
+		},
+		{
+			name:      "Android tablet",
+			userAgent: "Mozilla/5.0 (Linux; Android 13; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/123.0.0.0 Safari/537.36",
+			expected:  UserAgentInfo{Platform: PlatformAndroid, DeviceType: DeviceTypeTablet, Browser: "chrome"},
+		},
+		{
+			name:      "Windows Edge",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/123.0.0.0 Safari/537.36 Edg/123.0.0.0",
+			expected:  UserAgentInfo{Platform: PlatformWindows, DeviceType: DeviceTypeDesktop, Browser: "edge"},
+		},
+		{
+			name:      "macOS Firefox",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 14.4; rv:124.0) Gecko/20100101 Firefox/124.0",
+			expected:  UserAgentInfo{Platform: PlatformMacOS, DeviceType: DeviceTypeDesktop, Browser: "firefox"},
+		},
+		{
+			name:      "Linux Chrome",
+			userAgent: "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/123.0.0.0 Safari/537.36",
+			expected:  UserAgentInfo{Platform: PlatformLinux, DeviceType: DeviceTypeDesktop, Browser: "chrome"},
+		},
+		{
+			name:      "crawler",
+			userAgent: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			expected:  UserAgentInfo{Platform: PlatformOther, DeviceType: DeviceTypeBot, Browser: "other"},
+		},
+		{
+			name:      "empty",
+			userAgent: "",
+			expected:  UserAgentInfo{Platform: PlatformOther, DeviceType: DeviceTypeUnknown, Browser: "other"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ParseUserAgent(tt.userAgent))
+		})
+	}
+}
+
+func TestIsLinkPreviewCrawler(t *testing.T) {
+	crawlers := []string{
+		"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)",
+		"Twitterbot/1.0",
+		"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)",
+		"Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)",
+		"WhatsApp/2.23.20.0",
+		"TelegramBot (like TwitterBot)",
+		"LinkedInBot/1.0 (compatible; Mozilla/5.0; Apache-HttpClient +http://www.linkedin.com)",
+	}
+	for _, ua := range crawlers {
+		assert.True(t, IsLinkPreviewCrawler(ua), ua)
+	}
+
+	notCrawlers := []string{
+		"",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+	}
+	for _, ua := range notCrawlers {
+		assert.False(t, IsLinkPreviewCrawler(ua), ua)
+	}
+}